@@ -31,6 +31,7 @@ const formSchema = z.object({
       message: 'Username must be at least 2 characters.',
     })
     .max(20, { message: 'username cannot be more than 20 characters' }),
+  email: z.string().email({ message: 'Enter a valid email address' }),
   password: z
     .string()
     .min(8, {
@@ -48,6 +49,7 @@ export default function Page() {
     resolver: zodResolver(formSchema),
     defaultValues: {
       userName: '',
+      email: '',
       password: '',
     },
   });
@@ -56,7 +58,7 @@ export default function Page() {
     mutationFn: (data: z.infer<typeof formSchema>) => signUp(data),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: queryKeys.user.all });
-      toast.success('Account created successfully');
+      toast.success('Account created. Check your email to verify it');
       setTimeout(() => {
         push('/login');
       }, 2000);
@@ -112,6 +114,23 @@ export default function Page() {
                 </FormItem>
               )}
             />
+            <FormField
+              control={form.control}
+              name="email"
+              render={({ field }) => (
+                <FormItem>
+                  <FormLabel className="font-bold text-sm">Email</FormLabel>
+                  <FormControl>
+                    <Input
+                      placeholder="you@example.com"
+                      {...field}
+                      type="email"
+                    />
+                  </FormControl>
+                  <FormMessage />
+                </FormItem>
+              )}
+            />
             <FormField
               control={form.control}
               name="password"
@@ -0,0 +1,57 @@
+package aihelpers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/somtojf/trio/qdrantpackage"
+)
+
+const MAX_EMBEDDING_BATCH = 100
+
+type EmbeddableMessage struct {
+	MessageID  string
+	ChatID     string
+	SenderType string
+	SenderName string
+	Content    string
+}
+
+// EmbedMessages embeds the messages on behalf of call.User and stores them in the messages collection
+func EmbedMessages(ctx context.Context, caller Caller, vectors qdrantpackage.VectorStore, modelName string, call ModelCall, messages []EmbeddableMessage) error {
+	for start := 0; start < len(messages); start += MAX_EMBEDDING_BATCH {
+		end := min(start+MAX_EMBEDDING_BATCH, len(messages))
+		chunk := messages[start:end]
+
+		texts := make([]string, 0, len(chunk))
+		for _, message := range chunk {
+			texts = append(texts, message.Content)
+		}
+
+		embeddings, err := EmbedContent(ctx, caller, modelName, call, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed messages: %w", err)
+		}
+		if len(embeddings) != len(chunk) {
+			return fmt.Errorf("expected %d embeddings, received %d", len(chunk), len(embeddings))
+		}
+
+		points := make([]qdrantpackage.MessagePoint, 0, len(chunk))
+		for i, message := range chunk {
+			points = append(points, qdrantpackage.MessagePoint{
+				MessageID:  message.MessageID,
+				ChatID:     message.ChatID,
+				SenderType: message.SenderType,
+				SenderName: message.SenderName,
+				Content:    message.Content,
+				Vector:     embeddings[i],
+			})
+		}
+
+		if err := vectors.UpsertMessagePoints(ctx, points); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package aihelpers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/somtojf/trio/llm"
+	"github.com/somtojf/trio/metrics"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/types"
+)
+
+// Caller is what model calls go through: the provider, the usage counters and plans every call is
+// checked against and recorded in, and the metrics it is observed by
+type Caller struct {
+	Provider llm.Provider
+	Usage    repository.UsageRepository
+	Plans    *quota.Plans
+	Metrics  *metrics.Metrics
+}
+
+// ModelCall describes who a model call is made for. ChatID is zero and Agent nil for calls outside a chat.
+type ModelCall struct {
+	User   models.User
+	ChatID uint
+	Agent  *models.Agent
+}
+
+// GenerateContent calls the model on behalf of the user, refusing once their quota is used up.
+// Every call, including failed ones, is logged with its tokens, latency and estimated cost.
+func GenerateContent(ctx context.Context, caller Caller, modelName string, call ModelCall, prompt string) (llm.Completion, error) {
+	if _, err := quota.Check(ctx, caller.Usage, caller.Plans, call.User); err != nil {
+		return llm.Completion{}, err
+	}
+
+	start := time.Now()
+	completion, err := caller.Provider.Generate(ctx, modelName, prompt)
+	latency := time.Since(start)
+
+	logModelCall(ctx, caller.Usage, modelName, call, prompt, completion, err, latency)
+	caller.Metrics.ObserveModelCall(modelName, latency, completion, err)
+	if err != nil {
+		return llm.Completion{}, err
+	}
+
+	if err := quota.Record(ctx, caller.Usage, call.User.ID, int64(completion.TotalTokens())); err != nil {
+		slog.Error("Failed to record quota usage", "userId", call.User.ExternalID, "error", err)
+	}
+
+	return completion, nil
+}
+
+// EmbedContent embeds the texts on behalf of the user with the same quota check, logging and metrics
+// as GenerateContent. The embedding API does not report usage, so input tokens are estimated.
+func EmbedContent(ctx context.Context, caller Caller, modelName string, call ModelCall, texts []string) ([][]float32, error) {
+	if _, err := quota.Check(ctx, caller.Usage, caller.Plans, call.User); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	embeddings, err := caller.Provider.Embed(ctx, modelName, texts)
+	latency := time.Since(start)
+
+	completion := llm.Completion{InputTokens: estimateTokens(texts)}
+	logModelCall(ctx, caller.Usage, modelName, call, strings.Join(texts, "\n\n"), completion, err, latency)
+	caller.Metrics.ObserveModelCall(modelName, latency, completion, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := quota.Record(ctx, caller.Usage, call.User.ID, int64(completion.TotalTokens())); err != nil {
+		slog.Error("Failed to record quota usage", "userId", call.User.ExternalID, "error", err)
+	}
+
+	return embeddings, nil
+}
+
+// estimateTokens uses the rough four characters per token Gemini documents for English text
+func estimateTokens(texts []string) int {
+	characters := 0
+	for _, text := range texts {
+		characters += utf8.RuneCountInString(text)
+	}
+	return (characters + 3) / 4
+}
+
+func logModelCall(ctx context.Context, usage repository.UsageRepository, modelName string, call ModelCall, prompt string, completion llm.Completion, callErr error, latency time.Duration) {
+	entry := models.GeminiLogs{
+		Prompt:       prompt,
+		SenderType:   string(types.SenderTypeUser),
+		SenderID:     call.User.ID,
+		UserID:       &call.User.ID,
+		ModelName:    modelName,
+		LatencyMs:    latency.Milliseconds(),
+		InputTokens:  completion.InputTokens,
+		OutputTokens: completion.OutputTokens,
+		TotalTokens:  completion.TotalTokens(),
+		FinishReason: completion.FinishReason,
+	}
+	if call.ChatID != 0 {
+		entry.ChatID = &call.ChatID
+	}
+	if call.Agent != nil {
+		entry.SenderType = string(types.SenderTypeAgent)
+		entry.SenderID = call.Agent.ID
+		entry.AgentID = &call.Agent.ID
+	}
+
+	if callErr != nil {
+		entry.Error = callErr.Error()
+
+		var blocked *llm.BlockedError
+		if errors.As(callErr, &blocked) {
+			entry.FinishReason = blocked.FinishReason
+		}
+	}
+
+	entry.EstimatedCost = EstimateCost(modelName, entry.InputTokens, entry.OutputTokens)
+
+	// Logging must not fail the call the user is waiting for
+	if err := usage.LogModelCall(ctx, &entry); err != nil {
+		slog.Error("Failed to log model call", "model", modelName, "error", err)
+	}
+}
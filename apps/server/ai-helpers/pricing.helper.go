@@ -0,0 +1,20 @@
+package aihelpers
+
+// ModelPricing is the list price in USD per million tokens
+type ModelPricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// Prices for prompts up to 128k tokens, which is all Trio sends
+var modelPricing = map[string]ModelPricing{
+	"gemini-1.5-flash":   {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":     {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+	"text-embedding-004": {},
+}
+
+// EstimateCost returns the approximate USD cost of a call. Unknown models are estimated at zero.
+func EstimateCost(model string, inputTokens int, outputTokens int) float64 {
+	pricing := modelPricing[model]
+	return (float64(inputTokens)*pricing.InputPerMillion + float64(outputTokens)*pricing.OutputPerMillion) / 1_000_000
+}
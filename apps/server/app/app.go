@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/initializers"
+	"github.com/somtojf/trio/lifecycle"
+	"github.com/somtojf/trio/llm"
+	"github.com/somtojf/trio/mailer"
+	"github.com/somtojf/trio/metrics"
+	"github.com/somtojf/trio/qdrantpackage"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/ratelimit"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
+	"gorm.io/gorm"
+)
+
+// App holds the dependencies shared by every handler. main builds one with New;
+// tests build their own with in-memory repositories and fakes in place of the vector store or model.
+type App struct {
+	DB *gorm.DB
+	repository.Repositories
+	Vectors qdrantpackage.VectorStore
+	LLM     llm.Provider
+	Config  config.Config
+	// Keys sign tokens and encrypt secrets at rest with the configured SECRET
+	Keys   auth.Keys
+	Logger *slog.Logger
+	// Generations lets shutdown wait for in-flight model calls
+	Generations *lifecycle.Generations
+	// Broker delivers realtime events to connected users
+	Broker realtime.Broker
+	// RateLimitStore keeps the rate limiting middleware's buckets
+	RateLimitStore ratelimit.Store
+	Mailer         mailer.Mailer
+	// Plans are the quota plans users are assigned to
+	Plans *quota.Plans
+	// OIDC holds the configured single sign-on providers
+	OIDC *auth.OIDCProviders
+	// Metrics is the Prometheus registry every metric is recorded in
+	Metrics *metrics.Metrics
+}
+
+// New connects to Postgres, Qdrant and Gemini
+func New(ctx context.Context, cfg config.Config) (*App, error) {
+	keys, err := auth.NewKeys(cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	plans, err := quota.LoadPlans(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := initializers.ConnectToDb(cfg.Database.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Qdrant is only used for search, so the server still starts without it
+	vectors, err := qdrantpackage.ConnectToQdrant(cfg.Qdrant.Host, cfg.Qdrant.Port)
+	if err == nil {
+		err = vectors.CreateCollections([]qdrantpackage.CollectionName{qdrantpackage.Messages})
+	}
+	// A collection with the wrong dimensionality is a deployment mistake, not an outage, so it stops startup
+	if errors.Is(err, qdrantpackage.ErrVectorSizeMismatch) {
+		return nil, err
+	}
+	if err != nil {
+		slog.Error("Qdrant is unavailable", "error", err)
+	}
+
+	provider, err := newProvider(ctx, cfg.LLM)
+	if err != nil {
+		return nil, err
+	}
+
+	broker, err := realtime.NewBroker(cfg.Realtime.Broker, db, cfg.Database.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &App{
+		DB:             db,
+		Repositories:   repository.NewPostgres(db),
+		Vectors:        vectors,
+		LLM:            provider,
+		Config:         cfg,
+		Keys:           keys,
+		Logger:         slog.Default(),
+		Generations:    lifecycle.NewGenerations(),
+		Broker:         broker,
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		Mailer:         mail,
+		Plans:          plans,
+		OIDC:           auth.NewOIDCProviders(cfg.OIDC),
+		Metrics:        metrics.New(),
+	}, nil
+}
+
+// newProvider picks the model backend named by LLM_PROVIDER.
+// With LLM_CASSETTE set, calls are recorded to that directory or replayed from it instead.
+func newProvider(ctx context.Context, cfg config.LLMConfig) (llm.Provider, error) {
+	if cfg.Cassette == "" {
+		return newBackend(ctx, cfg)
+	}
+
+	mode := llm.CassetteMode(cfg.CassetteMode)
+	if mode == llm.CassetteReplay {
+		slog.Warn("Replaying recorded model calls", "cassette", cfg.Cassette)
+		return llm.NewCassetteProvider(cfg.Cassette, llm.CassetteReplay, nil)
+	}
+
+	backend, err := newBackend(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.Warn("Recording model calls", "cassette", cfg.Cassette)
+	return llm.NewCassetteProvider(cfg.Cassette, mode, backend)
+}
+
+func newBackend(ctx context.Context, cfg config.LLMConfig) (llm.Provider, error) {
+	switch cfg.Provider {
+	case "gemini":
+		return llm.NewGeminiProvider(ctx, cfg.GeminiAPIKey)
+	case "fake":
+		var fixtures llm.FakeFixtures
+		if cfg.Fixtures != "" {
+			loaded, err := llm.LoadFakeFixtures(cfg.Fixtures)
+			if err != nil {
+				return nil, err
+			}
+			fixtures = loaded
+		}
+		slog.Warn("Using the fake model provider, replies are scripted", "fixtures", cfg.Fixtures)
+		return llm.NewFakeProvider(fixtures)
+	}
+	return nil, fmt.Errorf("unknown LLM_PROVIDER %q, expected gemini or fake", cfg.Provider)
+}
+
+// Close releases the rate limit store and the database, Qdrant and model clients, in that order.
+// It runs once the server has stopped serving, so nothing is still writing through them.
+func (a *App) Close() error {
+	var errs []error
+	if a.RateLimitStore != nil {
+		errs = append(errs, a.RateLimitStore.Close())
+	}
+	if a.DB != nil {
+		if sqlDB, err := a.DB.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+	}
+	if a.Vectors != nil {
+		errs = append(errs, a.Vectors.Close())
+	}
+	if a.LLM != nil {
+		errs = append(errs, a.LLM.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/utils"
+)
+
+const (
+	API_KEY_PREFIX        = "trio_"
+	API_KEY_DISPLAY_CHARS = 12
+	// last_used_at is written at most this often per key to avoid a write on every request
+	API_KEY_USAGE_RESOLUTION = time.Minute
+)
+
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, API_KEY_PREFIX)
+}
+
+// CreateAPIKey stores a new key and returns it with its plaintext value, which is never shown again
+func CreateAPIKey(ctx context.Context, apiKeys repository.APIKeyRepository, user models.User, name string, scopes []Scope, expiresAt *time.Time) (models.APIKey, string, error) {
+	secret, err := utils.GenerateToken(utils.DEFAULT_TOKEN_BYTES)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	plaintext := API_KEY_PREFIX + secret
+
+	grantedScopes := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		grantedScopes = append(grantedScopes, string(scope))
+	}
+
+	key := models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    plaintext[:API_KEY_DISPLAY_CHARS],
+		KeyHash:   utils.HashToken(plaintext),
+		Scopes:    grantedScopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := apiKeys.Create(ctx, &key); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+// AuthenticateAPIKey resolves the key and its owner, recording when it was last used
+func AuthenticateAPIKey(ctx context.Context, apiKeys repository.APIKeyRepository, plaintext string) (models.APIKey, models.User, error) {
+	key, err := apiKeys.FindByHash(ctx, utils.HashToken(plaintext))
+	if err != nil {
+		return models.APIKey{}, models.User{}, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if !key.IsActive(now) || key.User.ID == 0 {
+		return models.APIKey{}, models.User{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > API_KEY_USAGE_RESOLUTION {
+		apiKeys.TouchLastUsed(ctx, key.ID, now)
+		key.LastUsedAt = &now
+	}
+
+	return key, key.User, nil
+}
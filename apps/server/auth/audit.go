@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+// RecordAuditEvent stores an audit entry for the request. Failures are logged so auditing never blocks the action itself.
+func RecordAuditEvent(c *gin.Context, audit repository.AuditRepository, action models.AuditAction, userID *uint, details string) {
+	event := models.AuditEvent{
+		Action:    action,
+		UserID:    userID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Details:   details,
+	}
+	if actor, ok := c.Value("currentUser").(models.User); ok {
+		event.ActorID = &actor.ID
+	}
+	if err := audit.Create(c, &event); err != nil {
+		slog.Error("Failed to record audit event", "action", action, "error", err)
+	}
+	slog.Warn("Audit event", "action", action, "ip", event.IPAddress, "details", details)
+}
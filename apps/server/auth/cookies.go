@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ACCESS_TOKEN_COOKIE  = "Access_Token"
+	REFRESH_TOKEN_COOKIE = "Refresh_Token"
+)
+
+// SetAuthCookies scopes the cookies to the configured DOMAIN
+func SetAuthCookies(c *gin.Context, domain string, tokens IssuedTokens) {
+	c.SetCookie(ACCESS_TOKEN_COOKIE, tokens.AccessToken, int(ACCESS_TOKEN_TTL.Seconds()), "/", domain, false, true)
+	// Requests that raced a rotation only receive a new access token
+	if tokens.RefreshToken != "" {
+		c.SetCookie(REFRESH_TOKEN_COOKIE, tokens.RefreshToken, int(REFRESH_TOKEN_TTL.Seconds()), "/", domain, false, true)
+	}
+}
+
+func ClearAuthCookies(c *gin.Context, domain string) {
+	c.SetCookie(ACCESS_TOKEN_COOKIE, "", -1, "/", domain, false, true)
+	c.SetCookie(REFRESH_TOKEN_COOKIE, "", -1, "/", domain, false, true)
+}
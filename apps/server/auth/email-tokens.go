@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/somtojf/trio/mailer"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	VERIFY_EMAIL_TOKEN_TTL   = 24 * time.Hour
+	PASSWORD_RESET_TOKEN_TTL = time.Hour
+)
+
+var (
+	ErrInvalidUserToken = errors.New("this link is invalid or has expired")
+	ErrEmailTaken       = errors.New("email is already in use")
+)
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// issueUserToken creates a token for the purpose, invalidating any earlier unused one
+func issueUserToken(ctx context.Context, tokens repository.UserTokenRepository, user models.User, purpose models.UserTokenPurpose, email string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateToken(utils.DEFAULT_TOKEN_BYTES)
+	if err != nil {
+		return "", err
+	}
+
+	err = tokens.Issue(ctx, &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken marks the token used and applies its change to the user, mapping any unusable token to ErrInvalidUserToken
+func consumeUserToken(ctx context.Context, tokens repository.UserTokenRepository, purpose models.UserTokenPurpose, token string, apply repository.UserTokenApply) (models.User, error) {
+	user, err := tokens.Consume(ctx, utils.HashToken(token), purpose, time.Now().UTC(), apply)
+	if errors.Is(err, repository.ErrUserTokenNotFound) {
+		return models.User{}, ErrInvalidUserToken
+	}
+	return user, err
+}
+
+// SendVerificationEmail emails the user a link to the client at clientAddress confirming they own their address
+func SendVerificationEmail(ctx context.Context, tokens repository.UserTokenRepository, mail mailer.Mailer, clientAddress string, user models.User) error {
+	if user.Email == nil || *user.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	token, err := issueUserToken(ctx, tokens, user, models.UserTokenVerifyEmail, *user.Email, VERIFY_EMAIL_TOKEN_TTL)
+	if err != nil {
+		return err
+	}
+
+	link := clientAddress + "/verify-email?" + url.Values{"token": {token}}.Encode()
+	mailer.SendAsync(mail, mailer.Message{
+		To:      *user.Email,
+		Subject: "Verify your Trio email address",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nConfirm this is your email address by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not create a Trio account you can ignore this email.\n",
+			user.FullName, link, formatTTL(VERIFY_EMAIL_TOKEN_TTL),
+		),
+	})
+
+	return nil
+}
+
+// SendPasswordResetEmail emails the user a single-use link to the client at clientAddress for choosing a new password
+func SendPasswordResetEmail(ctx context.Context, tokens repository.UserTokenRepository, mail mailer.Mailer, clientAddress string, user models.User) error {
+	if user.Email == nil || *user.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	token, err := issueUserToken(ctx, tokens, user, models.UserTokenResetPassword, *user.Email, PASSWORD_RESET_TOKEN_TTL)
+	if err != nil {
+		return err
+	}
+
+	link := clientAddress + "/reset-password?" + url.Values{"token": {token}}.Encode()
+	mailer.SendAsync(mail, mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your Trio password",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your Trio account (%s). Choose a new password here:\n\n%s\n\nThe link expires in %s and can only be used once. If this wasn't you, you can ignore this email.\n",
+			user.FullName, user.Username, link, formatTTL(PASSWORD_RESET_TOKEN_TTL),
+		),
+	})
+
+	return nil
+}
+
+// VerifyEmail confirms the address the token was sent to, if it is still the user's address
+func VerifyEmail(ctx context.Context, tokens repository.UserTokenRepository, token string) (models.User, error) {
+	return consumeUserToken(ctx, tokens, models.UserTokenVerifyEmail, token, func(userToken models.UserToken, user *models.User) ([]string, error) {
+		if user.Email == nil || *user.Email != userToken.Email {
+			return nil, ErrInvalidUserToken
+		}
+
+		now := time.Now().UTC()
+		user.EmailVerifiedAt = &now
+		return []string{"email_verified_at"}, nil
+	})
+}
+
+// ResetPasswordWithToken sets a new password and logs the user out everywhere.
+// Receiving the email also proves the address, so it is marked verified.
+func ResetPasswordWithToken(ctx context.Context, repos repository.Repositories, token string, password string) (models.User, error) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := consumeUserToken(ctx, repos.UserTokens, models.UserTokenResetPassword, token, func(userToken models.UserToken, user *models.User) ([]string, error) {
+		user.PasswordHash = string(passwordHash)
+		columns := []string{"password_hash"}
+		if user.Email != nil && *user.Email == userToken.Email && user.EmailVerifiedAt == nil {
+			now := time.Now().UTC()
+			user.EmailVerifiedAt = &now
+			columns = append(columns, "email_verified_at")
+		}
+		return columns, nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := RevokeAllSessions(ctx, repos.Sessions, user.ID, 0); err != nil {
+		return user, err
+	}
+
+	// Proving ownership of the email lifts any lockout on the account
+	if err := ClearLoginFailures(ctx, repos.LoginThrottles, user.Username); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// ChangeEmail replaces the user's address, which must then be verified again
+func ChangeEmail(ctx context.Context, users repository.UserRepository, user models.User, email string) (models.User, error) {
+	email = NormalizeEmail(email)
+	if user.Email != nil && *user.Email == email {
+		return user, nil
+	}
+
+	taken, err := users.EmailTaken(ctx, email, user.ID)
+	if err != nil {
+		return user, err
+	}
+	if taken {
+		return user, ErrEmailTaken
+	}
+
+	user.Email = &email
+	user.EmailVerifiedAt = nil
+	if err := users.Update(ctx, &user, "email", "email_verified_at"); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+func formatTTL(ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		hours := int(ttl / time.Hour)
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return ttl.String()
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+const MAX_USERNAME_LENGTH = 20
+
+var (
+	ErrIdentityLinkedElsewhere = errors.New("this sso account is already linked to another user")
+	ErrLinkUserNotFound        = errors.New("the account being linked no longer exists")
+
+	usernameDisallowed = regexp.MustCompile(`[^a-z0-9_.-]+`)
+)
+
+// ResolveOIDCUser finds the user behind an SSO login. A flow started from the link
+// endpoint attaches the identity to that user; otherwise a known identity logs its
+// user in and an unknown one provisions a new user just in time.
+func ResolveOIDCUser(ctx context.Context, repos repository.Repositories, provider *OIDCProvider, claims OIDCClaims, flow OIDCFlow) (models.User, error) {
+	identity, err := repos.Identities.FindBySubject(ctx, provider.Config.Issuer, claims.Subject)
+	if err != nil && !errors.Is(err, repository.ErrIdentityNotFound) {
+		return models.User{}, err
+	}
+	found := err == nil
+
+	if flow.LinkUserID != "" {
+		linkID, err := uuid.Parse(flow.LinkUserID)
+		if err != nil {
+			return models.User{}, ErrLinkUserNotFound
+		}
+		user, err := repos.Users.FindByExternalID(ctx, linkID)
+		if err != nil {
+			return models.User{}, ErrLinkUserNotFound
+		}
+		if found {
+			if identity.UserID != user.ID {
+				return models.User{}, ErrIdentityLinkedElsewhere
+			}
+			return user, nil
+		}
+		newIdentity := newUserIdentity(provider, claims)
+		newIdentity.UserID = user.ID
+		return user, repos.Identities.Create(ctx, &newIdentity)
+	}
+
+	if found && identity.User.ID != 0 {
+		if claims.Email != "" && claims.Email != identity.Email {
+			if err := repos.Identities.UpdateEmail(ctx, &identity, claims.Email); err != nil {
+				return models.User{}, err
+			}
+		}
+		return identity.User, nil
+	}
+
+	username, err := uniqueUsername(ctx, repos.Users, claims)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		Username: username,
+		FullName: claims.Name,
+	}
+	if user.FullName == "" {
+		user.FullName = username
+	}
+	if email := NormalizeEmail(claims.Email); email != "" && claims.EmailVerified {
+		taken, err := repos.Users.EmailTaken(ctx, email, 0)
+		if err != nil {
+			return models.User{}, err
+		}
+		// The provider vouches for the address, so it starts out verified
+		if !taken {
+			now := time.Now().UTC()
+			user.Email = &email
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	// A stale identity whose user was deleted is replaced
+	var stale *models.UserIdentity
+	if found {
+		stale = &identity
+	}
+	newIdentity := newUserIdentity(provider, claims)
+	if err := repos.Identities.Provision(ctx, &user, &newIdentity, stale); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func newUserIdentity(provider *OIDCProvider, claims OIDCClaims) models.UserIdentity {
+	return models.UserIdentity{
+		Provider: provider.Config.Name,
+		Issuer:   provider.Config.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+}
+
+// uniqueUsername derives a username from the provider's claims, adding a numeric suffix when it is taken
+func uniqueUsername(ctx context.Context, users repository.UserRepository, claims OIDCClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > MAX_USERNAME_LENGTH {
+		base = base[:MAX_USERNAME_LENGTH]
+	}
+
+	candidate := base
+	for i := 1; i < 1000; i++ {
+		taken, err := users.UsernameTaken(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+
+		suffix := fmt.Sprintf("%d", i)
+		trimmed := base
+		if len(trimmed)+len(suffix) > MAX_USERNAME_LENGTH {
+			trimmed = trimmed[:MAX_USERNAME_LENGTH-len(suffix)]
+		}
+		candidate = trimmed + suffix
+	}
+
+	return "", errors.New("could not find a free username")
+}
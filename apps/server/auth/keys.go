@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/somtojf/trio/utils"
+)
+
+// ErrMissingSecret stops tokens from being signed, or secrets encrypted, with an empty key
+var ErrMissingSecret = errors.New("SECRET is not set")
+
+// Keys signs the JWTs the server issues and encrypts secrets stored at rest, such as TOTP secrets.
+// Both keys derive from the configured SECRET. The zero value refuses to do either.
+type Keys struct {
+	signing    []byte
+	encryption []byte
+}
+
+func NewKeys(secret string) (Keys, error) {
+	if secret == "" {
+		return Keys{}, ErrMissingSecret
+	}
+	encryption := sha256.Sum256([]byte("trio-secret-encryption:" + secret))
+	return Keys{signing: []byte(secret), encryption: encryption[:]}, nil
+}
+
+func (k Keys) sign(claims jwt.MapClaims) (string, error) {
+	if len(k.signing) == 0 {
+		return "", ErrMissingSecret
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.signing)
+}
+
+// parse verifies an HMAC-signed token, which must carry an expiry, and returns its claims
+func (k Keys) parse(tokenString string) (jwt.MapClaims, error) {
+	if len(k.signing) == 0 {
+		return nil, ErrMissingSecret
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return k.signing, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (k Keys) encrypt(plaintext string) (string, error) {
+	if len(k.encryption) == 0 {
+		return "", ErrMissingSecret
+	}
+	return utils.EncryptSecret(k.encryption, plaintext)
+}
+
+func (k Keys) decrypt(ciphertext string) (string, error) {
+	if len(k.encryption) == 0 {
+		return "", ErrMissingSecret
+	}
+	return utils.DecryptSecret(k.encryption, ciphertext)
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+)
+
+func TestZeroKeysRefuseToSignOrEncrypt(t *testing.T) {
+	if _, err := NewKeys(""); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("an empty SECRET should be rejected, got %v", err)
+	}
+
+	var keys Keys
+	if _, err := IssueAccessToken(keys, models.User{ExternalID: uuid.New()}, models.Session{ExternalID: uuid.New()}); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("signing without a secret should fail, got %v", err)
+	}
+	if _, err := keys.encrypt("totp-secret"); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("encrypting without a secret should fail, got %v", err)
+	}
+}
+
+func TestKeysAreBoundToTheSecret(t *testing.T) {
+	keys, _ := NewKeys("first-secret")
+	other, _ := NewKeys("second-secret")
+
+	token, err := IssueAccessToken(keys, models.User{ExternalID: uuid.New()}, models.Session{ExternalID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(keys, token); err != nil {
+		t.Fatalf("token should verify with its own keys: %v", err)
+	}
+	if _, err := ParseAccessToken(other, token); err == nil {
+		t.Fatal("token should not verify with another secret")
+	}
+	if _, err := ParseAccessToken(Keys{}, token); err == nil {
+		t.Fatal("token should not verify without a secret")
+	}
+
+	sealed, err := keys.encrypt("totp-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := keys.decrypt(sealed); err != nil || opened != "totp-secret" {
+		t.Fatalf("got %q, %v after a round trip", opened, err)
+	}
+	if _, err := other.decrypt(sealed); err == nil {
+		t.Fatal("another secret should not open the value")
+	}
+}
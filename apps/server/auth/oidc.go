@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/utils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	OIDC_FLOW_COOKIE = "OIDC_Flow"
+	OIDC_FLOW_TTL    = 10 * time.Minute
+)
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown sso provider")
+	ErrInvalidOIDCFlow     = errors.New("sso login expired or was tampered with, please try again")
+)
+
+// OIDCProviderConfig is built from config.OIDCProviderConfig for every enabled provider
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type OIDCProvider struct {
+	Config   OIDCProviderConfig
+	OAuth2   oauth2.Config
+	Verifier *oidc.IDTokenVerifier
+}
+
+// OIDCFlow carries state between the login redirect and the callback in a signed cookie
+type OIDCFlow struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string
+	// Set when a logged in user is linking another identity to their account
+	LinkUserID string
+}
+
+type OIDCClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+}
+
+// OIDCProviders holds the enabled providers and caches each one once it has been discovered
+type OIDCProviders struct {
+	mu         sync.Mutex
+	configs    map[string]OIDCProviderConfig
+	discovered map[string]*OIDCProvider
+}
+
+// NewOIDCProviders sets up the enabled providers, which config.Validate has already checked.
+// Discovery happens lazily so an unreachable issuer does not stop the server from booting.
+func NewOIDCProviders(cfg config.OIDCConfig) *OIDCProviders {
+	configs := make(map[string]OIDCProviderConfig)
+
+	for _, name := range cfg.Enabled {
+		provider := cfg.Providers[name]
+		config := OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  provider.DisplayName,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+		}
+		if config.DisplayName == "" {
+			config.DisplayName = name
+		}
+		configs[name] = config
+	}
+
+	return &OIDCProviders{configs: configs, discovered: make(map[string]*OIDCProvider)}
+}
+
+func (p *OIDCProviders) Configs() []OIDCProviderConfig {
+	configs := make([]OIDCProviderConfig, 0, len(p.configs))
+	for _, config := range p.configs {
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// Get discovers the provider on first use and caches it
+func (p *OIDCProviders) Get(ctx context.Context, name string) (*OIDCProvider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if provider, ok := p.discovered[name]; ok {
+		return provider, nil
+	}
+
+	config, ok := p.configs[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	discovered, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover sso provider %s: %w", name, err)
+	}
+
+	provider := &OIDCProvider{
+		Config: config,
+		OAuth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       config.Scopes,
+		},
+		Verifier: discovered.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}
+	p.discovered[name] = provider
+
+	return provider, nil
+}
+
+// AuthCodeURL starts an authorization code flow with PKCE and returns the redirect URL and the flow to remember
+func (p *OIDCProvider) AuthCodeURL(linkUserID string) (string, OIDCFlow, error) {
+	state, err := generateOIDCValue()
+	if err != nil {
+		return "", OIDCFlow{}, err
+	}
+	nonce, err := generateOIDCValue()
+	if err != nil {
+		return "", OIDCFlow{}, err
+	}
+
+	flow := OIDCFlow{
+		Provider:   p.Config.Name,
+		State:      state,
+		Nonce:      nonce,
+		Verifier:   oauth2.GenerateVerifier(),
+		LinkUserID: linkUserID,
+	}
+
+	url := p.OAuth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	return url, flow, nil
+}
+
+// Exchange redeems the authorization code and verifies the ID token against the flow's nonce
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, flow OIDCFlow) (OIDCClaims, error) {
+	token, err := p.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OIDCClaims{}, errors.New("provider did not return an id token")
+	}
+
+	idToken, err := p.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("failed to verify id token: %w", err)
+	}
+
+	var claims OIDCClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return OIDCClaims{}, fmt.Errorf("failed to read id token claims: %w", err)
+	}
+	if claims.Nonce != flow.Nonce {
+		return OIDCClaims{}, errors.New("id token nonce mismatch")
+	}
+	claims.Subject = idToken.Subject
+
+	return claims, nil
+}
+
+func generateOIDCValue() (string, error) {
+	return utils.GenerateToken(24)
+}
+
+// SignOIDCFlow serializes the flow into a short-lived JWT for the flow cookie
+func SignOIDCFlow(keys Keys, flow OIDCFlow) (string, error) {
+	return keys.sign(jwt.MapClaims{
+		"provider": flow.Provider,
+		"state":    flow.State,
+		"nonce":    flow.Nonce,
+		"verifier": flow.Verifier,
+		"link":     flow.LinkUserID,
+		"exp":      time.Now().Add(OIDC_FLOW_TTL).Unix(),
+	})
+}
+
+func ParseOIDCFlow(keys Keys, tokenString string) (OIDCFlow, error) {
+	claims, err := keys.parse(tokenString)
+	if err != nil {
+		return OIDCFlow{}, ErrInvalidOIDCFlow
+	}
+
+	flow := OIDCFlow{}
+	flow.Provider, _ = claims["provider"].(string)
+	flow.State, _ = claims["state"].(string)
+	flow.Nonce, _ = claims["nonce"].(string)
+	flow.Verifier, _ = claims["verifier"].(string)
+	flow.LinkUserID, _ = claims["link"].(string)
+	if flow.State == "" || flow.Verifier == "" {
+		return OIDCFlow{}, ErrInvalidOIDCFlow
+	}
+
+	return flow, nil
+}
@@ -0,0 +1,21 @@
+package auth
+
+type Scope string
+
+const (
+	ScopeChatsRead   Scope = "chats:read"
+	ScopeChatsWrite  Scope = "chats:write"
+	ScopeAgentsWrite Scope = "agents:write"
+	ScopeCompletions Scope = "completions"
+)
+
+var AllScopes = []Scope{ScopeChatsRead, ScopeChatsWrite, ScopeAgentsWrite, ScopeCompletions}
+
+// IsValid checks if the Scope is valid
+func (s Scope) IsValid() bool {
+	switch s {
+	case ScopeChatsRead, ScopeChatsWrite, ScopeAgentsWrite, ScopeCompletions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/utils"
+)
+
+var (
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrSessionRevoked   = errors.New("session revoked or expired")
+	ErrAccountSuspended = errors.New("this account has been suspended")
+	// A rotated-out refresh token was presented again, so the session is assumed stolen
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+type IssuedTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      models.Session
+}
+
+// StartSession records a new login for the request's device and issues its first token pair
+func StartSession(c *gin.Context, sessions repository.SessionRepository, keys Keys, user models.User) (IssuedTokens, error) {
+	if user.IsSuspended() {
+		return IssuedTokens{}, ErrAccountSuspended
+	}
+
+	refreshToken, err := utils.GenerateToken(utils.DEFAULT_TOKEN_BYTES)
+	if err != nil {
+		return IssuedTokens{}, err
+	}
+
+	now := time.Now().UTC()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IPAddress:        c.ClientIP(),
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(REFRESH_TOKEN_TTL),
+	}
+	if err := sessions.Create(c, &session); err != nil {
+		return IssuedTokens{}, err
+	}
+
+	accessToken, err := IssueAccessToken(keys, user, session)
+	if err != nil {
+		return IssuedTokens{}, err
+	}
+
+	return IssuedTokens{AccessToken: accessToken, RefreshToken: refreshToken, Session: session}, nil
+}
+
+// RotateSession exchanges a refresh token for a new token pair
+func RotateSession(c *gin.Context, sessions repository.SessionRepository, keys Keys, refreshToken string) (IssuedTokens, models.User, error) {
+	return rotateSessionAt(c, sessions, keys, refreshToken, time.Now().UTC())
+}
+
+func rotateSessionAt(c *gin.Context, sessions repository.SessionRepository, keys Keys, refreshToken string, now time.Time) (IssuedTokens, models.User, error) {
+	hash := utils.HashToken(refreshToken)
+
+	session, err := sessions.FindByRefreshTokenHash(c, hash)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return replayRotatedToken(c, sessions, keys, hash, now)
+	}
+	if err != nil {
+		return IssuedTokens{}, models.User{}, err
+	}
+
+	if !session.IsActive(now) || session.User.ID == 0 {
+		return IssuedTokens{}, models.User{}, ErrSessionRevoked
+	}
+
+	nextRefreshToken, err := utils.GenerateToken(utils.DEFAULT_TOKEN_BYTES)
+	if err != nil {
+		return IssuedTokens{}, models.User{}, err
+	}
+
+	// Only the request holding the current token can rotate it
+	rotated, err := sessions.Rotate(c, session.ID, hash, repository.SessionRotation{
+		RefreshTokenHash: utils.HashToken(nextRefreshToken),
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(REFRESH_TOKEN_TTL),
+		UserAgent:        c.Request.UserAgent(),
+		IPAddress:        c.ClientIP(),
+	})
+	if err != nil {
+		return IssuedTokens{}, models.User{}, err
+	}
+	if !rotated {
+		return IssuedTokens{}, models.User{}, ErrSessionRevoked
+	}
+
+	accessToken, err := IssueAccessToken(keys, session.User, session)
+	if err != nil {
+		return IssuedTokens{}, models.User{}, err
+	}
+
+	return IssuedTokens{AccessToken: accessToken, RefreshToken: nextRefreshToken, Session: session}, session.User, nil
+}
+
+// replayRotatedToken handles a refresh token that has already been rotated out. Parallel requests
+// racing the same rotation get an access token; any later replay revokes the session.
+func replayRotatedToken(ctx context.Context, sessions repository.SessionRepository, keys Keys, hash string, now time.Time) (IssuedTokens, models.User, error) {
+	session, err := sessions.FindByPreviousTokenHash(ctx, hash)
+	if err != nil {
+		return IssuedTokens{}, models.User{}, ErrSessionNotFound
+	}
+
+	if now.Sub(session.LastUsedAt) > REFRESH_REUSE_GRACE {
+		RevokeSession(ctx, sessions, session)
+		return IssuedTokens{}, models.User{}, ErrRefreshTokenReused
+	}
+	if !session.IsActive(now) || session.User.ID == 0 {
+		return IssuedTokens{}, models.User{}, ErrSessionRevoked
+	}
+
+	accessToken, err := IssueAccessToken(keys, session.User, session)
+	if err != nil {
+		return IssuedTokens{}, models.User{}, err
+	}
+	return IssuedTokens{AccessToken: accessToken, Session: session}, session.User, nil
+}
+
+// FindActiveSession loads the session an access token was issued for
+func FindActiveSession(ctx context.Context, sessions repository.SessionRepository, sessionID string) (models.Session, error) {
+	externalID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return models.Session{}, ErrSessionNotFound
+	}
+
+	session, err := sessions.FindByExternalID(ctx, externalID)
+	if err != nil {
+		return models.Session{}, ErrSessionNotFound
+	}
+	if !session.IsActive(time.Now()) {
+		return models.Session{}, ErrSessionRevoked
+	}
+	return session, nil
+}
+
+func RevokeSession(ctx context.Context, sessions repository.SessionRepository, session models.Session) error {
+	return sessions.Revoke(ctx, session.ID)
+}
+
+// RevokeAllSessions logs the user out everywhere, optionally keeping one session alive
+func RevokeAllSessions(ctx context.Context, sessions repository.SessionRepository, userID uint, exceptSessionID uint) error {
+	return sessions.RevokeAll(ctx, userID, exceptSessionID)
+}
+
+func FindSessionByRefreshToken(ctx context.Context, sessions repository.SessionRepository, refreshToken string) (models.Session, error) {
+	session, err := sessions.FindByRefreshTokenHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+func startTestSession(t *testing.T) (repository.Repositories, Keys, IssuedTokens) {
+	t.Helper()
+	repos := repository.NewMemory()
+	keys, err := NewKeys("sessions-test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Username: "session-user", FullName: "Session User"}
+	if err := repos.Users.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := StartSession(refreshContext(), repos.Sessions, keys, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repos, keys, tokens
+}
+
+func refreshContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/auth/refresh", nil)
+	return c
+}
+
+// checkAccessToken runs the same session lookup the auth middleware does for an access token's sid
+func checkAccessToken(t *testing.T, repos repository.Repositories, keys Keys, accessToken string) error {
+	t.Helper()
+	claims, err := ParseAccessToken(keys, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = FindActiveSession(context.Background(), repos.Sessions, claims.SessionID)
+	return err
+}
+
+func TestRotateSessionTwiceWithinGrace(t *testing.T) {
+	repos, keys, first := startTestSession(t)
+	now := time.Now().UTC()
+
+	rotated, _, err := rotateSessionAt(refreshContext(), repos.Sessions, keys, first.RefreshToken, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == first.RefreshToken {
+		t.Fatal("rotation should hand out a new refresh token")
+	}
+
+	// A parallel request racing the same rotation still gets an access token, but no refresh token of its own
+	raced, _, err := rotateSessionAt(refreshContext(), repos.Sessions, keys, first.RefreshToken, now.Add(REFRESH_REUSE_GRACE/2))
+	if err != nil {
+		t.Fatalf("replaying within the grace period should succeed, got %v", err)
+	}
+	if raced.RefreshToken != "" || raced.AccessToken == "" {
+		t.Fatalf("the racing request should only get an access token, got %+v", raced)
+	}
+	if err := checkAccessToken(t, repos, keys, raced.AccessToken); err != nil {
+		t.Fatalf("the session should survive a replay within the grace period, got %v", err)
+	}
+
+	// The token from the first rotation keeps the chain going
+	if _, _, err := rotateSessionAt(refreshContext(), repos.Sessions, keys, rotated.RefreshToken, now.Add(REFRESH_REUSE_GRACE/2)); err != nil {
+		t.Fatalf("the rotated refresh token should still work, got %v", err)
+	}
+}
+
+func TestReplayAfterGraceRevokesSession(t *testing.T) {
+	repos, keys, first := startTestSession(t)
+	now := time.Now().UTC()
+
+	rotated, _, err := rotateSessionAt(refreshContext(), repos.Sessions, keys, first.RefreshToken, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = rotateSessionAt(refreshContext(), repos.Sessions, keys, first.RefreshToken, now.Add(REFRESH_REUSE_GRACE+time.Second))
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replaying after the grace period should be treated as theft, got %v", err)
+	}
+
+	// Both the attacker's and the legitimate client's tokens die with the session
+	if _, _, err := rotateSessionAt(refreshContext(), repos.Sessions, keys, rotated.RefreshToken, now.Add(REFRESH_REUSE_GRACE+2*time.Second)); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("the current refresh token should stop working, got %v", err)
+	}
+	for _, accessToken := range []string{first.AccessToken, rotated.AccessToken} {
+		if err := checkAccessToken(t, repos, keys, accessToken); !errors.Is(err, ErrSessionRevoked) {
+			t.Fatalf("access tokens for a revoked session should be rejected, got %v", err)
+		}
+	}
+}
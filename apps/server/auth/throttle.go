@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Failures older than the window are forgotten
+	LOGIN_FAILURE_WINDOW = 15 * time.Minute
+	LOGIN_LOCKOUT        = 15 * time.Minute
+	MAX_LOGIN_DELAY      = time.Minute
+
+	ACCOUNT_DELAY_AFTER   = 3
+	ACCOUNT_LOCKOUT_AFTER = 10
+	IP_DELAY_AFTER        = 10
+	IP_LOCKOUT_AFTER      = 50
+)
+
+type throttlePolicy struct {
+	prefix       string
+	delayAfter   int
+	lockoutAfter int
+	auditAction  models.AuditAction
+}
+
+var (
+	accountPolicy = throttlePolicy{prefix: "user:", delayAfter: ACCOUNT_DELAY_AFTER, lockoutAfter: ACCOUNT_LOCKOUT_AFTER, auditAction: models.AuditLoginLockout}
+	ipPolicy      = throttlePolicy{prefix: "ip:", delayAfter: IP_DELAY_AFTER, lockoutAfter: IP_LOCKOUT_AFTER, auditAction: models.AuditLoginIPLockout}
+
+	// Compared against when the user does not exist or has no password, so every
+	// failed login costs one bcrypt comparison and takes the same time
+	dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("trio-dummy-password"), bcrypt.DefaultCost)
+)
+
+// ComparePassword checks the password in constant time with respect to whether the user exists
+func ComparePassword(user models.User, password string) bool {
+	hash := []byte(user.PasswordHash)
+	if user.ID == 0 || len(hash) == 0 {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+func accountThrottleKey(username string) string {
+	return accountPolicy.prefix + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipThrottleKey(ip string) string {
+	return ipPolicy.prefix + ip
+}
+
+// loginDelay is how long to wait after the latest failure, doubling with every failure past the threshold
+func (p throttlePolicy) loginDelay(failures int) time.Duration {
+	if failures < p.delayAfter {
+		return 0
+	}
+	delay := time.Second * time.Duration(math.Pow(2, float64(failures-p.delayAfter)))
+	if delay > MAX_LOGIN_DELAY || delay <= 0 {
+		return MAX_LOGIN_DELAY
+	}
+	return delay
+}
+
+func (p throttlePolicy) retryAfter(throttle models.LoginThrottle, now time.Time) time.Duration {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if now.Sub(throttle.LastFailureAt) > LOGIN_FAILURE_WINDOW {
+		return 0
+	}
+	if wait := throttle.LastFailureAt.Add(p.loginDelay(throttle.Failures)).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// LoginRetryAfter reports how long the IP or username must wait before trying again. Zero means go ahead.
+// Unknown usernames are throttled the same way so lockouts do not reveal which accounts exist.
+func LoginRetryAfter(ctx context.Context, throttles repository.LoginThrottleRepository, ip string, username string) (time.Duration, error) {
+	now := time.Now().UTC()
+
+	found, err := throttles.Find(ctx, []string{ipThrottleKey(ip), accountThrottleKey(username)})
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, throttle := range found {
+		policy := accountPolicy
+		if strings.HasPrefix(throttle.Key, ipPolicy.prefix) {
+			policy = ipPolicy
+		}
+		if retry := policy.retryAfter(throttle, now); retry > wait {
+			wait = retry
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordLoginFailure bumps the IP and username counters, locking either out once it crosses its threshold
+func RecordLoginFailure(c *gin.Context, repos repository.Repositories, username string, user models.User) error {
+	var userID *uint
+	if user.ID != 0 {
+		userID = &user.ID
+	}
+
+	if err := recordFailure(c, repos, ipPolicy, ipThrottleKey(c.ClientIP()), nil); err != nil {
+		return err
+	}
+	return recordFailure(c, repos, accountPolicy, accountThrottleKey(username), userID)
+}
+
+func recordFailure(c *gin.Context, repos repository.Repositories, policy throttlePolicy, key string, userID *uint) error {
+	now := time.Now().UTC()
+
+	throttle, err := repos.LoginThrottles.RecordFailure(c, key, now, now.Add(-LOGIN_FAILURE_WINDOW))
+	if err != nil {
+		return err
+	}
+
+	// Only the failure that crosses the threshold locks, so a lockout is not extended by further attempts
+	if throttle.Failures != policy.lockoutAfter {
+		return nil
+	}
+
+	lockedUntil := now.Add(LOGIN_LOCKOUT)
+	if err := repos.LoginThrottles.Lock(c, throttle.ID, lockedUntil); err != nil {
+		return err
+	}
+
+	RecordAuditEvent(c, repos.Audit, policy.auditAction, userID, fmt.Sprintf("%s locked until %s after %d failed logins", key, lockedUntil.Format(time.RFC3339), throttle.Failures))
+	return nil
+}
+
+// ClearLoginFailures forgets the username's failures after a successful login or password reset.
+// The IP counter is left to expire so one valid account cannot reset it.
+func ClearLoginFailures(ctx context.Context, throttles repository.LoginThrottleRepository, username string) error {
+	return throttles.Clear(ctx, accountThrottleKey(username))
+}
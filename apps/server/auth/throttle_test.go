@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func loginContext(ip string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/auth/login", nil)
+	c.Request.RemoteAddr = ip + ":40000"
+	return c
+}
+
+func accountThrottle(t *testing.T, repos repository.Repositories, username string) models.LoginThrottle {
+	t.Helper()
+	found, err := repos.LoginThrottles.Find(context.Background(), []string{accountThrottleKey(username)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) == 0 {
+		return models.LoginThrottle{}
+	}
+	return found[0]
+}
+
+func TestLoginDelayCurve(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{ACCOUNT_DELAY_AFTER - 1, 0},
+		{ACCOUNT_DELAY_AFTER, time.Second},
+		{ACCOUNT_DELAY_AFTER + 1, 2 * time.Second},
+		{ACCOUNT_DELAY_AFTER + 5, 32 * time.Second},
+		{ACCOUNT_DELAY_AFTER + 6, MAX_LOGIN_DELAY},
+		{ACCOUNT_DELAY_AFTER + 100, MAX_LOGIN_DELAY},
+	} {
+		if got := accountPolicy.loginDelay(tc.failures); got != tc.want {
+			t.Errorf("loginDelay(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	lockedUntil := now.Add(5 * time.Minute)
+
+	for _, tc := range []struct {
+		name     string
+		throttle models.LoginThrottle
+		want     time.Duration
+	}{
+		{"below the threshold", models.LoginThrottle{Failures: 2, LastFailureAt: now}, 0},
+		{"delayed", models.LoginThrottle{Failures: 4, LastFailureAt: now.Add(-500 * time.Millisecond)}, 1500 * time.Millisecond},
+		{"delay served", models.LoginThrottle{Failures: 4, LastFailureAt: now.Add(-3 * time.Second)}, 0},
+		{"outside the window", models.LoginThrottle{Failures: 9, LastFailureAt: now.Add(-LOGIN_FAILURE_WINDOW - time.Second)}, 0},
+		{"locked", models.LoginThrottle{Failures: 10, LastFailureAt: now, LockedUntil: &lockedUntil}, 5 * time.Minute},
+	} {
+		if got := accountPolicy.retryAfter(tc.throttle, now); got != tc.want {
+			t.Errorf("%s: retryAfter = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLockoutExactlyAtThreshold(t *testing.T) {
+	repos := repository.NewMemory()
+	user := models.User{Username: "alice"}
+	user.ID = 1
+
+	for i := 1; i < ACCOUNT_LOCKOUT_AFTER; i++ {
+		if err := RecordLoginFailure(loginContext("198.51.100.1"), repos, "alice", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if throttle := accountThrottle(t, repos, "alice"); throttle.LockedUntil != nil {
+		t.Fatalf("locked after %d failures, before the threshold", throttle.Failures)
+	}
+
+	if err := RecordLoginFailure(loginContext("198.51.100.1"), repos, "alice", user); err != nil {
+		t.Fatal(err)
+	}
+	locked := accountThrottle(t, repos, "alice")
+	if locked.Failures != ACCOUNT_LOCKOUT_AFTER || locked.LockedUntil == nil {
+		t.Fatalf("expected a lockout at failure %d, got %+v", ACCOUNT_LOCKOUT_AFTER, locked)
+	}
+
+	// Further attempts during the lockout do not extend it
+	if err := RecordLoginFailure(loginContext("198.51.100.1"), repos, "alice", user); err != nil {
+		t.Fatal(err)
+	}
+	if again := accountThrottle(t, repos, "alice"); !again.LockedUntil.Equal(*locked.LockedUntil) {
+		t.Fatalf("lockout moved from %s to %s", locked.LockedUntil, again.LockedUntil)
+	}
+
+	wait, err := LoginRetryAfter(context.Background(), repos.LoginThrottles, "203.0.113.9", "Alice ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait < LOGIN_LOCKOUT-time.Minute || wait > LOGIN_LOCKOUT {
+		t.Fatalf("a locked account should wait out the lockout from any IP, got %s", wait)
+	}
+}
+
+func TestClearLoginFailuresResetsTheAccountOnly(t *testing.T) {
+	repos := repository.NewMemory()
+	ctx := context.Background()
+
+	for i := 0; i < IP_DELAY_AFTER; i++ {
+		if err := RecordLoginFailure(loginContext("198.51.100.2"), repos, "bob", models.User{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ClearLoginFailures(ctx, repos.LoginThrottles, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if throttle := accountThrottle(t, repos, "bob"); throttle.Failures != 0 {
+		t.Fatalf("account counter should be cleared, got %d failures", throttle.Failures)
+	}
+	if wait, _ := LoginRetryAfter(ctx, repos.LoginThrottles, "203.0.113.9", "bob"); wait != 0 {
+		t.Fatalf("cleared account should not wait from a fresh IP, got %s", wait)
+	}
+	if wait, _ := LoginRetryAfter(ctx, repos.LoginThrottles, "198.51.100.2", "bob"); wait == 0 {
+		t.Fatal("the IP counter should survive a successful login")
+	}
+}
+
+func TestUnknownUsersAreThrottledAndCompared(t *testing.T) {
+	repos := repository.NewMemory()
+
+	if ComparePassword(models.User{}, "anything") {
+		t.Fatal("an unknown user must never match")
+	}
+	withoutPassword := models.User{}
+	withoutPassword.ID = 7
+	if ComparePassword(withoutPassword, "") {
+		t.Fatal("a user without a password must never match")
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	known := models.User{PasswordHash: string(hash)}
+	known.ID = 8
+	if !ComparePassword(known, "correct horse") || ComparePassword(known, "wrong") {
+		t.Fatal("ComparePassword should only accept the user's password")
+	}
+
+	for i := 0; i < ACCOUNT_DELAY_AFTER; i++ {
+		if err := RecordLoginFailure(loginContext("198.51.100.3"), repos, "nobody", models.User{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := LoginRetryAfter(context.Background(), repos.LoginThrottles, "203.0.113.9", "nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Fatalf("unknown usernames should be delayed like real ones, got %s", wait)
+	}
+}
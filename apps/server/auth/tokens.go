@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/somtojf/trio/models"
+)
+
+const (
+	ACCESS_TOKEN_TTL  = 15 * time.Minute
+	REFRESH_TOKEN_TTL = 7 * 24 * time.Hour
+	// How long a rotated-out refresh token is tolerated for requests that raced the rotation
+	REFRESH_REUSE_GRACE = 30 * time.Second
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type AccessClaims struct {
+	UserID    string
+	Username  string
+	SessionID string
+}
+
+// IssueAccessToken signs a short-lived JWT bound to a session
+func IssueAccessToken(keys Keys, user models.User, session models.Session) (string, error) {
+	return keys.sign(jwt.MapClaims{
+		"id":       user.ExternalID.String(),
+		"username": user.Username,
+		"sid":      session.ExternalID.String(),
+		"exp":      time.Now().Add(ACCESS_TOKEN_TTL).Unix(),
+	})
+}
+
+func ParseAccessToken(keys Keys, tokenString string) (AccessClaims, error) {
+	claims, err := keys.parse(tokenString)
+	if err != nil {
+		return AccessClaims{}, ErrInvalidToken
+	}
+
+	userID, _ := claims["id"].(string)
+	username, _ := claims["username"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userID == "" || sessionID == "" {
+		return AccessClaims{}, ErrInvalidToken
+	}
+
+	return AccessClaims{UserID: userID, Username: username, SessionID: sessionID}, nil
+}
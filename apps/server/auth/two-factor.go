@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/utils"
+)
+
+const (
+	TOTP_ISSUER    = "Trio"
+	TOTP_PERIOD    = 30
+	TOTP_SKEW      = 1
+	PRE_AUTH_TTL   = 5 * time.Minute
+	RECOVERY_CODES = 10
+
+	preAuthTokenType = "pre_auth"
+	// Unambiguous characters for recovery codes, which people may have to type from paper
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrNoPendingEnrollment = errors.New("start two-factor enrollment first")
+	ErrInvalidSecondFactor = errors.New("invalid authentication code")
+)
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// otpauth:// provisioning URI to render as a QR code
+	URI string `json:"uri"`
+}
+
+// BeginTOTPEnrollment stores a new pending secret. It only takes effect once a code from it is confirmed.
+func BeginTOTPEnrollment(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user models.User) (TOTPEnrollment, error) {
+	if user.TwoFactorEnabledAt != nil {
+		return TOTPEnrollment{}, ErrTwoFactorEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TOTP_ISSUER,
+		AccountName: user.Username,
+		Period:      TOTP_PERIOD,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	encrypted, err := keys.encrypt(key.Secret())
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if err := twoFactor.BeginEnrollment(ctx, user.ID, encrypted); err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication and returns the first set of recovery codes
+func ConfirmTOTPEnrollment(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrNoPendingEnrollment
+	}
+
+	if err := verifyTOTP(ctx, twoFactor, keys, &user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := twoFactor.Enable(ctx, user.ID, time.Now().UTC(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes invalidates the old codes after checking a current second factor
+func RegenerateRecoveryCodes(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user models.User, code string) ([]string, error) {
+	if err := verifySecondFactor(ctx, twoFactor, keys, &user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := twoFactor.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func DisableTwoFactor(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user models.User, code string) error {
+	if err := verifySecondFactor(ctx, twoFactor, keys, &user, code); err != nil {
+		return err
+	}
+	return twoFactor.Disable(ctx, user.ID)
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery code
+func VerifySecondFactor(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user models.User, code string) error {
+	return verifySecondFactor(ctx, twoFactor, keys, &user, code)
+}
+
+func CountRecoveryCodes(ctx context.Context, twoFactor repository.TwoFactorRepository, user models.User) (int64, error) {
+	return twoFactor.CountRecoveryCodes(ctx, user.ID)
+}
+
+func verifySecondFactor(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user *models.User, code string) error {
+	if user.TwoFactorEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == int(otp.DigitsSix) {
+		return verifyTOTP(ctx, twoFactor, keys, user, code)
+	}
+	return useRecoveryCode(ctx, twoFactor, *user, code)
+}
+
+// verifyTOTP checks the code against the steps around now. The matched step is remembered so a code cannot be replayed.
+func verifyTOTP(ctx context.Context, twoFactor repository.TwoFactorRepository, keys Keys, user *models.User, code string) error {
+	secret, err := keys.decrypt(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	current := time.Now().Unix() / TOTP_PERIOD
+	for step := current - TOTP_SKEW; step <= current+TOTP_SKEW; step++ {
+		if step <= user.TOTPLastStep {
+			continue
+		}
+
+		valid, err := hotp.ValidateCustom(code, uint64(step), secret, hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+		if err != nil || !valid {
+			continue
+		}
+
+		advanced, err := twoFactor.AdvanceStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return ErrInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	return ErrInvalidSecondFactor
+}
+
+func useRecoveryCode(ctx context.Context, twoFactor repository.TwoFactorRepository, user models.User, code string) error {
+	used, err := twoFactor.UseRecoveryCode(ctx, user.ID, utils.HashToken(normalizeRecoveryCode(code)), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidSecondFactor
+	}
+	return nil
+}
+
+// generateRecoveryCodes returns a fresh set of codes to show once and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RECOVERY_CODES)
+	hashes := make([]string, 0, RECOVERY_CODES)
+	for i := 0; i < RECOVERY_CODES; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// generateRecoveryCode returns a code like "k7m2p-x9qr4"
+func generateRecoveryCode() (string, error) {
+	// Bytes past the largest multiple of the alphabet size are skipped to avoid modulo bias
+	limit := byte(256 / len(recoveryCodeAlphabet) * len(recoveryCodeAlphabet))
+
+	var builder strings.Builder
+	buffer := make([]byte, 1)
+	for written := 0; written < 10; {
+		if _, err := rand.Read(buffer); err != nil {
+			return "", err
+		}
+		if buffer[0] >= limit {
+			continue
+		}
+		if written == 5 {
+			builder.WriteByte('-')
+		}
+		builder.WriteByte(recoveryCodeAlphabet[int(buffer[0])%len(recoveryCodeAlphabet)])
+		written++
+	}
+	return builder.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", ""))
+}
+
+// IssuePreAuthToken proves the password was checked. It is only accepted by the second login step.
+func IssuePreAuthToken(keys Keys, user models.User) (string, error) {
+	return keys.sign(jwt.MapClaims{
+		"id":  user.ExternalID.String(),
+		"typ": preAuthTokenType,
+		"exp": time.Now().Add(PRE_AUTH_TTL).Unix(),
+	})
+}
+
+func ParsePreAuthToken(keys Keys, tokenString string) (string, error) {
+	claims, err := keys.parse(tokenString)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	userID, _ := claims["id"].(string)
+	tokenType, _ := claims["typ"].(string)
+	if userID == "" || tokenType != preAuthTokenType {
+		return "", ErrInvalidToken
+	}
+
+	return userID, nil
+}
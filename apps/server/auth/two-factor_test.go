@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+// enrollTwoFactor turns on two-factor authentication for a new user and returns its TOTP secret,
+// the step whose code confirmed the enrollment and the recovery codes
+func enrollTwoFactor(t *testing.T, repos repository.Repositories, keys Keys) (models.User, string, int64, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	user := models.User{Username: "totp-user", FullName: "TOTP User"}
+	if err := repos.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	enrollment, err := BeginTOTPEnrollment(ctx, repos.TwoFactor, keys, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err = repos.Users.FindByID(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	step := time.Now().Unix() / TOTP_PERIOD
+	codes, err := ConfirmTOTPEnrollment(ctx, repos.TwoFactor, keys, user, totpCode(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err = repos.Users.FindByID(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user, enrollment.Secret, step, codes
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTOTPCodesCannotBeReplayed(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	keys, _ := NewKeys("two-factor-test-secret")
+	user, secret, step, _ := enrollTwoFactor(t, repos, keys)
+
+	// The code confirming enrollment is spent
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, totpCode(t, secret, step)); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("the enrollment code should not log in again, got %v", err)
+	}
+
+	// A code from the next step, still inside the skew, works once
+	next := totpCode(t, secret, step+1)
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, next); err != nil {
+		t.Fatalf("a fresh code should be accepted, got %v", err)
+	}
+	// user still holds the old totp_last_step, so only the stored step stops the replay
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, next); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("a used code should be rejected, got %v", err)
+	}
+
+	stored, err := repos.Users.FindByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.TOTPLastStep != step+1 {
+		t.Fatalf("totp_last_step = %d, want %d", stored.TOTPLastStep, step+1)
+	}
+	// Earlier steps stay rejected even though they are inside the skew
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, stored, totpCode(t, secret, step-1)); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("a code older than the last used one should be rejected, got %v", err)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewMemory()
+	keys, _ := NewKeys("two-factor-test-secret")
+	user, _, _, codes := enrollTwoFactor(t, repos, keys)
+
+	if len(codes) != RECOVERY_CODES {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), RECOVERY_CODES)
+	}
+
+	// Codes are accepted however they were copied down
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, " "+strings.ToUpper(codes[0])+" "); err != nil {
+		t.Fatalf("an unused recovery code should be accepted, got %v", err)
+	}
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, codes[0]); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("a recovery code should only work once, got %v", err)
+	}
+
+	remaining, err := CountRecoveryCodes(ctx, repos.TwoFactor, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != RECOVERY_CODES-1 {
+		t.Fatalf("%d recovery codes left, want %d", remaining, RECOVERY_CODES-1)
+	}
+
+	// Regenerating invalidates every old code
+	fresh, err := RegenerateRecoveryCodes(ctx, repos.TwoFactor, keys, user, codes[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, codes[2]); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("codes from before regenerating should be rejected, got %v", err)
+	}
+	if err := VerifySecondFactor(ctx, repos.TwoFactor, keys, user, fresh[0]); err != nil {
+		t.Fatalf("a regenerated code should be accepted, got %v", err)
+	}
+}
+
+func TestPreAuthTokensOnlyWorkForTheSecondStep(t *testing.T) {
+	keys, _ := NewKeys("two-factor-test-secret")
+	user := models.User{ExternalID: uuid.New()}
+
+	preAuth, err := IssuePreAuthToken(keys, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := ParsePreAuthToken(keys, preAuth); err != nil || userID != user.ExternalID.String() {
+		t.Fatalf("the pre-auth token should name the user, got %q, %v", userID, err)
+	}
+	if _, err := ParseAccessToken(keys, preAuth); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("a pre-auth token must not work as an access token, got %v", err)
+	}
+
+	accessToken, err := IssueAccessToken(keys, user, models.Session{ExternalID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePreAuthToken(keys, accessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("an access token must not skip the second factor, got %v", err)
+	}
+}
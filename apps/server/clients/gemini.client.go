@@ -1,18 +0,0 @@
-package clients
-
-import (
-	"context"
-	"os"
-
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
-)
-
-func CreateGeminiClient(ctx context.Context) (*genai.Client, error) {
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("GEMINI_API_KEY")))
-	if err != nil {
-		return nil, err
-	}
-
-	return client, nil
-}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/initializers"
+	"github.com/somtojf/trio/migration"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: go run ./cmd/migrate <command>
+
+Commands:
+  up             apply every pending migration
+  down [steps]   revert the latest applied migrations, one by default
+  status         list migrations and when they were applied
+  create [-dir path] <name>
+                 add empty up and down files for a new migration, in
+                 migration/sql of this source tree unless -dir is given`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if os.Args[1] == "create" {
+		flags := flag.NewFlagSet("create", flag.ExitOnError)
+		dir := flags.String("dir", migration.SourceDir(), "directory holding the migration files")
+		flags.Parse(os.Args[2:])
+		if flags.NArg() == 0 {
+			log.Fatal("create needs a migration name")
+		}
+		upPath, downPath, err := migration.Create(*dir, strings.Join(flags.Args(), "_"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", upPath, downPath)
+		return
+	}
+
+	// Only the database URL is needed here, so the rest of the config is not validated
+	cfg, err := config.LoadUnvalidated()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.URL == "" {
+		log.Fatal("DATABASE_URL is required")
+	}
+	db, err := initializers.ConnectToDb(cfg.Database.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migration.New(sqlDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %06d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		promoteAdmins(db, cfg.AdminUsernames)
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", os.Args[2])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %06d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d_%-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
+
+// Promote the bootstrap admins, e.g. ADMIN_USERNAMES=alice,bob
+func promoteAdmins(db *gorm.DB, admins []string) {
+	if len(admins) == 0 {
+		return
+	}
+
+	if err := db.Model(&models.User{}).Where("username IN ?", admins).Update("role", models.UserRoleAdmin).Error; err != nil {
+		log.Fatal(err)
+	}
+}
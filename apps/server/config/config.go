@@ -0,0 +1,464 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/somtojf/trio/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Fields are filled from, in order of precedence, environment variables, a .env file,
+// the YAML file named by CONFIG_FILE, and the default tag. Fields tagged required must end up
+// non-empty, and fields tagged secret are redacted whenever the config is printed or logged.
+type Config struct {
+	Port          string `yaml:"port" env:"PORT" default:"8080"`
+	Secret        string `yaml:"secret" env:"SECRET" required:"true" secret:"true"`
+	ClientAddress string `yaml:"clientAddress" env:"CLIENT_ADDRESS" required:"true"`
+	Domain        string `yaml:"domain" env:"DOMAIN"`
+	// ShutdownTimeout is how long in-flight requests and generations get to finish after SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// TrustedProxies are the comma separated IPs or CIDRs whose X-Forwarded-For header is believed
+	// when working out a client's IP for rate limits and login throttling. None are trusted by default.
+	TrustedProxies []string `yaml:"trustedProxies" env:"TRUSTED_PROXIES"`
+	// AdminUsernames are promoted to admin by `cmd/migrate up`, e.g. ADMIN_USERNAMES=alice,bob
+	AdminUsernames []string `yaml:"adminUsernames" env:"ADMIN_USERNAMES"`
+
+	Database   DatabaseConfig  `yaml:"database"`
+	Qdrant     QdrantConfig    `yaml:"qdrant"`
+	LLM        LLMConfig       `yaml:"llm"`
+	RateLimits RateLimitConfig `yaml:"rateLimits"`
+	Chat       ChatConfig      `yaml:"chat"`
+	Metrics    MetricsConfig   `yaml:"metrics"`
+	Realtime   RealtimeConfig  `yaml:"realtime"`
+	Mail       MailConfig      `yaml:"mail"`
+	Quota      QuotaConfig     `yaml:"quota"`
+	OIDC       OIDCConfig      `yaml:"oidc"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" env:"DATABASE_URL" required:"true" secret:"true"`
+}
+
+type QdrantConfig struct {
+	Host string `yaml:"host" env:"QDRANT_HOST" default:"localhost"`
+	Port int    `yaml:"port" env:"QDRANT_PORT" default:"6334"`
+}
+
+type LLMConfig struct {
+	// Provider is "gemini" or "fake"
+	Provider     string `yaml:"provider" env:"LLM_PROVIDER" default:"gemini"`
+	GeminiAPIKey string `yaml:"geminiApiKey" env:"GEMINI_API_KEY" secret:"true"`
+	// Fixtures is the YAML file scripting the fake provider
+	Fixtures string `yaml:"fixtures" env:"LLM_FIXTURES"`
+	// Cassette is the directory model calls are recorded to or replayed from
+	Cassette string `yaml:"cassette" env:"LLM_CASSETTE"`
+	// CassetteMode is "record" or "replay", which never calls the real model
+	CassetteMode string      `yaml:"cassetteMode" env:"LLM_CASSETTE_MODE" default:"replay"`
+	Models       ModelConfig `yaml:"models"`
+}
+
+type ModelConfig struct {
+	Basic       string `yaml:"basic" env:"LLM_BASIC_MODEL" default:"gemini-1.5-flash"`
+	Reflection  string `yaml:"reflection" env:"LLM_REFLECTION_MODEL" default:"gemini-1.5-pro"`
+	Completions string `yaml:"completions" env:"LLM_COMPLETIONS_MODEL" default:"gemini-1.5-flash"`
+	Embedding   string `yaml:"embedding" env:"LLM_EMBEDDING_MODEL" default:"text-embedding-004"`
+}
+
+// RateLimitConfig holds each limit as "<requests>/<period>", such as "10/m", "500/h" or "5/30s"
+type RateLimitConfig struct {
+	Auth       string `yaml:"auth" env:"RATE_LIMIT_AUTH" default:"10/m"`
+	Generation string `yaml:"generation" env:"RATE_LIMIT_GENERATION" default:"20/m"`
+	Read       string `yaml:"read" env:"RATE_LIMIT_READ" default:"300/m"`
+	Write      string `yaml:"write" env:"RATE_LIMIT_WRITE" default:"60/m"`
+}
+
+type ChatConfig struct {
+	// MaxAgents caps the agents in a default chat. Reflection chats always have exactly two.
+	MaxAgents int `yaml:"maxAgents" env:"CHAT_MAX_AGENTS" default:"2"`
+	MaxTraits int `yaml:"maxTraits" env:"CHAT_MAX_AGENT_TRAITS" default:"4"`
+}
+
+// Without either setting /metrics is not served at all
+type MetricsConfig struct {
+	// Token serves /metrics on the public port to requests sending it as a bearer token
+	Token string `yaml:"token" env:"METRICS_TOKEN" secret:"true"`
+	// Address, such as 127.0.0.1:9090, serves /metrics without a token on a separate listener.
+	// It must only be reachable from the internal network.
+	Address string `yaml:"address" env:"METRICS_ADDRESS"`
+}
+
+type RealtimeConfig struct {
+	// Broker is "memory", which only reaches users connected to this process, or "postgres",
+	// which relays events between replicas with LISTEN/NOTIFY
+	Broker string `yaml:"broker" env:"REALTIME_BROKER" default:"memory"`
+}
+
+type MailConfig struct {
+	// Mailer is "console", which logs emails instead of sending them, or "smtp"
+	Mailer string     `yaml:"mailer" env:"MAILER" default:"console"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT" default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `yaml:"from" env:"MAIL_FROM"`
+}
+
+type QuotaConfig struct {
+	// Plans adds or replaces quota tiers. QUOTA_PLANS holds them as JSON, e.g.
+	// {"free": {"dailyTokens": 100000}, "team": {"monthlyTokens": 10000000}}
+	Plans map[string]QuotaPlan `yaml:"plans" env:"QUOTA_PLANS"`
+	// DefaultPlan applies to users without a plan of their own
+	DefaultPlan string `yaml:"defaultPlan" env:"QUOTA_DEFAULT_PLAN" default:"free"`
+}
+
+// QuotaPlan caps how much a user may use the models. A zero limit means unlimited.
+type QuotaPlan struct {
+	DailyRequests   int64 `yaml:"dailyRequests" json:"dailyRequests"`
+	MonthlyRequests int64 `yaml:"monthlyRequests" json:"monthlyRequests"`
+	DailyTokens     int64 `yaml:"dailyTokens" json:"dailyTokens"`
+	MonthlyTokens   int64 `yaml:"monthlyTokens" json:"monthlyTokens"`
+}
+
+type OIDCConfig struct {
+	// Enabled names the SSO providers offered at login. Each is configured under providers
+	// or by OIDC_<NAME>_* variables, such as OIDC_GOOGLE_CLIENT_ID.
+	Enabled   []string                      `yaml:"enabled" env:"OIDC_PROVIDERS"`
+	Providers map[string]OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig's env tags are suffixes of the provider's OIDC_<NAME>_ prefix
+type OIDCProviderConfig struct {
+	// DisplayName defaults to the provider's name
+	DisplayName  string `yaml:"displayName" env:"DISPLAY_NAME"`
+	Issuer       string `yaml:"issuer" env:"ISSUER" required:"true"`
+	ClientID     string `yaml:"clientId" env:"CLIENT_ID" required:"true"`
+	ClientSecret string `yaml:"clientSecret" env:"CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `yaml:"redirectUrl" env:"REDIRECT_URL" required:"true"`
+	// Scopes defaults to openid, profile and email
+	Scopes []string `yaml:"scopes" env:"SCOPES"`
+}
+
+const REDACTED = "[redacted]"
+
+// Defaults returns the config with only the default tags applied
+func Defaults() Config {
+	var c Config
+	walk(reflect.ValueOf(&c).Elem(), func(field reflect.StructField, value reflect.Value) error {
+		if def, ok := field.Tag.Lookup("default"); ok {
+			// Defaults are compile-time constants, so a bad one is a programming error
+			if err := setValue(value, def); err != nil {
+				panic(fmt.Sprintf("config: default for %s: %v", field.Name, err))
+			}
+		}
+		return nil
+	})
+	return c
+}
+
+// LoadDotEnv reads .env into the environment without overriding variables that are already set.
+// A missing file is not an error, since deployed containers get their environment directly.
+func LoadDotEnv() error {
+	err := godotenv.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("No .env file found, using the environment only")
+		return nil
+	}
+	return err
+}
+
+// Load builds the config from defaults, the optional CONFIG_FILE, the optional .env file and the environment
+func Load() (Config, error) {
+	c, err := LoadUnvalidated()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// LoadUnvalidated is Load without Validate, for tools such as cmd/migrate that only use part of
+// the config and check it themselves. Malformed values are still reported.
+func LoadUnvalidated() (Config, error) {
+	if err := LoadDotEnv(); err != nil {
+		return Config{}, fmt.Errorf("loading .env: %w", err)
+	}
+
+	c := Defaults()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return Config{}, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&c).Elem(), ""); err != nil {
+		return Config{}, err
+	}
+	if err := c.OIDC.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// applyEnv sets every field whose variable, named by prefix and the field's env tag, is non-empty
+func applyEnv(v reflect.Value, prefix string) error {
+	return walk(v, func(field reflect.StructField, value reflect.Value) error {
+		env := field.Tag.Get("env")
+		if env == "" {
+			return nil
+		}
+		raw := os.Getenv(prefix + env)
+		if raw == "" {
+			return nil
+		}
+		if err := setValue(value, raw); err != nil {
+			return fmt.Errorf("%s%s: %w", prefix, env, err)
+		}
+		return nil
+	})
+}
+
+// applyEnv lowercases the enabled names and fills each enabled provider from its OIDC_<NAME>_* variables
+func (o *OIDCConfig) applyEnv() error {
+	providers := make(map[string]OIDCProviderConfig, len(o.Providers))
+	for name, provider := range o.Providers {
+		providers[strings.ToLower(name)] = provider
+	}
+
+	for i, name := range o.Enabled {
+		name = strings.ToLower(name)
+		o.Enabled[i] = name
+
+		provider := providers[name]
+		if err := applyEnv(reflect.ValueOf(&provider).Elem(), oidcPrefix(name)); err != nil {
+			return err
+		}
+		providers[name] = provider
+	}
+
+	o.Providers = providers
+	return nil
+}
+
+func oidcPrefix(name string) string {
+	return "OIDC_" + strings.ToUpper(name) + "_"
+}
+
+// Validate reports every missing required field and invalid value at once
+func (c Config) Validate() error {
+	errs := missingRequired(reflect.ValueOf(&c).Elem(), "", "")
+	for _, name := range c.OIDC.Enabled {
+		provider := c.OIDC.Providers[name]
+		errs = append(errs, missingRequired(reflect.ValueOf(&provider).Elem(), oidcPrefix(name), " when OIDC_PROVIDERS includes "+name)...)
+	}
+
+	switch c.LLM.Provider {
+	case "gemini":
+		// Replaying a cassette never reaches Gemini, so it needs no key
+		if c.LLM.GeminiAPIKey == "" && (c.LLM.Cassette == "" || c.LLM.CassetteMode == "record") {
+			errs = append(errs, errors.New("GEMINI_API_KEY is required when LLM_PROVIDER is gemini"))
+		}
+	case "fake":
+	default:
+		errs = append(errs, fmt.Errorf("LLM_PROVIDER must be gemini or fake, got %q", c.LLM.Provider))
+	}
+
+	if c.LLM.CassetteMode != "record" && c.LLM.CassetteMode != "replay" {
+		errs = append(errs, fmt.Errorf("LLM_CASSETTE_MODE must be record or replay, got %q", c.LLM.CassetteMode))
+	}
+	if c.Qdrant.Port < 1 || c.Qdrant.Port > 65535 {
+		errs = append(errs, fmt.Errorf("QDRANT_PORT must be between 1 and 65535, got %d", c.Qdrant.Port))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES must hold IPs or CIDRs, got %q", proxy))
+			}
+		}
+	}
+	switch c.Realtime.Broker {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("REALTIME_BROKER must be memory or postgres, got %q", c.Realtime.Broker))
+	}
+	switch c.Mail.Mailer {
+	case "console":
+	case "smtp":
+		if c.Mail.SMTP.Host == "" || c.Mail.SMTP.From == "" {
+			errs = append(errs, errors.New("SMTP_HOST and MAIL_FROM are required when MAILER is smtp"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAILER must be console or smtp, got %q", c.Mail.Mailer))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT must be positive, got %s", c.ShutdownTimeout))
+	}
+	if c.Chat.MaxAgents < 2 {
+		errs = append(errs, fmt.Errorf("CHAT_MAX_AGENTS must be at least 2, got %d", c.Chat.MaxAgents))
+	}
+	if c.Chat.MaxTraits < 1 {
+		errs = append(errs, fmt.Errorf("CHAT_MAX_AGENT_TRAITS must be at least 1, got %d", c.Chat.MaxTraits))
+	}
+	if _, err := c.RateLimits.Limits(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// missingRequired reports every empty field tagged required, naming it by prefix and its env tag
+func missingRequired(v reflect.Value, prefix string, when string) []error {
+	var errs []error
+	walk(v, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s%s is required%s", prefix, field.Tag.Get("env"), when))
+		}
+		return nil
+	})
+	return errs
+}
+
+// Limits parses the configured rate limits
+func (r RateLimitConfig) Limits() (ratelimit.Limits, error) {
+	var limits ratelimit.Limits
+	var errs []error
+	for _, limit := range []struct {
+		env    string
+		name   string
+		value  string
+		target *ratelimit.Limit
+	}{
+		{"RATE_LIMIT_AUTH", "auth", r.Auth, &limits.Auth},
+		{"RATE_LIMIT_GENERATION", "generation", r.Generation, &limits.Generation},
+		{"RATE_LIMIT_READ", "read", r.Read, &limits.Read},
+		{"RATE_LIMIT_WRITE", "write", r.Write, &limits.Write},
+	} {
+		parsed, err := ratelimit.ParseLimit(limit.name, limit.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", limit.env, err))
+			continue
+		}
+		*limit.target = parsed
+	}
+	return limits, errors.Join(errs...)
+}
+
+// Redacted returns a copy with every non-empty secret replaced
+func (c Config) Redacted() Config {
+	redact(reflect.ValueOf(&c).Elem())
+
+	// The map is shared with the original, so the redacted providers go in a new one
+	if c.OIDC.Providers != nil {
+		providers := make(map[string]OIDCProviderConfig, len(c.OIDC.Providers))
+		for name, provider := range c.OIDC.Providers {
+			redact(reflect.ValueOf(&provider).Elem())
+			providers[name] = provider
+		}
+		c.OIDC.Providers = providers
+	}
+	return c
+}
+
+func redact(v reflect.Value) {
+	walk(v, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("secret") == "true" && !value.IsZero() {
+			value.SetString(REDACTED)
+		}
+		return nil
+	})
+}
+
+// String prints the redacted config as YAML
+func (c Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(data)
+}
+
+// LogValue keeps secrets out of logs when the config is passed to slog
+func (c Config) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
+
+// walk calls fn for every leaf field, descending into nested structs
+func walk(v reflect.Value, fn func(field reflect.StructField, value reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := walk(value, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 30s, got %q", raw)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Map:
+		if err := json.Unmarshal([]byte(raw), value.Addr().Interface()); err != nil {
+			return fmt.Errorf("expected a JSON object: %w", err)
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected a whole number, got %q", raw)
+		}
+		value.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported config field type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DEFAULT_ADMIN_PAGE_SIZE = 50
+	MAX_ADMIN_PAGE_SIZE     = 100
+	DEFAULT_USAGE_DAYS      = 30
+	MAX_USAGE_DAYS          = 365
+)
+
+type adminUpdateRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type adminUpdatePlanInput struct {
+	Plan string `json:"plan" binding:"required,max=50"`
+}
+
+type adminResetPasswordInput struct {
+	// When empty a reset link is emailed to the user instead
+	NewPassword string `json:"newPassword" binding:"omitempty,max=20,min=8"`
+}
+
+type userUsage struct {
+	repository.UsageTotals
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"userName"`
+	Messages int64     `json:"messages"`
+	Chats    int64     `json:"chats"`
+}
+
+// AdminListUsers godoc
+//	@Summary		List users
+//	@Description	Lists users, optionally filtered by username, name or email. Admin only.
+//	@Tags			admin
+//	@Param			q		query		string					false	"Search text"
+//	@Param			page	query		int						false	"Page number, starting at 1"
+//	@Param			limit	query		int						false	"Page size"
+//	@Success		200		{array}		models.User				"Users"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users [get]
+func (h *Handler) AdminListUsers(c *gin.Context) {
+	page, limit := pagination(c)
+
+	users, total, err := h.Users.List(c, strings.TrimSpace(c.Query("q")), (page-1)*limit, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users, "page": page, "limit": limit, "total": total})
+}
+
+// AdminGetUser godoc
+//	@Summary		Get a user
+//	@Description	Retrieves a user and their usage over the last days. Admin only.
+//	@Tags			admin
+//	@Param			userId	path		string					true	"User ID"
+//	@Param			days	query		int						false	"Usage window in days"
+//	@Success		200		{object}	map[string]interface{}	"User and usage"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404		{object}	map[string]interface{}	"User not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId} [get]
+func (h *Handler) AdminGetUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	since := usageSince(c)
+	usage, err := h.getUserUsage(c, user, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"user": user, "usage": usage, "since": since}})
+}
+
+// AdminUpdateUserRole godoc
+//	@Summary		Change a user's role
+//	@Description	Promotes a user to admin or demotes them. Admins cannot change their own role. Admin only.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId					path		string					true	"User ID"
+//	@Param			adminUpdateRoleInput	body		adminUpdateRoleInput	true	"New role"
+//	@Success		200						{object}	models.User				"Updated user"
+//	@Failure		400						{object}	map[string]interface{}	"Bad request"
+//	@Failure		403						{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404						{object}	map[string]interface{}	"User not found"
+//	@Failure		500						{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId}/role [put]
+func (h *Handler) AdminUpdateUserRole(c *gin.Context) {
+	var body adminUpdateRoleInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	previous := user.Role
+	user.Role = models.UserRole(body.Role)
+	if err := h.Users.Update(c, &user, "role"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	auth.RecordAuditEvent(c, h.Audit, models.AuditAdminRoleChanged, &user.ID, fmt.Sprintf("%s: %s -> %s", user.Username, previous, user.Role))
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// AdminUpdateUserPlan godoc
+//	@Summary		Change a user's plan
+//	@Description	Moves a user to another quota plan. Admin only.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId					path		string					true	"User ID"
+//	@Param			adminUpdatePlanInput	body		adminUpdatePlanInput	true	"Plan name"
+//	@Success		200						{object}	models.User				"Updated user"
+//	@Failure		400						{object}	map[string]interface{}	"Unknown plan"
+//	@Failure		403						{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404						{object}	map[string]interface{}	"User not found"
+//	@Failure		500						{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId}/plan [put]
+func (h *Handler) AdminUpdateUserPlan(c *gin.Context) {
+	var body adminUpdatePlanInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.Plans.Has(body.Plan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown plan"})
+		return
+	}
+
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	previous := h.Plans.Get(user.Plan).Name
+	user.Plan = body.Plan
+	if err := h.Users.Update(c, &user, "plan"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		return
+	}
+
+	auth.RecordAuditEvent(c, h.Audit, models.AuditAdminPlanChanged, &user.ID, fmt.Sprintf("%s: %s -> %s", user.Username, previous, user.Plan))
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// AdminGetPlans godoc
+//	@Summary		List quota plans
+//	@Description	Lists the configured quota plans and which one new users get. Admin only.
+//	@Tags			admin
+//	@Success		200	{object}	map[string]interface{}	"Plans"
+//	@Failure		403	{object}	map[string]interface{}	"Forbidden"
+//	@Router			/admin/plans [get]
+func (h *Handler) AdminGetPlans(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.Plans.List(), "defaultPlan": h.Plans.Default()})
+}
+
+// AdminSuspendUser godoc
+//	@Summary		Suspend a user
+//	@Description	Blocks a user from logging in or using the API and ends their sessions. Admin only.
+//	@Tags			admin
+//	@Param			userId	path		string					true	"User ID"
+//	@Success		200		{object}	models.User				"Suspended user"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404		{object}	map[string]interface{}	"User not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId}/suspend [post]
+func (h *Handler) AdminSuspendUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend yourself"})
+		return
+	}
+	if user.IsSuspended() {
+		c.JSON(http.StatusOK, gin.H{"data": user})
+		return
+	}
+
+	now := time.Now().UTC()
+	user.SuspendedAt = &now
+	if err := h.Users.Update(c, &user, "suspended_at"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+
+	if err := auth.RevokeAllSessions(c, h.Sessions, user.ID, 0); err != nil {
+		slog.Error("Failed to revoke sessions of suspended user", "userId", user.ExternalID, "error", err)
+	}
+
+	auth.RecordAuditEvent(c, h.Audit, models.AuditAdminUserSuspended, &user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// AdminUnsuspendUser godoc
+//	@Summary		Unsuspend a user
+//	@Description	Lets a suspended user log in again. Admin only.
+//	@Tags			admin
+//	@Param			userId	path		string					true	"User ID"
+//	@Success		200		{object}	models.User				"User"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404		{object}	map[string]interface{}	"User not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId}/unsuspend [post]
+func (h *Handler) AdminUnsuspendUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+	if !user.IsSuspended() {
+		c.JSON(http.StatusOK, gin.H{"data": user})
+		return
+	}
+
+	user.SuspendedAt = nil
+	if err := h.Users.Update(c, &user, "suspended_at"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsuspend user"})
+		return
+	}
+
+	auth.RecordAuditEvent(c, h.Audit, models.AuditAdminUserUnsuspended, &user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// AdminResetUserPassword godoc
+//	@Summary		Reset a user's password
+//	@Description	Sets a new password and ends the user's sessions, or emails them a reset link when no password is given. Admin only.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userId					path		string					true	"User ID"
+//	@Param			adminResetPasswordInput	body		adminResetPasswordInput	false	"New password"
+//	@Success		200						{object}	map[string]interface{}	"Password reset"
+//	@Failure		400						{object}	map[string]interface{}	"Bad request"
+//	@Failure		403						{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404						{object}	map[string]interface{}	"User not found"
+//	@Failure		500						{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/users/{userId}/reset-password [post]
+func (h *Handler) AdminResetUserPassword(c *gin.Context) {
+	var body adminResetPasswordInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	if body.NewPassword == "" {
+		if user.Email == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User has no email address, set a new password instead"})
+			return
+		}
+		if err := auth.SendPasswordResetEmail(c, h.UserTokens, h.Mailer, h.Config.ClientAddress, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+			return
+		}
+
+		auth.RecordAuditEvent(c, h.Audit, models.AuditAdminPasswordReset, &user.ID, user.Username+": reset link emailed")
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash new password"})
+		return
+	}
+	user.PasswordHash = string(passwordHash)
+	if err := h.Users.Update(c, &user, "password_hash"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := auth.RevokeAllSessions(c, h.Sessions, user.ID, 0); err != nil {
+		slog.Error("Failed to revoke sessions after admin password reset", "userId", user.ExternalID, "error", err)
+	}
+	if err := auth.ClearLoginFailures(c, h.LoginThrottles, user.Username); err != nil {
+		slog.Error("Failed to clear login failures", "error", err)
+	}
+
+	auth.RecordAuditEvent(c, h.Audit, models.AuditAdminPasswordReset, &user.ID, user.Username+": password set by admin")
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
+
+// AdminGetUsage godoc
+//	@Summary		Get usage
+//	@Description	Reports model usage across all users and the heaviest users over the last days. Admin only.
+//	@Tags			admin
+//	@Param			days	query		int						false	"Usage window in days"
+//	@Param			limit	query		int						false	"Number of users to include"
+//	@Success		200		{object}	map[string]interface{}	"Usage"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/admin/usage [get]
+func (h *Handler) AdminGetUsage(c *gin.Context) {
+	since := usageSince(c)
+	_, limit := pagination(c)
+
+	totals, err := h.Usage.Totals(c, since, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+
+	rows, err := h.Usage.TopUsers(c, since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+
+	userIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+	users, err := h.Users.FindByIDs(c, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+	usersByID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	topUsers := make([]userUsage, 0, len(rows))
+	for _, row := range rows {
+		user := usersByID[row.UserID]
+		topUsers = append(topUsers, userUsage{UsageTotals: row.UsageTotals, UserID: user.ExternalID, Username: user.Username})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"since": since, "totals": totals, "users": topUsers}})
+}
+
+func (h *Handler) findAdminTarget(c *gin.Context) (models.User, bool) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return models.User{}, false
+	}
+
+	user, err := h.Users.FindByExternalID(c, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve user"})
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+func isCurrentUser(c *gin.Context, user models.User) bool {
+	current, ok := c.Value("currentUser").(models.User)
+	return ok && current.ID == user.ID
+}
+
+func pagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DEFAULT_ADMIN_PAGE_SIZE)))
+	if err != nil || limit < 1 {
+		limit = DEFAULT_ADMIN_PAGE_SIZE
+	}
+	if limit > MAX_ADMIN_PAGE_SIZE {
+		limit = MAX_ADMIN_PAGE_SIZE
+	}
+	return page, limit
+}
+
+func usageSince(c *gin.Context) time.Time {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(DEFAULT_USAGE_DAYS)))
+	if err != nil || days < 1 {
+		days = DEFAULT_USAGE_DAYS
+	}
+	if days > MAX_USAGE_DAYS {
+		days = MAX_USAGE_DAYS
+	}
+	return time.Now().UTC().AddDate(0, 0, -days)
+}
+
+func (h *Handler) getUserUsage(ctx context.Context, user models.User, since time.Time) (userUsage, error) {
+	usage := userUsage{UserID: user.ExternalID, Username: user.Username}
+
+	var err error
+	if usage.UsageTotals, err = h.Usage.Totals(ctx, since, user.ID); err != nil {
+		return usage, err
+	}
+	if usage.Messages, err = h.Messages.CountSentBy(ctx, user.ID, since); err != nil {
+		return usage, err
+	}
+	if usage.Chats, err = h.Chats.CountOwnedBy(ctx, user.ID); err != nil {
+		return usage, err
+	}
+
+	return usage, nil
+}
@@ -5,9 +5,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/somtojf/trio/initializers"
 	"github.com/somtojf/trio/models"
-	"gorm.io/gorm"
+	"github.com/somtojf/trio/realtime"
 )
 
 type updateAgentInput struct {
@@ -28,7 +27,7 @@ type updateAgentInput struct {
 //	@Failure		404		{object}	map[string]interface{}	"Agent not found"
 //	@Failure		500		{object}	map[string]interface{}	"Internal server error"
 //	@Router			/agents/{agentId} [delete]
-func DeleteAgent(c *gin.Context) {
+func (h *Handler) DeleteAgent(c *gin.Context) {
 	agentID, err := uuid.Parse(c.Param("agentId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
@@ -42,23 +41,19 @@ func DeleteAgent(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var agent models.Agent
-	if err := initializers.DB.Joins("Chat").
-		Where("agents.external_id = ? AND chats.user_id = ?", agentID, userModel.ID).
-		First(&agent).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found or does not belong to the user"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
-		}
+	agent, err := h.Agents.FindForMember(c, agentID, userModel.ID, models.ChatRoleEditor)
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
-	if err := initializers.DB.Delete(&agent).Error; err != nil {
+	if err := h.Agents.Delete(c, agent); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete agent"})
 		return
 	}
 
+	realtime.PublishToChatByID(c, h.Broker, h.Chats, agent.ChatID, realtime.EventAgentDeleted, gin.H{"id": agent.ExternalID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Agent deleted successfully"})
 }
 
@@ -74,7 +69,7 @@ func DeleteAgent(c *gin.Context) {
 //	@Failure		404		{object}	map[string]interface{}	"Agent not found"
 //	@Failure		500		{object}	map[string]interface{}	"Internal server error"
 //	@Router			/agents/{agentId} [get]
-func GetAgent(c *gin.Context) {
+func (h *Handler) GetAgent(c *gin.Context) {
 	agentID, err := uuid.Parse(c.Param("agentId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
@@ -88,15 +83,9 @@ func GetAgent(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var agent models.Agent
-	if err := initializers.DB.Joins("Chat").
-		Where("agents.external_id = ? AND chats.user_id = ?", agentID, userModel.ID).
-		First(&agent).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found or does not belong to the user"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
-		}
+	agent, err := h.Agents.FindForMember(c, agentID, userModel.ID, models.ChatRoleViewer)
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
@@ -116,7 +105,7 @@ func GetAgent(c *gin.Context) {
 //	@Failure		404			{object}	map[string]interface{}	"Agent not found"
 //	@Failure		500			{object}	map[string]interface{}	"Internal server error"
 //	@Router			/agents/{agentId} [put]
-func UpdateAgent(c *gin.Context) {
+func (h *Handler) UpdateAgent(c *gin.Context) {
 	agentID, err := uuid.Parse(c.Param("agentId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
@@ -137,26 +126,25 @@ func UpdateAgent(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var agent models.Agent
-	if err := initializers.DB.Joins("Chat").
-		Where("agents.external_id = ? AND chats.user_id = ?", agentID, userModel.ID).
-		First(&agent).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found or does not belong to the user"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve agent"})
-		}
+	agent, err := h.Agents.FindForMember(c, agentID, userModel.ID, models.ChatRoleEditor)
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
 	agent.Name = body.Name
+	if agent.Metadata == nil {
+		agent.Metadata = &models.AgentMetadata{}
+	}
 	agent.Metadata.Lingo = body.Lingo
 	agent.Metadata.Traits = body.Traits
 
-	if err := initializers.DB.Save(&agent).Error; err != nil {
+	if err := h.Agents.Update(c, &agent); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update agent"})
 		return
 	}
 
+	realtime.PublishToChatByID(c, h.Broker, h.Chats, agent.ChatID, realtime.EventAgentUpdated, agent)
+
 	c.JSON(http.StatusOK, gin.H{"agent": agent})
 }
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+)
+
+type createAPIKeyInput struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=chats:read chats:write agents:write completions"`
+	// Days until the key expires. Zero means the key never expires.
+	ExpiresInDays int `json:"expiresInDays" binding:"min=0,max=365"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	Creates a scoped personal access token for use as "Authorization: Bearer <key>". The key is only returned once.
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Param			apiKeyInput	body		createAPIKeyInput		true	"Key name, scopes and expiry"
+//	@Success		201			{object}	map[string]interface{}	"Created API key"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/api-keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var body createAPIKeyInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	scopes := make([]auth.Scope, 0, len(body.Scopes))
+	seen := make(map[auth.Scope]bool)
+	for _, scope := range body.Scopes {
+		if !seen[auth.Scope(scope)] {
+			seen[auth.Scope(scope)] = true
+			scopes = append(scopes, auth.Scope(scope))
+		}
+	}
+
+	var expiresAt *time.Time
+	if body.ExpiresInDays > 0 {
+		expiry := time.Now().UTC().AddDate(0, 0, body.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	key, plaintext, err := auth.CreateAPIKey(c, h.APIKeys, user, body.Name, scopes, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": key, "key": plaintext})
+}
+
+// GetAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	Lists the authenticated user's API keys without their secret values
+//	@Tags			api-keys
+//	@Produce		json
+//	@Success		200	{array}		models.APIKey			"API keys"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/api-keys [get]
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keys, err := h.APIKeys.ListForUser(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// RevokeAPIKey godoc
+//
+//	@Summary		Revoke an API key
+//	@Description	Revokes one of the authenticated user's API keys
+//	@Tags			api-keys
+//	@Param			keyId	path		string					true	"API key ID"
+//	@Success		200		{object}	map[string]interface{}	"API key revoked"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404		{object}	map[string]interface{}	"API key not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/api-keys/{keyId} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	revoked, err := h.APIKeys.Revoke(c, keyID, user.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -1,17 +1,20 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/generative-ai-go/genai"
 	"github.com/google/uuid"
-	"github.com/somtojf/trio/initializers"
 	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
 	"github.com/somtojf/trio/response"
 	"github.com/somtojf/trio/types"
-	"github.com/somtojf/trio/utils"
-	"gorm.io/gorm"
 )
 
 type addAgentToChatInput struct {
@@ -62,7 +65,7 @@ type createChatWithAgentsInput struct {
 //	@Failure		409			{object}	map[string]interface{}	"Conflict"
 //	@Failure		500			{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats/{chatId}/agents [post]
-func AddAgentToChat(c *gin.Context) {
+func (h *Handler) AddAgentToChat(c *gin.Context) {
 	chatID, err := uuid.Parse(c.Param("chatId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
@@ -76,8 +79,8 @@ func AddAgentToChat(c *gin.Context) {
 		return
 	}
 
-	if len(body.Traits) > 4 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent cannot have more than 4 traits"})
+	if len(body.Traits) > h.Config.Chat.MaxTraits {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Agent cannot have more than %d traits", h.Config.Chat.MaxTraits)})
 		return
 	}
 
@@ -88,13 +91,17 @@ func AddAgentToChat(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var chat models.Chat
-	if err := initializers.DB.Preload("Agents").First(&chat, "external_id = ? AND user_id = ?", chatID, userModel.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleEditor, repository.ChatPreload{Agents: true})
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
-	if len(chat.Agents) >= 2 {
+	maxAgents := h.Config.Chat.MaxAgents
+	if chat.Type == models.ChatTypeReflection {
+		maxAgents = 2
+	}
+	if len(chat.Agents) >= maxAgents {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat already has the maximum number of agents"})
 		return
 	}
@@ -124,11 +131,13 @@ func AddAgentToChat(c *gin.Context) {
 		ChatID:   chat.ID,
 	}
 
-	if err := initializers.DB.Create(&agent).Error; err != nil {
+	if err := h.Agents.Create(c, &agent); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	realtime.PublishToChat(c, h.Broker, h.Chats, chat, realtime.EventAgentCreated, agent)
+
 	c.JSON(http.StatusCreated, gin.H{"data": agent})
 }
 
@@ -144,7 +153,7 @@ func AddAgentToChat(c *gin.Context) {
 //	@Failure		404		{object}	map[string]interface{}	"Chat not found"
 //	@Failure		500		{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats/{chatId} [delete]
-func DeleteChat(c *gin.Context) {
+func (h *Handler) DeleteChat(c *gin.Context) {
 	chatID, err := uuid.Parse(c.Param("chatId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
@@ -158,17 +167,25 @@ func DeleteChat(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var chat models.Chat
-	if err := initializers.DB.First(&chat, "external_id = ? AND user_id = ?", chatID, userModel.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
-	if err := initializers.DB.Delete(&chat).Error; err != nil {
+	// Members are resolved before the chat is gone, and only told once it is
+	memberIDs, err := h.Chats.MemberIDs(c, chat.ID)
+	if err != nil {
+		slog.Error("Failed to resolve chat members for realtime event", "chatId", chat.ExternalID, "error", err)
+	}
+
+	if err := h.Chats.Delete(c, chat); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat"})
 		return
 	}
 
+	realtime.PublishToUsers(c, h.Broker, memberIDs, realtime.Event{Type: realtime.EventChatDeleted, ChatID: chat.ExternalID})
+
 	c.JSON(http.StatusNoContent, gin.H{"message": "Chat deleted successfully"})
 }
 
@@ -187,7 +204,7 @@ func DeleteChat(c *gin.Context) {
 //	@Failure		404			{object}	map[string]interface{}	"Chat not found"
 //	@Failure		500			{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats/{chatId} [put]
-func UpdateChat(c *gin.Context) {
+func (h *Handler) UpdateChat(c *gin.Context) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -213,71 +230,41 @@ func UpdateChat(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var chat models.Chat
-	if err := initializers.DB.First(&chat, "external_id = ? AND user_id = ?", chatID, userModel.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		return
-	}
-
-	tx := initializers.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	chat.ChatName = body.ChatName
-
-	if err := tx.Save(&chat).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chat"})
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleEditor, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
-	if err := tx.Model(&chat).Association("Agents").Clear(); err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear old agents"})
+	if chat.Type == models.ChatTypeReflection && len(body.Agents) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reflection chat must have exactly two agents"})
 		return
 	}
 
-	var agentMetadata []*models.AgentMetadata
-
-	if chat.Type == models.ChatTypeReflection {
-		if len(body.Agents) != 2 {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Reflection chat must have exactly two agents"})
-			return
-		}
-		for range body.Agents {
-			agentMetadata = append(agentMetadata, nil)
-		}
-	} else {
-		for _, agentData := range body.Agents {
-			agentMetadata = append(agentMetadata, &models.AgentMetadata{
+	agents := make([]models.Agent, 0, len(body.Agents))
+	for _, agentData := range body.Agents {
+		agent := models.Agent{Name: agentData.Name}
+		if chat.Type != models.ChatTypeReflection {
+			agent.Metadata = &models.AgentMetadata{
 				Lingo:  agentData.Metadata.Lingo,
 				Traits: agentData.Metadata.Traits,
-			})
+			}
 		}
+		agents = append(agents, agent)
 	}
 
-	for i, agentData := range body.Agents {
-		agent := models.Agent{
-			Name:     agentData.Name,
-			Metadata: agentMetadata[i],
-			ChatID:   chat.ID,
-		}
+	renamed := chat.ChatName != body.ChatName
+	chat.ChatName = body.ChatName
 
-		if err := tx.Create(&agent).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
-			return
-		}
+	if err := h.Chats.Update(c, &chat, agents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update chat"})
+		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+	if renamed {
+		realtime.PublishToChat(c, h.Broker, h.Chats, chat, realtime.EventChatRenamed, gin.H{"chatName": chat.ChatName})
 	}
+	realtime.PublishToChat(c, h.Broker, h.Chats, chat, realtime.EventChatUpdated, chat)
 
 	c.JSON(http.StatusOK, gin.H{"data": chat})
 }
@@ -294,7 +281,7 @@ func UpdateChat(c *gin.Context) {
 //	@Failure		404		{object}	map[string]interface{}	"Chat not found"
 //	@Failure		500		{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats/{chatId} [get]
-func GetChatInfo(c *gin.Context) {
+func (h *Handler) GetChatInfo(c *gin.Context) {
 	chatID, err := uuid.Parse(c.Param("chatId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
@@ -308,13 +295,15 @@ func GetChatInfo(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var chat models.Chat
-	if err := initializers.DB.Preload("Agents").
-		Preload("Messages", func(db *gorm.DB) *gorm.DB {
-			return db.Order("created_at ASC")
-		}).
-		First(&chat, "external_id = ? AND user_id = ?", chatID, userModel.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	chat, role, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleViewer, repository.ChatPreload{Agents: true, Messages: true})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	users, agents, err := h.findMessageSenders(c, chat.Messages)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat"})
 		return
 	}
 
@@ -330,8 +319,7 @@ func GetChatInfo(c *gin.Context) {
 		var sender interface{}
 
 		if message.SenderType == string(types.SenderTypeUser) {
-			var user models.User
-			initializers.DB.First(&user, message.SenderID)
+			user := users[message.SenderID]
 			sender = struct {
 				ID       uuid.UUID `json:"id"`
 				Username string    `json:"username"`
@@ -342,9 +330,8 @@ func GetChatInfo(c *gin.Context) {
 				FullName: user.FullName,
 			}
 		} else if message.SenderType == string(types.SenderTypeAgent) {
-			var agent models.Agent
-			if chat.Type == models.ChatTypeReflection {
-				initializers.DB.First(&agent, message.SenderID)
+			agent := agents[message.SenderID]
+			if chat.Type == models.ChatTypeReflection || agent.Metadata == nil {
 				sender = struct {
 					ID   uuid.UUID `json:"id"`
 					Name string    `json:"name"`
@@ -353,7 +340,6 @@ func GetChatInfo(c *gin.Context) {
 					Name: agent.Name,
 				}
 			} else {
-				initializers.DB.Preload("Metadata").First(&agent, message.SenderID)
 				sender = struct {
 					ID       uuid.UUID `json:"id"`
 					Name     string    `json:"name"`
@@ -386,14 +372,47 @@ func GetChatInfo(c *gin.Context) {
 	chatResponse := struct {
 		models.Chat
 		Messages []MessageWithSender `json:"messages"`
+		Role     models.ChatRole     `json:"role"`
 	}{
 		Chat:     chat,
 		Messages: messagesWithSenders,
+		Role:     role,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": chatResponse})
 }
 
+// Helper function to load the users and agents who sent the messages, keyed by id
+func (h *Handler) findMessageSenders(ctx context.Context, messages []models.Message) (map[uint]models.User, map[uint]models.Agent, error) {
+	var userIDs, agentIDs []uint
+	for _, message := range messages {
+		if message.SenderType == string(types.SenderTypeAgent) {
+			agentIDs = append(agentIDs, message.SenderID)
+		} else {
+			userIDs = append(userIDs, message.SenderID)
+		}
+	}
+
+	users, err := h.Users.FindByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	agents, err := h.Agents.FindByIDs(ctx, agentIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usersByID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+	agentsByID := make(map[uint]models.Agent, len(agents))
+	for _, agent := range agents {
+		agentsByID[agent.ID] = agent
+	}
+	return usersByID, agentsByID, nil
+}
+
 // CreateChatWithAgents godoc
 //
 //	@Summary		Create a new chat with agents
@@ -407,7 +426,7 @@ func GetChatInfo(c *gin.Context) {
 //	@Failure		401			{object}	map[string]interface{}		"Unauthorized"
 //	@Failure		500			{object}	map[string]interface{}		"Internal server error"
 //	@Router			/chats/create-with-agents [post]
-func CreateChat(c *gin.Context) {
+func (h *Handler) CreateChat(c *gin.Context) {
 	var body createChatWithAgentsInput
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -416,8 +435,8 @@ func CreateChat(c *gin.Context) {
 	}
 
 	for _, agent := range body.Agents {
-		if len(agent.Traits) > 4 || len(agent.Traits) < 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Agent must have at least one trait and a maximum of four traits"})
+		if len(agent.Traits) > h.Config.Chat.MaxTraits || len(agent.Traits) < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Agent must have at least one trait and a maximum of %d traits", h.Config.Chat.MaxTraits)})
 			return
 		}
 	}
@@ -429,70 +448,54 @@ func CreateChat(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	tx := initializers.DB.Begin()
-
-	chat := models.Chat{
-		ChatName: body.ChatName,
-		Type:     models.ChatType(body.Type),
-		UserID:   userModel.ID,
-	}
-
-	if err := tx.Create(&chat).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chat"})
-		return
-	}
-
 	if body.Type == string(models.ChatTypeReflection) {
 		if len(body.Agents) != 2 {
-			tx.Rollback()
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Reflection chat must have exactly two agents"})
 			return
 		}
+	} else if len(body.Agents) == 0 || len(body.Agents) > h.Config.Chat.MaxAgents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chat must have at least one agent and a maximum of %d agents", h.Config.Chat.MaxAgents)})
+		return
+	}
 
-		for _, agent := range body.Agents {
-			if err := createAgent(tx, agent.Name, nil, chat.ID); err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+	agents := make([]models.Agent, 0, len(body.Agents))
+	for _, agentData := range body.Agents {
+		agent := models.Agent{Name: agentData.Name}
+		if body.Type != string(models.ChatTypeReflection) {
+			agent.Metadata = &models.AgentMetadata{
+				Lingo:  agentData.Lingo,
+				Traits: agentData.Traits,
 			}
 		}
-	} else {
-		if len(body.Agents) == 0 || len(body.Agents) > 2 {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Chat must have at least one agent and a maximum of two agents"})
-			return
-		}
+		agents = append(agents, agent)
+	}
 
-		for _, agent := range body.Agents {
-			agentMetadata := &models.AgentMetadata{
-				Lingo:  agent.Lingo,
-				Traits: agent.Traits,
-			}
-			if err := createAgent(tx, agent.Name, agentMetadata, chat.ID); err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+	chat := models.Chat{
+		ChatName: body.ChatName,
+		Type:     models.ChatType(body.Type),
+		UserID:   userModel.ID,
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	if err := h.Chats.Create(c, &chat, agents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chat"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"data": chat})
 }
 
-// Helper function to create an agent
-func createAgent(tx *gorm.DB, name string, metadata *models.AgentMetadata, chatID uint) error {
-	agent := models.Agent{
-		Name:     name,
-		Metadata: metadata,
-		ChatID:   chatID,
+// Helper function to map chat access errors to responses
+func respondChatAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrChatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	case errors.Is(err, repository.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found or does not belong to the user"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat"})
 	}
-	return tx.Create(&agent).Error
 }
 
 // NewMessage godoc
@@ -510,9 +513,10 @@ func createAgent(tx *gorm.DB, name string, metadata *models.AgentMetadata, chatI
 //	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
 //	@Failure		404			{object}	map[string]interface{}	"Chat not found"
 //	@Failure		424			{object}	map[string]interface{}	"Chat must have at least one agent"
+//	@Failure		429			{object}	map[string]interface{}	"Usage quota exceeded"
 //	@Failure		500			{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats/{chatId}/messages [post]
-func NewMessage(c *gin.Context) {
+func (h *Handler) NewMessage(c *gin.Context) {
 	chatID, err := uuid.Parse(c.Param("chatId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
@@ -533,9 +537,9 @@ func NewMessage(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	var chat models.Chat
-	if err := initializers.DB.Preload("Agents").First(&chat, "external_id = ? AND user_id = ?", chatID, userModel.ID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleEditor, repository.ChatPreload{Agents: true})
+	if err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
@@ -544,29 +548,43 @@ func NewMessage(c *gin.Context) {
 		return
 	}
 
-	client, ok := c.Value("GeminiClient").(*genai.Client)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't retrieve gemini client"})
-		return
-	}
-
 	// Ensure we have at least one agent
 	if len(chat.Agents) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat must have at least one agent"})
 		return
 	}
 
-	response := response.NewResponse(chat.Messages, chat, chat.Agents, userModel, c, client)
+	status, err := quota.Check(c, h.Usage, h.Plans, userModel)
+	if quota.RespondIfExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check usage quota"})
+		return
+	}
+	quota.SetHeaders(c, status)
+
+	response := response.NewResponse(chat.Messages, chat, chat.Agents, userModel, c, h.Repositories, h.modelCaller(), h.Config.LLM.Models, h.Broker)
 	if chat.Type == models.ChatTypeDefault {
 		agentResponses, err := response.GenerateBasicResponse(body.Content)
+		if quota.RespondIfExceeded(c, err) {
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if err := utils.SaveResponsesToDatabase(agentResponses...); err != nil {
+		if err := h.Messages.CreateMany(c, agentResponses); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save agent responses"})
 			return
 		}
+		for _, agentResponse := range agentResponses {
+			for _, agent := range chat.Agents {
+				if agent.ID == agentResponse.SenderID {
+					realtime.PublishToChat(c, h.Broker, h.Chats, chat, realtime.EventMessageCreated, realtime.NewMessagePayload(agentResponse, realtime.AgentSender(agent)))
+				}
+			}
+		}
 
 		c.JSON(http.StatusCreated, gin.H{
 			"requestPrompt": body.Content,
@@ -576,6 +594,9 @@ func NewMessage(c *gin.Context) {
 	} else if chat.Type == models.ChatTypeReflection {
 		err = response.GenerateReflectionResponse(body.Content)
 	}
+	if quota.RespondIfExceeded(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -594,7 +615,7 @@ func NewMessage(c *gin.Context) {
 //	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
 //	@Failure		500	{object}	map[string]interface{}	"Internal server error"
 //	@Router			/chats [delete]
-func DeleteAllChats(c *gin.Context) {
+func (h *Handler) DeleteAllChats(c *gin.Context) {
 	currentUser, exists := c.Get("currentUser")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -602,18 +623,26 @@ func DeleteAllChats(c *gin.Context) {
 	}
 	userModel := currentUser.(models.User)
 
-	tx := initializers.DB.Begin()
-
-	if err := tx.Where("user_id = ?", userModel.ID).Delete(&models.Chat{}).Error; err != nil {
-		tx.Rollback()
+	chats, err := h.Chats.ListForMember(c, userModel.ID, models.ChatRoleOwner)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chats"})
 		return
 	}
+	memberIDs := make([][]uint, len(chats))
+	for i, chat := range chats {
+		if memberIDs[i], err = h.Chats.MemberIDs(c, chat.ID); err != nil {
+			slog.Error("Failed to resolve chat members for realtime event", "chatId", chat.ExternalID, "error", err)
+		}
+	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	if err := h.Chats.DeleteOwnedBy(c, userModel.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chats"})
 		return
 	}
 
+	for i, chat := range chats {
+		realtime.PublishToUsers(c, h.Broker, memberIDs[i], realtime.Event{Type: realtime.EventChatDeleted, ChatID: chat.ExternalID})
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "All chats deleted successfully"})
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	aihelpers "github.com/somtojf/trio/ai-helpers"
 	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
 	"github.com/somtojf/trio/types"
 )
 
@@ -13,7 +14,7 @@ type completionsRequest struct {
 	Text string `json:"text" binding:"required"`
 }
 
-func GetCompletion(c *gin.Context) {
+func (h *Handler) GetCompletion(c *gin.Context) {
 	var body completionsRequest
 	user, ok := c.Value("currentUser").(models.User)
 	if !ok {
@@ -32,10 +33,13 @@ func GetCompletion(c *gin.Context) {
 		SenderType: types.SenderTypeUser,
 	}
 
-	resp, err := aihelpers.GetGeminiCompletions(c, completionsRequest)
+	resp, err := aihelpers.GetGeminiCompletions(c, h.modelCaller(), h.Config.LLM.Models.Completions, completionsRequest)
+	if quota.RespondIfExceeded(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": resp.Candidates[0].Content.Parts[0]})
+	c.JSON(http.StatusOK, gin.H{"data": resp.Text})
 }
@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/somtojf/trio/initializers"
 	"github.com/somtojf/trio/models"
 )
 
@@ -15,7 +14,7 @@ import (
 //	@Success		200	{object}	models.User				"Current user data"
 //	@Failure		500	{object}	map[string]interface{}	"Internal server error"
 //	@Router			/me [get]
-func GetCurrentUser(c *gin.Context) {
+func (h *Handler) GetCurrentUser(c *gin.Context) {
 	user := c.Value("currentUser")
 	if user == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "We couldn't retrieve your data"})
@@ -31,11 +30,10 @@ func GetCurrentUser(c *gin.Context) {
 //	@Success		200	{array}		models.Chat				"User chats"
 //	@Failure		500	{object}	map[string]interface{}	"Internal server error"
 //	@Router			/me/chats [get]
-func GetUserChats(c *gin.Context) {
+func (h *Handler) GetUserChats(c *gin.Context) {
 	user := c.Value("currentUser").(models.User)
-	var chats []models.Chat
-
-	if err := initializers.DB.Where("user_id = ?", user.ID).Find(&chats).Error; err != nil {
+	chats, err := h.Chats.ListForMember(c, user.ID, models.ChatRoleViewer)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve chats"})
 		return
 	}
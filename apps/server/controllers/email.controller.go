@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+)
+
+type verifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type updateEmailInput struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+// VerifyEmail godoc
+//	@Summary		Verify email address
+//	@Description	Confirms the user's email address with the token from the verification email
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			verifyEmailInput	body		verifyEmailInput		true	"Verification token"
+//	@Success		200					{object}	map[string]interface{}	"Email verified"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid or expired token"
+//	@Failure		500					{object}	map[string]interface{}	"Internal server error"
+//	@Router			/verify-email [post]
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var body verifyEmailInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := auth.VerifyEmail(c, h.UserTokens, body.Token); err != nil {
+		if errors.Is(err, auth.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerificationEmail godoc
+//	@Summary		Resend verification email
+//	@Description	Sends a new verification link to the authenticated user's email address
+//	@Tags			users
+//	@Success		200	{object}	map[string]interface{}	"Verification email sent"
+//	@Failure		400	{object}	map[string]interface{}	"No email or already verified"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/email/verification [post]
+func (h *Handler) ResendVerificationEmail(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if user.Email == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Add an email address first"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := auth.SendVerificationEmail(c, h.UserTokens, h.Mailer, h.Config.ClientAddress, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// UpdateEmail godoc
+//	@Summary		Change email address
+//	@Description	Sets the authenticated user's email address and sends a verification link to it
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			updateEmailInput	body		updateEmailInput		true	"New email"
+//	@Success		200					{object}	models.User				"Updated user"
+//	@Failure		400					{object}	map[string]interface{}	"Bad request"
+//	@Failure		401					{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		409					{object}	map[string]interface{}	"Email already in use"
+//	@Failure		500					{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/email [put]
+func (h *Handler) UpdateEmail(c *gin.Context) {
+	var body updateEmailInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	user, err := auth.ChangeEmail(c, h.Users, user, body.Email)
+	if errors.Is(err, auth.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email"})
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := auth.SendVerificationEmail(c, h.UserTokens, h.Mailer, h.Config.ClientAddress, user); err != nil {
+			slog.Error("Failed to send verification email", "userId", user.ExternalID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/transcript"
+)
+
+// ExportChat godoc
+//
+//	@Summary		Export a chat
+//	@Description	Exports a chat transcript with agent names, traits, timestamps and reflection verdicts
+//	@Tags			chats
+//	@Produce		json,html,plain
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Param			format	query		string					false	"Export format (md, json or html)"	default(md)
+//	@Success		200		{file}		file					"Chat transcript"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404		{object}	map[string]interface{}	"Chat not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/export [get]
+func (h *Handler) ExportChat(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	format := transcript.Format(c.DefaultQuery("format", string(transcript.FormatMarkdown)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be one of md, json or html"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleViewer, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	chatTranscript, err := transcript.Load(c, h.Repositories, chat)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build chat transcript"})
+		return
+	}
+
+	var buffer bytes.Buffer
+	if err := chatTranscript.Render(&buffer, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render chat transcript"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", chatTranscript.FileName(format)))
+	c.Data(http.StatusOK, format.ContentType(), buffer.Bytes())
+}
+
+// ExportAllChats godoc
+//
+//	@Summary		Export all chats
+//	@Description	Exports every chat of the authenticated user as a zip archive of transcripts
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			format	query		string					false	"Export format (md, json or html)"	default(json)
+//	@Success		200		{file}		file					"Zip archive of chat transcripts"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/export [get]
+func (h *Handler) ExportAllChats(c *gin.Context) {
+	format := transcript.Format(c.DefaultQuery("format", string(transcript.FormatJSON)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be one of md, json or html"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chats, err := h.Chats.ListForMember(c, userModel.ID, models.ChatRoleViewer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve chats"})
+		return
+	}
+
+	transcripts := make([]transcript.Transcript, 0, len(chats))
+	for _, chat := range chats {
+		chatTranscript, err := transcript.Load(c, h.Repositories, chat)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build chat transcripts"})
+			return
+		}
+		transcripts = append(transcripts, chatTranscript)
+	}
+
+	var buffer bytes.Buffer
+	if err := transcript.WriteArchive(&buffer, transcripts, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build export archive"})
+		return
+	}
+
+	fileName := fmt.Sprintf("trio-export-%s-%s.zip", userModel.Username, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, "application/zip", buffer.Bytes())
+}
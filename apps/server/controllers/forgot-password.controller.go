@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/repository"
+)
+
+type forgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+type resetForgottenPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,max=20,min=8"`
+}
+
+// ForgotPassword godoc
+//	@Summary		Request a password reset
+//	@Description	Emails a single-use password reset link. The response is the same whether or not the address has an account.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			forgotPasswordInput	body		forgotPasswordInput		true	"Account email"
+//	@Success		200					{object}	map[string]interface{}	"Reset email sent if the account exists"
+//	@Failure		400					{object}	map[string]interface{}	"Bad request"
+//	@Router			/forgot-password [post]
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var body forgotPasswordInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.Users.FindByEmail(c, auth.NormalizeEmail(body.Email))
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		slog.Error("Failed to look up password reset email", "error", err)
+	}
+
+	if err == nil {
+		if err := auth.SendPasswordResetEmail(c, h.UserTokens, h.Mailer, h.Config.ClientAddress, user); err != nil {
+			slog.Error("Failed to send password reset email", "userId", user.ExternalID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account uses that email, a reset link is on its way"})
+}
+
+// ResetForgottenPassword godoc
+//	@Summary		Reset a forgotten password
+//	@Description	Sets a new password using the token from the reset email and logs out every session
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			resetForgottenPasswordInput	body		resetForgottenPasswordInput	true	"Reset token and new password"
+//	@Success		200							{object}	map[string]interface{}		"Password updated"
+//	@Failure		400							{object}	map[string]interface{}		"Invalid or expired token"
+//	@Failure		500							{object}	map[string]interface{}		"Internal server error"
+//	@Router			/forgot-password/reset [post]
+func (h *Handler) ResetForgottenPassword(c *gin.Context) {
+	var body resetForgottenPasswordInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := auth.ResetPasswordWithToken(c, h.Repositories, body.Token, body.NewPassword); err != nil {
+		if errors.Is(err, auth.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	auth.ClearAuthCookies(c, h.Config.Domain)
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully. Please login with new password"})
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"context"
+
+	aihelpers "github.com/somtojf/trio/ai-helpers"
+	"github.com/somtojf/trio/app"
+	"github.com/somtojf/trio/health"
+)
+
+// Handler serves the HTTP API. Every dependency comes from the App, so tests can build
+// a Handler around fakes instead of Postgres, Qdrant and Gemini.
+type Handler struct {
+	*app.App
+	// pingLLM is shared by every readiness probe so the provider is not called on each one
+	pingLLM func(ctx context.Context) error
+}
+
+func NewHandler(a *app.App) *Handler {
+	h := &Handler{App: a}
+	h.pingLLM = health.Cached(LLM_HEALTH_CACHE_TTL, h.probeLLM)
+	return h
+}
+
+// modelCaller routes model calls through the app's provider, quota plans and metrics
+func (h *Handler) modelCaller() aihelpers.Caller {
+	return aihelpers.Caller{Provider: h.LLM, Usage: h.Usage, Plans: h.Plans, Metrics: h.Metrics}
+}
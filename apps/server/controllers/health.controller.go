@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/health"
+	"github.com/somtojf/trio/llm"
+)
+
+// HEALTH_CHECK_TIMEOUT bounds each dependency probe, so a hung dependency cannot outlast the orchestrator's probe timeout
+const HEALTH_CHECK_TIMEOUT = 2 * time.Second
+
+// LLM_HEALTH_CACHE_TTL spaces out model provider pings, which are rate limited and count against
+// the API key's quota, however often the orchestrator probes readiness
+const LLM_HEALTH_CACHE_TTL = 30 * time.Second
+
+// Healthz godoc
+//	@Summary		Liveness probe
+//	@Description	Reports that the process is running. It checks no dependencies, so an outage elsewhere never gets the server restarted.
+//	@Tags			health
+//	@Success		200	{object}	map[string]interface{}	"Alive"
+//	@Router			/healthz [get]
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readyz godoc
+//	@Summary		Readiness probe
+//	@Description	Probes Postgres, Qdrant and the model provider and reports each one. Qdrant only backs search, so it being down degrades the report without failing it. The model provider's result is reused for 30 seconds.
+//	@Tags			health
+//	@Success		200	{object}	health.Report	"Ready, possibly degraded"
+//	@Failure		503	{object}	health.Report	"A required dependency is down or the server is shutting down"
+//	@Router			/readyz [get]
+func (h *Handler) Readyz(c *gin.Context) {
+	report := health.Run(c.Request.Context(), HEALTH_CHECK_TIMEOUT, h.healthChecks())
+
+	// Stop new traffic as soon as shutdown starts, while in-flight requests finish
+	if h.Generations != nil && h.Generations.ShuttingDown() {
+		report.Status = health.StatusDown
+		report.Checks["server"] = health.Result{Status: health.StatusDown, Error: "shutting down"}
+	}
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+func (h *Handler) healthChecks() []health.Check {
+	return []health.Check{
+		{Name: "postgres", Probe: func(ctx context.Context) error {
+			if h.DB == nil {
+				return health.ErrNotConfigured
+			}
+			sqlDB, err := h.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		{Name: "qdrant", Optional: true, Probe: func(ctx context.Context) error {
+			if h.Vectors == nil {
+				return health.ErrNotConfigured
+			}
+			return h.Vectors.Ping(ctx)
+		}},
+		{Name: "llm", Probe: h.pingLLM},
+	}
+}
+
+func (h *Handler) probeLLM(ctx context.Context) error {
+	if h.LLM == nil {
+		return health.ErrNotConfigured
+	}
+	// Providers that cannot be probed, like the fake, are reachable by definition
+	if pinger, ok := h.LLM.(llm.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	aihelpers "github.com/somtojf/trio/ai-helpers"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/transcript"
+	"github.com/somtojf/trio/types"
+	"gorm.io/gorm"
+)
+
+var defaultImportedAgentTraits = []string{"helpful"}
+
+// MAX_IMPORTED_NAME_LENGTH matches the max=20 CreateChat enforces on chat and agent names
+const MAX_IMPORTED_NAME_LENGTH = 20
+
+type importChatInput struct {
+	ChatName   string          `json:"chatName" binding:"max=20"`
+	Type       string          `json:"type" binding:"omitempty,oneof=DEFAULT REFLECTION"`
+	Embed      bool            `json:"embed"`
+	Transcript json.RawMessage `json:"transcript" binding:"required" swaggertype:"object"`
+}
+
+// ImportChat godoc
+//
+//	@Summary		Import a chat
+//	@Description	Creates a chat with its agents and messages from a Trio JSON export or an OpenAI/Gemini-style message array
+//	@Tags			chats
+//	@Accept			json
+//	@Produce		json
+//	@Param			importInput	body		importChatInput			true	"Transcript to import"
+//	@Success		201			{object}	map[string]interface{}	"Imported chat"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/import [post]
+func (h *Handler) ImportChat(c *gin.Context) {
+	var body importChatInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imported, err := transcript.Parse(body.Transcript)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chatType := models.ChatTypeDefault
+	if imported.Type != "" {
+		chatType = imported.Type
+	}
+	if body.Type != "" {
+		chatType = models.ChatType(body.Type)
+	}
+	if !types.ChatType(chatType).IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat type"})
+		return
+	}
+
+	chatName := body.ChatName
+	if chatName == "" {
+		chatName = imported.ChatName
+	}
+	if chatName == "" {
+		chatName = "Imported chat"
+	}
+	chatName = truncateRunes(chatName, MAX_IMPORTED_NAME_LENGTH)
+
+	agents := importedAgents(imported, h.Config.Chat.MaxTraits)
+	if chatType == models.ChatTypeReflection && len(agents) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reflection chat must have exactly two agents"})
+		return
+	}
+	if len(agents) == 0 || len(agents) > h.Config.Chat.MaxAgents {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chat must have at least one agent and a maximum of %d agents", h.Config.Chat.MaxAgents)})
+		return
+	}
+
+	chat := models.Chat{
+		ChatName: chatName,
+		Type:     chatType,
+		UserID:   userModel.ID,
+	}
+	if chatType == models.ChatTypeReflection {
+		for i := range agents {
+			agents[i].Metadata = nil
+		}
+	}
+	if err := h.Chats.Create(c, &chat, agents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create chat"})
+		return
+	}
+
+	agentIDs := make(map[string]uint)
+	for _, agent := range agents {
+		agentIDs[agent.Name] = agent.ID
+	}
+
+	var messages []models.Message
+	senderNames := make(map[int]string)
+
+	// Imported transcripts without timestamps keep their order by spacing messages a microsecond apart
+	baseTime := time.Now().UTC().Add(-time.Duration(len(imported.Messages)) * time.Microsecond)
+	for i, message := range imported.Messages {
+		createdAt := message.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = baseTime.Add(time.Duration(i) * time.Microsecond)
+		}
+
+		senderID := userModel.ID
+		senderName := userModel.Username
+		if message.SenderType == types.SenderTypeAgent {
+			senderName = truncateRunes(message.SenderName, MAX_IMPORTED_NAME_LENGTH)
+			// Senders missing from the agent list, such as agents removed before the export,
+			// are attributed to the first listed agent
+			if _, ok := agentIDs[senderName]; !ok {
+				senderName = agents[0].Name
+			}
+			senderID = agentIDs[senderName]
+		}
+
+		senderNames[len(messages)] = senderName
+		messages = append(messages, models.Message{
+			Model:      gorm.Model{CreatedAt: createdAt, UpdatedAt: createdAt},
+			Content:    message.Content,
+			ChatID:     chat.ID,
+			SenderType: string(message.SenderType),
+			SenderID:   senderID,
+		})
+	}
+
+	if err := h.Messages.CreateMany(c, messages); err != nil {
+		// Without its messages the import failed, so the half created chat is removed again
+		if deleteErr := h.Chats.Delete(c, chat); deleteErr != nil {
+			slog.Error("Failed to remove partially imported chat", "chatId", chat.ExternalID, "error", deleteErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create messages"})
+		return
+	}
+
+	chat.Agents = agents
+
+	embedded := false
+	var embeddingError string
+	if body.Embed {
+		if err := h.embedImportedMessages(c, userModel, chat, messages, senderNames); err != nil {
+			embeddingError = err.Error()
+		} else {
+			embedded = true
+		}
+	}
+
+	response := gin.H{
+		"data":          chat,
+		"messagesCount": len(messages),
+		"embedded":      embedded,
+	}
+	if embeddingError != "" {
+		response["embeddingError"] = embeddingError
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// importedAgents collects the distinct agents the transcript lists. Messages from senders it does not
+// list are attributed to one of these, so a chat that once had more agents still imports within the limit.
+func importedAgents(imported transcript.Transcript, maxTraits int) []models.Agent {
+	var agents []models.Agent
+	seen := make(map[string]bool)
+
+	for _, agent := range imported.Agents {
+		name := truncateRunes(agent.Name, MAX_IMPORTED_NAME_LENGTH)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		traits := agent.Traits
+		if len(traits) == 0 {
+			traits = defaultImportedAgentTraits
+		}
+		if len(traits) > maxTraits {
+			traits = traits[:maxTraits]
+		}
+
+		agents = append(agents, models.Agent{
+			Name: name,
+			Metadata: &models.AgentMetadata{
+				Lingo:  truncateRunes(agent.Lingo, MAX_IMPORTED_NAME_LENGTH),
+				Traits: traits,
+			},
+		})
+	}
+
+	return agents
+}
+
+func truncateRunes(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+func (h *Handler) embedImportedMessages(c *gin.Context, user models.User, chat models.Chat, messages []models.Message, senderNames map[int]string) error {
+	embeddable := make([]aihelpers.EmbeddableMessage, 0, len(messages))
+	for i, message := range messages {
+		embeddable = append(embeddable, aihelpers.EmbeddableMessage{
+			MessageID:  message.ExternalID.String(),
+			ChatID:     chat.ExternalID.String(),
+			SenderType: message.SenderType,
+			SenderName: senderNames[i],
+			Content:    message.Content,
+		})
+	}
+
+	call := aihelpers.ModelCall{User: user, ChatID: chat.ID}
+	return aihelpers.EmbedMessages(c.Request.Context(), h.modelCaller(), h.Vectors, h.Config.LLM.Models.Embedding, call, embeddable)
+}
@@ -1,15 +1,18 @@
 package controllers
 
 import (
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/somtojf/trio/initializers"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
 	"github.com/somtojf/trio/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/somtojf/trio/repository"
 )
 
 type loginInput struct {
@@ -19,17 +22,18 @@ type loginInput struct {
 
 // Login godoc
 //	@Summary		Login user
-//	@Description	Logs in a user and returns an access token
+//	@Description	Logs in a user, starting a session with a short-lived access token and a rotating refresh token. Repeated failures slow down and then temporarily lock the username and IP.
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			loginInput	body		loginInput				true	"Login credentials"
-//	@Success		200			{object}	map[string]interface{}	"success message"
+//	@Success		200			{object}	map[string]interface{}	"success message, or a pre-auth token when two-factor authentication is enabled"
 //	@Failure		400			{object}	map[string]interface{}	"error message"
+//	@Failure		403			{object}	map[string]interface{}	"account suspended"
+//	@Failure		429			{object}	map[string]interface{}	"too many failed attempts"
 //	@Failure		500			{object}	map[string]interface{}	"internal server error"
 //	@Router			/login [post]
-func Login(c *gin.Context) {
-	domain := os.Getenv("DOMAIN")
+func (h *Handler) Login(c *gin.Context) {
 	var body loginInput
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -37,32 +41,142 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var userFound models.User
-	initializers.DB.Where("username=?", body.Username).Find(&userFound)
+	retryAfter, err := auth.LoginRetryAfter(c, h.LoginThrottles, c.ClientIP(), body.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+		return
+	}
+	if retryAfter > 0 {
+		respondLoginThrottled(c, retryAfter)
+		return
+	}
+
+	// A failed lookup leaves userFound empty, which fails the comparison below
+	userFound, _ := h.Users.FindByUsername(c, body.Username)
 
-	if userFound.ID == 0 {
+	// Unknown users still pay for a bcrypt comparison so response times do not reveal which usernames exist
+	if !auth.ComparePassword(userFound, body.Password) {
+		if err := auth.RecordLoginFailure(c, h.Repositories, body.Username, userFound); err != nil {
+			slog.Error("Failed to record login failure", "error", err)
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "username or password is incorrect"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(userFound.PasswordHash), []byte(body.Password)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username or password is incorrect"})
+	// Failures are only cleared once every factor has passed, so knowing the password
+	// does not reset the counter guarding the second factor
+	if userFound.TwoFactorEnabledAt != nil {
+		preAuthToken, err := auth.IssuePreAuthToken(h.Keys, userFound)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "two-factor authentication required",
+			"twoFactorRequired": true,
+			"preAuthToken":      preAuthToken,
+		})
 		return
 	}
 
-	generateToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       userFound.ExternalID.String(),
-		"username": userFound.Username,
-		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(),
-	})
+	h.completeLogin(c, userFound)
+}
+
+type twoFactorLoginInput struct {
+	PreAuthToken string `json:"preAuthToken" binding:"required"`
+	// A current TOTP code or an unused recovery code
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// LoginTwoFactor godoc
+//	@Summary		Complete two-factor login
+//	@Description	Exchanges the pre-auth token from /login and a TOTP or recovery code for a session
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			twoFactorLoginInput	body		twoFactorLoginInput		true	"Pre-auth token and code"
+//	@Success		200					{object}	map[string]interface{}	"success message"
+//	@Failure		400					{object}	map[string]interface{}	"invalid code"
+//	@Failure		401					{object}	map[string]interface{}	"pre-auth token expired"
+//	@Failure		429					{object}	map[string]interface{}	"too many failed attempts"
+//	@Failure		500					{object}	map[string]interface{}	"internal server error"
+//	@Router			/login/2fa [post]
+func (h *Handler) LoginTwoFactor(c *gin.Context) {
+	var body twoFactorLoginInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := auth.ParsePreAuthToken(h.Keys, body.PreAuthToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
+	externalID, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
 
-	token, err := generateToken.SignedString([]byte(os.Getenv("SECRET")))
+	userFound, err := h.Users.FindByExternalID(c, externalID)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+		return
+	}
+	if err != nil || userFound.TwoFactorEnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
 
+	retryAfter, err := auth.LoginRetryAfter(c, h.LoginThrottles, c.ClientIP(), userFound.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+		return
+	}
+	if retryAfter > 0 {
+		respondLoginThrottled(c, retryAfter)
+		return
+	}
+
+	if err := auth.VerifySecondFactor(c, h.TwoFactor, h.Keys, userFound, body.Code); err != nil {
+		if !errors.Is(err, auth.ErrInvalidSecondFactor) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+			return
+		}
+		if err := auth.RecordLoginFailure(c, h.Repositories, userFound.Username, userFound); err != nil {
+			slog.Error("Failed to record login failure", "error", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.completeLogin(c, userFound)
+}
+
+func respondLoginThrottled(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts. Please try again later"})
+}
+
+func (h *Handler) completeLogin(c *gin.Context, userFound models.User) {
+	if err := auth.ClearLoginFailures(c, h.LoginThrottles, userFound.Username); err != nil {
+		slog.Error("Failed to clear login failures", "error", err)
+	}
+
+	tokens, err := auth.StartSession(c, h.Sessions, h.Keys, userFound)
+	if errors.Is(err, auth.ErrAccountSuspended) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+		return
 	}
 
-	c.SetCookie("Access_Token", token, 604800, "/", domain, false, true)
+	auth.SetAuthCookies(c, h.Config.Domain, tokens)
 
 	c.JSON(200, gin.H{
 		"message": "success",
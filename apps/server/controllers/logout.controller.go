@@ -2,18 +2,56 @@ package controllers
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
 )
 
 // Logout godoc
 //	@Summary		Logout user
-//	@Description	Logs out the user by clearing the access token
+//	@Description	Revokes the current session and clears the auth cookies. Works with an expired access token as long as the refresh token is present.
 //	@Tags			auth
 //	@Success		200	{object}	map[string]interface{}	"Logout successful"
 //	@Router			/logout [post]
-func Logout(c *gin.Context) {
-	c.SetCookie("Access_Token", "", -1, "/", os.Getenv("DOMAIN"), false, true)
+func (h *Handler) Logout(c *gin.Context) {
+	if accessToken, err := c.Cookie(auth.ACCESS_TOKEN_COOKIE); err == nil {
+		if claims, err := auth.ParseAccessToken(h.Keys, accessToken); err == nil {
+			if session, err := auth.FindActiveSession(c, h.Sessions, claims.SessionID); err == nil {
+				auth.RevokeSession(c, h.Sessions, session)
+			}
+		}
+	}
+	if refreshToken, err := c.Cookie(auth.REFRESH_TOKEN_COOKIE); err == nil {
+		if session, err := auth.FindSessionByRefreshToken(c, h.Sessions, refreshToken); err == nil {
+			auth.RevokeSession(c, h.Sessions, session)
+		}
+	}
+
+	auth.ClearAuthCookies(c, h.Config.Domain)
 	c.JSON(http.StatusOK, gin.H{"message": "logout successful"})
 }
+
+// LogoutEverywhere godoc
+//	@Summary		Logout from every device
+//	@Description	Revokes every session of the authenticated user, including the current one
+//	@Tags			auth
+//	@Success		200	{object}	map[string]interface{}	"Logged out everywhere"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/logout-all [post]
+func (h *Handler) LogoutEverywhere(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := auth.RevokeAllSessions(c, h.Sessions, user.ID, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	auth.ClearAuthCookies(c, h.Config.Domain)
+	c.JSON(http.StatusOK, gin.H{"message": "logged out everywhere"})
+}
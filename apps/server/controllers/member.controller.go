@@ -0,0 +1,535 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
+)
+
+type inviteToChatInput struct {
+	Username string `json:"userName" binding:"required,max=20"`
+	Role     string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type updateChatMemberInput struct {
+	Role string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type chatMemberResponse struct {
+	ID       uuid.UUID       `json:"id"`
+	Username string          `json:"userName"`
+	FullName string          `json:"fullName"`
+	Role     models.ChatRole `json:"role"`
+	JoinedAt time.Time       `json:"joinedAt"`
+}
+
+type chatInvitationResponse struct {
+	ID        uuid.UUID               `json:"id"`
+	ChatID    uuid.UUID               `json:"chatId"`
+	ChatName  string                  `json:"chatName"`
+	Inviter   string                  `json:"inviter"`
+	Invitee   string                  `json:"invitee"`
+	Role      models.ChatRole         `json:"role"`
+	Status    models.InvitationStatus `json:"status"`
+	CreatedAt time.Time               `json:"createdAt"`
+}
+
+func newChatInvitationResponse(invitation models.ChatInvitation) chatInvitationResponse {
+	return chatInvitationResponse{
+		ID:        invitation.ExternalID,
+		ChatID:    invitation.Chat.ExternalID,
+		ChatName:  invitation.Chat.ChatName,
+		Inviter:   invitation.Inviter.Username,
+		Invitee:   invitation.Invitee.Username,
+		Role:      invitation.Role,
+		Status:    invitation.Status,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+// GetChatMembers godoc
+//
+//	@Summary		List chat members
+//	@Description	Lists the human members of a chat and their roles
+//	@Tags			members
+//	@Produce		json
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Success		200		{array}		chatMemberResponse		"Chat members"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404		{object}	map[string]interface{}	"Chat not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/members [get]
+func (h *Handler) GetChatMembers(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleViewer, repository.ChatPreload{Members: true})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	members := make([]chatMemberResponse, 0, len(chat.Members))
+	for _, member := range chat.Members {
+		members = append(members, chatMemberResponse{
+			ID:       member.User.ExternalID,
+			Username: member.User.Username,
+			FullName: member.User.FullName,
+			Role:     member.Role,
+			JoinedAt: member.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// InviteToChat godoc
+//
+//	@Summary		Invite a user to a chat
+//	@Description	Invites another user to collaborate on a chat as an editor or viewer
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId		path		string					true	"Chat ID"
+//	@Param			inviteInput	body		inviteToChatInput		true	"Invitee and role"
+//	@Success		201			{object}	chatInvitationResponse	"Created invitation"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		403			{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404			{object}	map[string]interface{}	"Chat or user not found"
+//	@Failure		409			{object}	map[string]interface{}	"Conflict"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/invitations [post]
+func (h *Handler) InviteToChat(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var body inviteToChatInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	invitee, err := h.Users.FindByUsername(c, body.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if _, err := h.Chats.Role(c, chat.ID, invitee.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this chat"})
+		return
+	}
+
+	pending, err := h.Invitations.HasPending(c, chat.ID, invitee.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check pending invitations"})
+		return
+	}
+	if pending {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already has a pending invitation to this chat"})
+		return
+	}
+
+	invitation := models.ChatInvitation{
+		ChatID:    chat.ID,
+		Chat:      chat,
+		InviterID: userModel.ID,
+		Inviter:   userModel,
+		InviteeID: invitee.ID,
+		Invitee:   invitee,
+		Role:      models.ChatRole(body.Role),
+		Status:    models.InvitationStatusPending,
+	}
+
+	if err := h.Invitations.Create(c, &invitation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": newChatInvitationResponse(invitation)})
+}
+
+// GetChatInvitations godoc
+//
+//	@Summary		List a chat's pending invitations
+//	@Description	Lists invitations to a chat that have not been answered yet
+//	@Tags			members
+//	@Produce		json
+//	@Param			chatId	path		string						true	"Chat ID"
+//	@Success		200		{array}		chatInvitationResponse		"Pending invitations"
+//	@Failure		400		{object}	map[string]interface{}		"Bad request"
+//	@Failure		401		{object}	map[string]interface{}		"Unauthorized"
+//	@Failure		403		{object}	map[string]interface{}		"Forbidden"
+//	@Failure		404		{object}	map[string]interface{}		"Chat not found"
+//	@Failure		500		{object}	map[string]interface{}		"Internal server error"
+//	@Router			/chats/{chatId}/invitations [get]
+func (h *Handler) GetChatInvitations(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	invitations, err := h.Invitations.ListPendingForChat(c, chat.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve invitations"})
+		return
+	}
+
+	response := make([]chatInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		response = append(response, newChatInvitationResponse(invitation))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// RevokeChatInvitation godoc
+//
+//	@Summary		Revoke a chat invitation
+//	@Description	Revokes a pending invitation to a chat
+//	@Tags			members
+//	@Param			chatId			path		string					true	"Chat ID"
+//	@Param			invitationId	path		string					true	"Invitation ID"
+//	@Success		200				{object}	map[string]interface{}	"Invitation revoked"
+//	@Failure		400				{object}	map[string]interface{}	"Bad request"
+//	@Failure		401				{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		403				{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404				{object}	map[string]interface{}	"Invitation not found"
+//	@Failure		500				{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/invitations/{invitationId} [delete]
+func (h *Handler) RevokeChatInvitation(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	revoked, err := h.Invitations.Revoke(c, chat.ID, invitationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// UpdateChatMember godoc
+//
+//	@Summary		Change a member's role
+//	@Description	Changes a collaborator's role in a chat. The owner's role cannot be changed.
+//	@Tags			members
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId		path		string					true	"Chat ID"
+//	@Param			userId		path		string					true	"Member's user ID"
+//	@Param			roleInput	body		updateChatMemberInput	true	"New role"
+//	@Success		200			{object}	map[string]interface{}	"Member updated"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		403			{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404			{object}	map[string]interface{}	"Member not found"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/members/{userId} [put]
+func (h *Handler) UpdateChatMember(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var body updateChatMemberInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	member, ok := h.findChatMember(c, chat.ID, memberID)
+	if !ok {
+		return
+	}
+
+	if member.Role == models.ChatRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner's role cannot be changed"})
+		return
+	}
+
+	if err := h.Chats.UpdateMemberRole(c, &member, models.ChatRole(body.Role)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated", "role": body.Role})
+}
+
+// RemoveChatMember godoc
+//
+//	@Summary		Remove a member from a chat
+//	@Description	Removes a collaborator from a chat. Owners can remove anyone else; other members can only remove themselves.
+//	@Tags			members
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Param			userId	path		string					true	"Member's user ID"
+//	@Success		200		{object}	map[string]interface{}	"Member removed"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		403		{object}	map[string]interface{}	"Forbidden"
+//	@Failure		404		{object}	map[string]interface{}	"Member not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/members/{userId} [delete]
+func (h *Handler) RemoveChatMember(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	required := models.ChatRoleOwner
+	if memberID == userModel.ExternalID {
+		required = models.ChatRoleViewer
+	}
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, required, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	member, ok := h.findChatMember(c, chat.ID, memberID)
+	if !ok {
+		return
+	}
+
+	if member.Role == models.ChatRoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot leave their own chat"})
+		return
+	}
+
+	// Recipients are resolved before the removal so the departing member hears about it too
+	memberIDs, err := h.Chats.MemberIDs(c, chat.ID)
+	if err != nil {
+		slog.Error("Failed to resolve chat members for realtime event", "chatId", chat.ExternalID, "error", err)
+	}
+
+	if err := h.Chats.RemoveMember(c, member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	realtime.PublishToUsers(c, h.Broker, memberIDs, realtime.Event{Type: realtime.EventMemberRemoved, ChatID: chat.ExternalID, Data: gin.H{"id": memberID}})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// GetMyInvitations godoc
+//
+//	@Summary		List my invitations
+//	@Description	Lists pending invitations to collaborate on other users' chats
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		chatInvitationResponse	"Pending invitations"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/invitations [get]
+func (h *Handler) GetMyInvitations(c *gin.Context) {
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	invitations, err := h.Invitations.ListPendingForInvitee(c, userModel.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve invitations"})
+		return
+	}
+
+	response := make([]chatInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		response = append(response, newChatInvitationResponse(invitation))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// AcceptInvitation godoc
+//
+//	@Summary		Accept an invitation
+//	@Description	Accepts a pending invitation and joins the chat with the invited role
+//	@Tags			users
+//	@Param			invitationId	path		string					true	"Invitation ID"
+//	@Success		200				{object}	map[string]interface{}	"Invitation accepted"
+//	@Failure		400				{object}	map[string]interface{}	"Bad request"
+//	@Failure		401				{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404				{object}	map[string]interface{}	"Invitation not found"
+//	@Failure		500				{object}	map[string]interface{}	"Internal server error"
+//	@Router			/invitations/{invitationId}/accept [post]
+func (h *Handler) AcceptInvitation(c *gin.Context) {
+	h.answerInvitation(c, models.InvitationStatusAccepted)
+}
+
+// DeclineInvitation godoc
+//
+//	@Summary		Decline an invitation
+//	@Description	Declines a pending invitation to a chat
+//	@Tags			users
+//	@Param			invitationId	path		string					true	"Invitation ID"
+//	@Success		200				{object}	map[string]interface{}	"Invitation declined"
+//	@Failure		400				{object}	map[string]interface{}	"Bad request"
+//	@Failure		401				{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404				{object}	map[string]interface{}	"Invitation not found"
+//	@Failure		500				{object}	map[string]interface{}	"Internal server error"
+//	@Router			/invitations/{invitationId}/decline [post]
+func (h *Handler) DeclineInvitation(c *gin.Context) {
+	h.answerInvitation(c, models.InvitationStatusDeclined)
+}
+
+func (h *Handler) answerInvitation(c *gin.Context, status models.InvitationStatus) {
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	invitation, err := h.Invitations.Answer(c, invitationID, userModel.ID, status)
+	if errors.Is(err, repository.ErrInvitationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer invitation"})
+		return
+	}
+
+	if status == models.InvitationStatusAccepted {
+		realtime.PublishToChatByID(c, h.Broker, h.Chats, invitation.ChatID, realtime.EventMemberJoined, gin.H{"id": userModel.ExternalID, "userName": userModel.Username})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation " + string(status)})
+}
+
+// findChatMember loads a member of the chat by their user's external id, answering the request itself when it cannot
+func (h *Handler) findChatMember(c *gin.Context, chatID uint, userExternalID uuid.UUID) (models.ChatMember, bool) {
+	member, err := h.Chats.FindMember(c, chatID, userExternalID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return models.ChatMember{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve member"})
+		return models.ChatMember{}, false
+	}
+	return member, true
+}
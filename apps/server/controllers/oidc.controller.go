@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+type oidcProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// GetOIDCProviders godoc
+//	@Summary		List SSO providers
+//	@Description	Lists the OIDC providers users can sign in with
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{array}	oidcProviderResponse	"Configured providers"
+//	@Router			/auth/oidc/providers [get]
+func (h *Handler) GetOIDCProviders(c *gin.Context) {
+	configs := h.OIDC.Configs()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	providers := make([]oidcProviderResponse, 0, len(configs))
+	for _, config := range configs {
+		providers = append(providers, oidcProviderResponse{Name: config.Name, DisplayName: config.DisplayName})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": providers})
+}
+
+// StartOIDCLogin godoc
+//	@Summary		Start SSO login
+//	@Description	Redirects to the OIDC provider using the authorization code flow with PKCE
+//	@Tags			auth
+//	@Param			provider	path	string	true	"Provider name"
+//	@Success		302
+//	@Failure		404	{object}	map[string]interface{}	"Unknown provider"
+//	@Failure		502	{object}	map[string]interface{}	"Provider unavailable"
+//	@Router			/auth/oidc/{provider}/login [get]
+func (h *Handler) StartOIDCLogin(c *gin.Context) {
+	h.redirectToOIDCProvider(c, "")
+}
+
+// StartOIDCLink godoc
+//	@Summary		Link an SSO account
+//	@Description	Redirects to the OIDC provider and links the resulting identity to the authenticated user
+//	@Tags			users
+//	@Param			provider	path	string	true	"Provider name"
+//	@Success		302
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404	{object}	map[string]interface{}	"Unknown provider"
+//	@Failure		502	{object}	map[string]interface{}	"Provider unavailable"
+//	@Router			/auth/oidc/{provider}/link [get]
+func (h *Handler) StartOIDCLink(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.redirectToOIDCProvider(c, user.ExternalID.String())
+}
+
+func (h *Handler) redirectToOIDCProvider(c *gin.Context, linkUserID string) {
+	provider, err := h.OIDC.Get(c.Request.Context(), c.Param("provider"))
+	if errors.Is(err, auth.ErrUnknownOIDCProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load sso provider", "provider", c.Param("provider"), "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "SSO provider is unavailable"})
+		return
+	}
+
+	authURL, flow, err := provider.AuthCodeURL(linkUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sso login"})
+		return
+	}
+
+	flowToken, err := auth.SignOIDCFlow(h.Keys, flow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sso login"})
+		return
+	}
+
+	// Lax so the cookie survives the top-level redirect back from the provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(auth.OIDC_FLOW_COOKIE, flowToken, int(auth.OIDC_FLOW_TTL.Seconds()), "/auth/oidc", h.Config.Domain, false, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+//	@Summary		Finish SSO login
+//	@Description	Handles the OIDC provider's redirect, logging in, linking or provisioning the user, then redirects to the client. Users with two-factor authentication are sent to /login/2fa with a pre-auth token instead.
+//	@Tags			auth
+//	@Param			provider	path	string	true	"Provider name"
+//	@Param			code		query	string	true	"Authorization code"
+//	@Param			state		query	string	true	"State"
+//	@Success		302
+//	@Router			/auth/oidc/{provider}/callback [get]
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	flowToken, _ := c.Cookie(auth.OIDC_FLOW_COOKIE)
+	c.SetCookie(auth.OIDC_FLOW_COOKIE, "", -1, "/auth/oidc", h.Config.Domain, false, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		h.redirectToClient(c, "/login", providerError)
+		return
+	}
+
+	flow, err := auth.ParseOIDCFlow(h.Keys, flowToken)
+	if err != nil || flow.Provider != c.Param("provider") || flow.State != c.Query("state") {
+		h.redirectToClient(c, "/login", auth.ErrInvalidOIDCFlow.Error())
+		return
+	}
+
+	provider, err := h.OIDC.Get(c.Request.Context(), flow.Provider)
+	if err != nil {
+		slog.Error("Failed to load sso provider", "provider", flow.Provider, "error", err)
+		h.redirectToClient(c, "/login", "SSO provider is unavailable")
+		return
+	}
+
+	claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), flow)
+	if err != nil {
+		slog.Error("SSO login failed", "provider", flow.Provider, "error", err)
+		h.redirectToClient(c, "/login", "SSO login failed")
+		return
+	}
+
+	user, err := auth.ResolveOIDCUser(c, h.Repositories, provider, claims, flow)
+	if err != nil {
+		message := "SSO login failed"
+		if errors.Is(err, auth.ErrIdentityLinkedElsewhere) || errors.Is(err, auth.ErrLinkUserNotFound) {
+			message = err.Error()
+		} else {
+			slog.Error("Failed to resolve sso user", "provider", flow.Provider, "error", err)
+		}
+		h.redirectToClient(c, "/login", message)
+		return
+	}
+
+	// Linking keeps the session the user started the flow from
+	if flow.LinkUserID != "" {
+		h.redirectToClient(c, "/", "")
+		return
+	}
+
+	// The provider stands in for the password only. The pre-auth token goes in the fragment so it
+	// never reaches server logs, and the client finishes through POST /login/2fa like a password login.
+	if user.TwoFactorEnabledAt != nil {
+		preAuthToken, err := auth.IssuePreAuthToken(h.Keys, user)
+		if err != nil {
+			h.redirectToClient(c, "/login", "SSO login failed")
+			return
+		}
+
+		c.Redirect(http.StatusFound, h.Config.ClientAddress+"/login/2fa#"+url.Values{"preAuthToken": {preAuthToken}}.Encode())
+		return
+	}
+
+	tokens, err := auth.StartSession(c, h.Sessions, h.Keys, user)
+	if errors.Is(err, auth.ErrAccountSuspended) {
+		h.redirectToClient(c, "/login", err.Error())
+		return
+	}
+	if err != nil {
+		h.redirectToClient(c, "/login", "SSO login failed")
+		return
+	}
+
+	auth.SetAuthCookies(c, h.Config.Domain, tokens)
+	h.redirectToClient(c, "/", "")
+}
+
+func (h *Handler) redirectToClient(c *gin.Context, path string, errorMessage string) {
+	target := h.Config.ClientAddress + path
+	if errorMessage != "" {
+		target += "?" + url.Values{"error": {errorMessage}}.Encode()
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// GetIdentities godoc
+//	@Summary		List my SSO identities
+//	@Description	Lists the OIDC identities linked to the authenticated user
+//	@Tags			users
+//	@Success		200	{array}		models.UserIdentity		"Linked identities"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/identities [get]
+func (h *Handler) GetIdentities(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	identities, err := h.Identities.ListForUser(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": identities})
+}
+
+// UnlinkIdentity godoc
+//	@Summary		Unlink an SSO identity
+//	@Description	Removes an OIDC identity from the authenticated user. The last sign-in method cannot be removed.
+//	@Tags			users
+//	@Param			identityId	path		string					true	"Identity ID"
+//	@Success		200			{object}	map[string]interface{}	"Identity unlinked"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404			{object}	map[string]interface{}	"Identity not found"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/identities/{identityId} [delete]
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	identityID, err := uuid.Parse(c.Param("identityId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	identity, err := h.Identities.FindForUser(c, identityID, user.ID)
+	if errors.Is(err, repository.ErrIdentityNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+
+	count, err := h.Identities.CountForUser(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	if count == 1 && user.PasswordHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot unlink your only sign-in method"})
+		return
+	}
+
+	if err := h.Identities.Delete(c, identity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+}
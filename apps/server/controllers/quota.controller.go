@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
+)
+
+// GetMyQuota godoc
+//	@Summary		Get my quota
+//	@Description	Reports the authenticated user's plan and how much of its daily and monthly quota is used
+//	@Tags			users
+//	@Success		200	{object}	quota.Status			"Quota status"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/quota [get]
+func (h *Handler) GetMyQuota(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	status, err := quota.GetStatus(c, h.Usage, h.Plans, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve quota"})
+		return
+	}
+
+	quota.SetHeaders(c, status)
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
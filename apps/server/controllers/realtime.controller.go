@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
+)
+
+const (
+	realtimeWriteWait  = 10 * time.Second
+	realtimePongWait   = 60 * time.Second
+	realtimePingPeriod = (realtimePongWait * 9) / 10
+	realtimeMaxMessage = 1024
+)
+
+func (h *Handler) upgrader() websocket.Upgrader {
+	return websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || origin == h.Config.ClientAddress
+		},
+	}
+}
+
+type realtimeClientMessage struct {
+	Type   realtime.EventType `json:"type"`
+	ChatID uuid.UUID          `json:"chatId"`
+}
+
+// ConnectRealtime godoc
+//
+//	@Summary		Subscribe to realtime updates
+//	@Description	Upgrades to a WebSocket that pushes new messages, typing and generation indicators, agent changes and chat renames for every chat the user belongs to. Clients may send {"type":"typing","chatId":"..."}.
+//	@Tags			realtime
+//	@Success		101	{string}	string					"Switching protocols"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Router			/ws [get]
+func (h *Handler) ConnectRealtime(c *gin.Context) {
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	upgrader := h.upgrader()
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already responded with an error
+		slog.Warn("Failed to upgrade realtime connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	subscription := h.Broker.Subscribe(userModel.ID)
+	defer subscription.Unsubscribe()
+
+	closed := make(chan struct{})
+	go h.readRealtimeClient(c.Request.Context(), conn, userModel, closed)
+
+	ticker := time.NewTicker(realtimePingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-subscription.Events:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRealtimeClient relays typing indicators and keeps the connection alive until the client goes away
+func (h *Handler) readRealtimeClient(ctx context.Context, conn *websocket.Conn, user models.User, closed chan<- struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(realtimeMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	})
+
+	for {
+		var message realtimeClientMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			return
+		}
+
+		if message.Type != realtime.EventTyping {
+			continue
+		}
+
+		chat, _, err := h.Chats.FindForMember(ctx, message.ChatID, user.ID, models.ChatRoleEditor, repository.ChatPreload{})
+		if err != nil {
+			continue
+		}
+		realtime.PublishToChat(ctx, h.Broker, h.Chats, chat, realtime.EventTyping, realtime.ActivityPayload{Name: user.Username})
+	}
+}
@@ -2,10 +2,9 @@ package controllers
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
-	"github.com/somtojf/trio/initializers"
+	"github.com/somtojf/trio/auth"
 	"github.com/somtojf/trio/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -17,7 +16,7 @@ type passwordResetRequest struct {
 
 // ResetPassword godoc
 //	@Summary		Reset user password
-//	@Description	Resets the password for the authenticated user
+//	@Description	Resets the password for the authenticated user and revokes all of their sessions
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
@@ -27,7 +26,7 @@ type passwordResetRequest struct {
 //	@Failure		401						{object}	map[string]interface{}	"Unauthorized"
 //	@Failure		500						{object}	map[string]interface{}	"Internal server error"
 //	@Router			/reset-password [post]
-func ResetPassword(c *gin.Context) {
+func (h *Handler) ResetPassword(c *gin.Context) {
 	var body passwordResetRequest
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -57,11 +56,16 @@ func ResetPassword(c *gin.Context) {
 
 	// Update the password in the database
 	user.PasswordHash = string(hashedPassword)
-	if err := initializers.DB.Save(&user).Error; err != nil {
+	if err := h.Users.Update(c, &user, "password_hash"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
-	c.SetCookie("Access_Token", "", -1, "/", os.Getenv("DOMAIN"), false, true)
+	if err := auth.RevokeAllSessions(c, h.Sessions, user.ID, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password updated but existing sessions could not be revoked"})
+		return
+	}
+
+	auth.ClearAuthCookies(c, h.Config.Domain)
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully. Please login with new password"})
 }
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+)
+
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// RefreshSession godoc
+//	@Summary		Refresh access token
+//	@Description	Exchanges the refresh token cookie for a new access token and a rotated refresh token
+//	@Tags			auth
+//	@Success		200	{object}	map[string]interface{}	"Tokens refreshed"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/refresh [post]
+func (h *Handler) RefreshSession(c *gin.Context) {
+	refreshToken, err := c.Cookie(auth.REFRESH_TOKEN_COOKIE)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokens, _, err := auth.RotateSession(c, h.Sessions, h.Keys, refreshToken)
+	if err != nil {
+		auth.ClearAuthCookies(c, h.Config.Domain)
+		switch {
+		case errors.Is(err, auth.ErrSessionNotFound), errors.Is(err, auth.ErrSessionRevoked), errors.Is(err, auth.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+
+	auth.SetAuthCookies(c, h.Config.Domain, tokens)
+	c.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// GetSessions godoc
+//	@Summary		List my sessions
+//	@Description	Lists the active sessions of the authenticated user
+//	@Tags			users
+//	@Success		200	{array}		sessionResponse			"Active sessions"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/sessions [get]
+func (h *Handler) GetSessions(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	current, _ := c.Value("currentSession").(models.Session)
+
+	sessions, err := h.Sessions.ListActive(c, user.ID, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve sessions"})
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{Session: session, Current: session.ID == current.ID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// RevokeSession godoc
+//	@Summary		Revoke a session
+//	@Description	Logs out one of the authenticated user's sessions
+//	@Tags			users
+//	@Param			sessionId	path		string					true	"Session ID"
+//	@Success		200			{object}	map[string]interface{}	"Session revoked"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404			{object}	map[string]interface{}	"Session not found"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/sessions/{sessionId} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := h.Sessions.FindForUser(c, sessionID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := auth.RevokeSession(c, h.Sessions, session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	if current, ok := c.Value("currentSession").(models.Session); ok && current.ID == session.ID {
+		auth.ClearAuthCookies(c, h.Config.Domain)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/transcript"
+	"github.com/somtojf/trio/utils"
+)
+
+type createChatShareInput struct {
+	// Hours until the link stops working. Zero means the link never expires.
+	ExpiresInHours int `json:"expiresInHours" binding:"min=0,max=8760"`
+	// Freeze the shared transcript at the time of sharing
+	Snapshot bool `json:"snapshot"`
+}
+
+// CreateChatShare godoc
+//
+//	@Summary		Share a chat
+//	@Description	Mints a revocable, read-only public link for a chat. The token is only returned once.
+//	@Tags			shares
+//	@Accept			json
+//	@Produce		json
+//	@Param			chatId		path		string					true	"Chat ID"
+//	@Param			shareInput	body		createChatShareInput	true	"Share options"
+//	@Success		201			{object}	map[string]interface{}	"Created share link"
+//	@Failure		400			{object}	map[string]interface{}	"Bad request"
+//	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404			{object}	map[string]interface{}	"Chat not found"
+//	@Failure		500			{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/shares [post]
+func (h *Handler) CreateChatShare(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	var body createChatShareInput
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{Agents: body.Snapshot})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	token, err := utils.GenerateToken(utils.DEFAULT_TOKEN_BYTES)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+
+	now := time.Now().UTC()
+	share := models.ChatShare{
+		TokenHash: utils.HashToken(token),
+		ChatID:    chat.ID,
+		UserID:    userModel.ID,
+	}
+	if body.Snapshot {
+		share.SnapshotAt = &now
+		share.SnapshotChatName = chat.ChatName
+		share.SnapshotAgents = transcript.SnapshotAgents(chat.Agents)
+	}
+	if body.ExpiresInHours > 0 {
+		expiresAt := now.Add(time.Duration(body.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expiresAt
+	}
+
+	if err := h.Shares.Create(c, &share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":  share,
+		"token": token,
+		"path":  "/shared/" + token,
+	})
+}
+
+// GetChatShares godoc
+//
+//	@Summary		List a chat's share links
+//	@Description	Lists share links of a chat, including expired and revoked ones
+//	@Tags			shares
+//	@Produce		json
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Success		200		{array}		models.ChatShare		"Share links"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404		{object}	map[string]interface{}	"Chat not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/shares [get]
+func (h *Handler) GetChatShares(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	shares, err := h.Shares.ListForChat(c, chat.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve share links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shares})
+}
+
+// RevokeChatShare godoc
+//
+//	@Summary		Revoke a share link
+//	@Description	Revokes a chat's share link so the public URL stops working
+//	@Tags			shares
+//	@Param			chatId	path		string					true	"Chat ID"
+//	@Param			shareId	path		string					true	"Share ID"
+//	@Success		200		{object}	map[string]interface{}	"Share link revoked"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404		{object}	map[string]interface{}	"Share link not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/chats/{chatId}/shares/{shareId} [delete]
+func (h *Handler) RevokeChatShare(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	shareID, err := uuid.Parse(c.Param("shareId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return
+	}
+
+	currentUser, exists := c.Get("currentUser")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userModel := currentUser.(models.User)
+
+	chat, _, err := h.Chats.FindForMember(c, chatID, userModel.ID, models.ChatRoleOwner, repository.ChatPreload{})
+	if err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	share, err := h.Shares.FindForChat(c, chat.ID, shareID)
+	if errors.Is(err, repository.ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+
+	if err := h.Shares.Revoke(c, &share, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked", "data": share})
+}
+
+// GetSharedChat godoc
+//
+//	@Summary		View a shared chat
+//	@Description	Renders the read-only transcript behind a share link. No authentication required.
+//	@Description	Senders are shown by name only. A snapshot link shows the chat's name, agents and messages as they were when it was shared.
+//	@Tags			shares
+//	@Produce		html,json,plain
+//	@Param			token	path		string					true	"Share token"
+//	@Param			format	query		string					false	"Transcript format (html, md or json)"	default(html)
+//	@Success		200		{file}		file					"Shared chat transcript"
+//	@Failure		400		{object}	map[string]interface{}	"Bad request"
+//	@Failure		404		{object}	map[string]interface{}	"Share link not found"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/shared/{token} [get]
+func (h *Handler) GetSharedChat(c *gin.Context) {
+	format := transcript.Format(c.DefaultQuery("format", string(transcript.FormatHTML)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be one of md, json or html"})
+		return
+	}
+
+	share, err := h.Shares.FindByTokenHash(c, utils.HashToken(c.Param("token")))
+	if errors.Is(err, repository.ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve share link"})
+		return
+	}
+
+	// A deleted chat leaves a zero value
+	if !share.IsActive(time.Now()) || share.Chat.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	var cutoff time.Time
+	if share.SnapshotAt != nil {
+		cutoff = *share.SnapshotAt
+	}
+
+	chatTranscript, err := transcript.LoadUntil(c, h.Repositories, share.Chat, cutoff)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build chat transcript"})
+		return
+	}
+	if share.SnapshotAt != nil {
+		chatTranscript.Freeze(share.SnapshotChatName, share.SnapshotAgents)
+	}
+	chatTranscript.Anonymize()
+
+	var buffer bytes.Buffer
+	if err := chatTranscript.Render(&buffer, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render chat transcript"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, format.ContentType(), buffer.Bytes())
+}
@@ -1,10 +1,11 @@
 package controllers
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/somtojf/trio/initializers"
+	"github.com/somtojf/trio/auth"
 	"github.com/somtojf/trio/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,12 +13,13 @@ import (
 type signUpInput struct {
 	Username string `json:"userName" binding:"required,max=20"`
 	FullName string `json:"fullName" binding:"required,max=50"`
+	Email    string `json:"email" binding:"required,email,max=254"`
 	Password string `json:"password" binding:"required,max=20,min=8"`
 }
 
 // Signup godoc
 //	@Summary		Signup a new user
-//	@Description	Creates a new user account
+//	@Description	Creates a new user account and emails a link to verify the address
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
@@ -26,7 +28,7 @@ type signUpInput struct {
 //	@Failure		400			{object}	map[string]interface{}	"Bad request"
 //	@Failure		500			{object}	map[string]interface{}	"Internal server error"
 //	@Router			/signup [post]
-func Signup(c *gin.Context) {
+func (h *Handler) Signup(c *gin.Context) {
 	var body signUpInput
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -34,14 +36,17 @@ func Signup(c *gin.Context) {
 		return
 	}
 
-	var userFound models.User
-	initializers.DB.Where("username=?", body.Username).Find(&userFound)
-
-	if userFound.ID != 0 {
+	if _, err := h.Users.FindByUsername(c, body.Username); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "username taken"})
 		return
 	}
 
+	email := auth.NormalizeEmail(body.Email)
+	if taken, _ := h.Users.EmailTaken(c, email, 0); taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email taken"})
+		return
+	}
+
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -51,10 +56,18 @@ func Signup(c *gin.Context) {
 	user := models.User{
 		Username:     body.Username,
 		FullName:     body.FullName,
+		Email:        &email,
 		PasswordHash: string(passwordHash),
 	}
 
-	initializers.DB.Create(&user)
+	if err := h.Users.Create(c, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+
+	if err := auth.SendVerificationEmail(c, h.UserTokens, h.Mailer, h.Config.ClientAddress, user); err != nil {
+		slog.Error("Failed to send verification email", "userId", user.ExternalID, "error", err)
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "account created successfully",
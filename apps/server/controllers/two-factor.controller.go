@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+)
+
+type twoFactorCodeInput struct {
+	// A current TOTP code, or an unused recovery code where noted
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// GetTwoFactorStatus godoc
+//	@Summary		Get two-factor status
+//	@Description	Reports whether two-factor authentication is enabled and how many recovery codes remain
+//	@Tags			two-factor
+//	@Success		200	{object}	map[string]interface{}	"Two-factor status"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/2fa [get]
+func (h *Handler) GetTwoFactorStatus(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	remaining, err := auth.CountRecoveryCodes(c, h.TwoFactor, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"enabled":                user.TwoFactorEnabledAt != nil,
+		"enabledAt":              user.TwoFactorEnabledAt,
+		"recoveryCodesRemaining": remaining,
+	}})
+}
+
+// BeginTOTPEnrollment godoc
+//	@Summary		Start TOTP enrollment
+//	@Description	Generates a TOTP secret and otpauth:// provisioning URI for an authenticator app. Two-factor authentication is enabled once a code is verified.
+//	@Tags			two-factor
+//	@Success		200	{object}	auth.TOTPEnrollment		"Secret and provisioning URI"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		409	{object}	map[string]interface{}	"Already enabled"
+//	@Failure		500	{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/2fa/totp [post]
+func (h *Handler) BeginTOTPEnrollment(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	enrollment, err := auth.BeginTOTPEnrollment(c, h.TwoFactor, h.Keys, user)
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": enrollment})
+}
+
+// ConfirmTOTPEnrollment godoc
+//	@Summary		Verify TOTP enrollment
+//	@Description	Enables two-factor authentication with a code from the authenticator app and returns recovery codes, which are only shown once
+//	@Tags			two-factor
+//	@Accept			json
+//	@Produce		json
+//	@Param			twoFactorCodeInput	body		twoFactorCodeInput		true	"TOTP code"
+//	@Success		200					{object}	map[string]interface{}	"Recovery codes"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid code"
+//	@Failure		401					{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		409					{object}	map[string]interface{}	"Already enabled"
+//	@Failure		500					{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/2fa/totp/verify [post]
+func (h *Handler) ConfirmTOTPEnrollment(c *gin.Context) {
+	var body twoFactorCodeInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	codes, err := auth.ConfirmTOTPEnrollment(c, h.TwoFactor, h.Keys, user, body.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "data": gin.H{"recoveryCodes": codes}})
+}
+
+// RegenerateRecoveryCodes godoc
+//	@Summary		Regenerate recovery codes
+//	@Description	Replaces all recovery codes after checking a TOTP or recovery code
+//	@Tags			two-factor
+//	@Accept			json
+//	@Produce		json
+//	@Param			twoFactorCodeInput	body		twoFactorCodeInput		true	"TOTP or recovery code"
+//	@Success		200					{object}	map[string]interface{}	"Recovery codes"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid code"
+//	@Failure		401					{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500					{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var body twoFactorCodeInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	codes, err := auth.RegenerateRecoveryCodes(c, h.TwoFactor, h.Keys, user, body.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recoveryCodes": codes}})
+}
+
+// DisableTwoFactor godoc
+//	@Summary		Disable two-factor authentication
+//	@Description	Turns off two-factor authentication after checking a TOTP or recovery code
+//	@Tags			two-factor
+//	@Accept			json
+//	@Produce		json
+//	@Param			twoFactorCodeInput	body		twoFactorCodeInput		true	"TOTP or recovery code"
+//	@Success		200					{object}	map[string]interface{}	"Disabled"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid code"
+//	@Failure		401					{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500					{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/2fa [delete]
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	var body twoFactorCodeInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := auth.DisableTwoFactor(c, h.TwoFactor, h.Keys, user, body.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidSecondFactor), errors.Is(err, auth.ErrNoPendingEnrollment), errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occured"})
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+type dailyUsage struct {
+	repository.UsageTotals
+	Day          string     `json:"day"`
+	ChatID       *uuid.UUID `json:"chatId"`
+	ChatName     string     `json:"chatName,omitempty"`
+	AgentID      *uuid.UUID `json:"agentId"`
+	AgentName    string     `json:"agentName,omitempty"`
+	Errors       int64      `json:"errors"`
+	AvgLatencyMs float64    `json:"avgLatencyMs"`
+}
+
+// GetMyUsage godoc
+//	@Summary		Get my usage
+//	@Description	Aggregates the authenticated user's model calls per UTC day, chat and agent
+//	@Tags			users
+//	@Param			days	query		int						false	"Number of days to include"
+//	@Success		200		{object}	map[string]interface{}	"Daily usage and totals"
+//	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		500		{object}	map[string]interface{}	"Internal server error"
+//	@Router			/me/usage [get]
+func (h *Handler) GetMyUsage(c *gin.Context) {
+	user, ok := c.Value("currentUser").(models.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	since := usageSince(c)
+
+	totals, err := h.Usage.Totals(c, since, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+
+	rows, err := h.Usage.Daily(c, user.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+
+	var chatIDs, agentIDs []uint
+	for _, row := range rows {
+		if row.ChatID != nil {
+			chatIDs = append(chatIDs, *row.ChatID)
+		}
+		if row.AgentID != nil {
+			agentIDs = append(agentIDs, *row.AgentID)
+		}
+	}
+
+	// Deleted chats and agents still show up in past usage
+	chats, err := h.Chats.FindByIDs(c, chatIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+	agents, err := h.Agents.FindByIDs(c, agentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve usage"})
+		return
+	}
+	chatsByID := make(map[uint]models.Chat, len(chats))
+	for _, chat := range chats {
+		chatsByID[chat.ID] = chat
+	}
+	agentsByID := make(map[uint]models.Agent, len(agents))
+	for _, agent := range agents {
+		agentsByID[agent.ID] = agent
+	}
+
+	days := make([]dailyUsage, 0, len(rows))
+	for _, row := range rows {
+		usage := dailyUsage{
+			UsageTotals:  row.UsageTotals,
+			Day:          row.Day.Format(time.DateOnly),
+			Errors:       row.Errors,
+			AvgLatencyMs: row.AvgLatencyMs,
+		}
+		if chat, ok := chatsByID[derefID(row.ChatID)]; ok {
+			usage.ChatID = &chat.ExternalID
+			usage.ChatName = chat.ChatName
+		}
+		if agent, ok := agentsByID[derefID(row.AgentID)]; ok {
+			usage.AgentID = &agent.ExternalID
+			usage.AgentName = agent.Name
+		}
+		days = append(days, usage)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"since": since, "totals": totals, "days": days}})
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+	// StatusDegraded means only optional dependencies are down, so the server can still take traffic
+	StatusDegraded Status = "degraded"
+)
+
+var ErrNotConfigured = errors.New("not configured")
+
+// Check probes one dependency. Optional dependencies being down degrades the report instead of failing it.
+type Check struct {
+	Name     string
+	Optional bool
+	Probe    func(ctx context.Context) error
+}
+
+type Result struct {
+	Status    Status `json:"status"`
+	Optional  bool   `json:"optional,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready reports whether every required dependency is up
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// Run probes every check at once, giving each until timeout
+func Run(ctx context.Context, timeout time.Duration, checks []Check) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := probe(ctx, timeout, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status == StatusDown {
+				if !check.Optional {
+					report.Status = StatusDown
+				} else if report.Status == StatusUp {
+					report.Status = StatusDegraded
+				}
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func probe(ctx context.Context, timeout time.Duration, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Run the probe on its own goroutine so one that ignores its context still cannot hold up the report
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check.Probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: StatusUp, Optional: check.Optional, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out after " + timeout.String()
+		}
+	}
+	return result
+}
+
+// Cached reuses probe's last result for ttl, for dependencies that are slow, rate limited or
+// billed per call. Results from probes whose context ended are not kept.
+func Cached(ttl time.Duration, probe func(ctx context.Context) error) func(ctx context.Context) error {
+	var mu sync.Mutex
+	var checkedAt time.Time
+	var last error
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return last
+		}
+		err := probe(ctx)
+		if ctx.Err() == nil {
+			checkedAt, last = time.Now(), err
+		}
+		return err
+	}
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func up(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestRunReportsEachDependency(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		checks []Check
+		want   Status
+	}{
+		{"all up", []Check{{Name: "postgres", Probe: up}, {Name: "qdrant", Optional: true, Probe: up}}, StatusUp},
+		{"optional down", []Check{{Name: "postgres", Probe: up}, {Name: "qdrant", Optional: true, Probe: down}}, StatusDegraded},
+		{"required down", []Check{{Name: "postgres", Probe: down}, {Name: "qdrant", Optional: true, Probe: down}}, StatusDown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Run(context.Background(), time.Second, tc.checks)
+			if report.Status != tc.want {
+				t.Fatalf("got %s, want %s: %+v", report.Status, tc.want, report)
+			}
+			if report.Ready() != (tc.want != StatusDown) {
+				t.Fatalf("Ready() disagrees with status %s", report.Status)
+			}
+			if len(report.Checks) != len(tc.checks) {
+				t.Fatalf("got %d results, want %d", len(report.Checks), len(tc.checks))
+			}
+		})
+	}
+}
+
+func TestRunTimesOutHungProbes(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	report := Run(context.Background(), 20*time.Millisecond, []Check{
+		// Ignores its context entirely
+		{Name: "llm", Probe: func(ctx context.Context) error { <-release; return nil }},
+		{Name: "postgres", Probe: up},
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("a hung probe held the report for %s", elapsed)
+	}
+	if result := report.Checks["llm"]; result.Status != StatusDown || result.Error != "timed out after 20ms" {
+		t.Fatalf("got %+v, want a timeout", result)
+	}
+	if report.Checks["postgres"].Status != StatusUp {
+		t.Fatalf("a hung probe should not affect the others: %+v", report.Checks)
+	}
+}
+
+func TestCachedReusesResultsUntilTTL(t *testing.T) {
+	calls := 0
+	probe := Cached(50*time.Millisecond, func(ctx context.Context) error {
+		calls++
+		return down(ctx)
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := probe(context.Background()); err == nil {
+			t.Fatal("expected the cached failure")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("got %d probes, want 1 within the TTL", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	probe(context.Background())
+	if calls != 2 {
+		t.Fatalf("got %d probes, want a fresh one after the TTL", calls)
+	}
+}
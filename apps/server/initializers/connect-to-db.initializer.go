@@ -1,22 +1,17 @@
 package initializers
 
 import (
-	"log"
-	"os"
+	"fmt"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func ConnectToDb() {
-	var err error
-
-	dsn := os.Getenv("DATABASE_URL")
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-
+func ConnectToDb(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to connect to database")
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+
+	return db, nil
 }
@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+type drainingKey struct{}
+
+// Generations tracks in-flight model generations so shutdown can let them finish, or stop them
+// at a checkpoint, before the database and model clients are closed
+type Generations struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	draining chan struct{}
+	closed   bool
+	cancels  map[uint64]context.CancelFunc
+	next     uint64
+}
+
+func NewGenerations() *Generations {
+	return &Generations{
+		draining: make(chan struct{}),
+		cancels:  make(map[uint64]context.CancelFunc),
+	}
+}
+
+// Begin registers a generation. The returned context is canceled if the generation is still running
+// when the shutdown deadline passes, and done must be called once it returns.
+func (g *Generations) Begin(parent context.Context) (context.Context, func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, nil, ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(parent, drainingKey{}, (<-chan struct{})(g.draining)))
+	id := g.next
+	g.next++
+	g.cancels[id] = cancel
+	g.wg.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			g.mu.Lock()
+			delete(g.cancels, id)
+			g.mu.Unlock()
+			cancel()
+			g.wg.Done()
+		})
+	}
+	return ctx, done, nil
+}
+
+// Drain refuses new generations and waits for the active ones until ctx is done. Generations
+// still running then are canceled, and Drain waits for them to return before reporting ctx's error.
+func (g *Generations) Drain(ctx context.Context) error {
+	g.mu.Lock()
+	if !g.closed {
+		g.closed = true
+		close(g.draining)
+	}
+	g.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	for _, cancel := range g.cancels {
+		cancel()
+	}
+	g.mu.Unlock()
+
+	<-finished
+	return ctx.Err()
+}
+
+// ShuttingDown reports whether Drain has been called
+func (g *Generations) ShuttingDown() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closed
+}
+
+// Draining returns a channel that is closed once shutdown starts. Generations that run for several
+// turns check it between turns and stop after saving the current one. It is nil, and so never
+// closes, for contexts that did not come from Begin.
+func Draining(ctx context.Context) <-chan struct{} {
+	draining, _ := ctx.Value(drainingKey{}).(<-chan struct{})
+	return draining
+}
@@ -0,0 +1,66 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForGenerations(t *testing.T) {
+	generations := NewGenerations()
+	ctx, done, err := generations.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- generations.Drain(context.Background()) }()
+
+	select {
+	case <-Draining(ctx):
+	case <-time.After(time.Second):
+		t.Fatal("Draining was not closed once Drain started")
+	}
+	select {
+	case <-drained:
+		t.Fatal("Drain returned while a generation was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+	if err := <-drained; err != nil {
+		t.Fatalf("got %v, want nil once the generation finished", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("a finished generation's context should be released")
+	}
+}
+
+func TestDrainCancelsGenerationsAtDeadline(t *testing.T) {
+	generations := NewGenerations()
+	ctx, done, err := generations.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		done()
+	}()
+
+	deadline, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := generations.Drain(deadline); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline error", err)
+	}
+
+	if _, _, err := generations.Begin(context.Background()); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("got %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestDrainingIsNilOutsideGenerations(t *testing.T) {
+	if Draining(context.Background()) != nil {
+		t.Fatal("contexts that did not come from Begin should never drain")
+	}
+}
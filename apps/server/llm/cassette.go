@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type CassetteMode string
+
+const (
+	// CassetteRecord forwards every call and saves the request and response
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay answers from saved calls only and never reaches a real model
+	CassetteReplay CassetteMode = "replay"
+)
+
+var ErrCassetteMiss = errors.New("no recorded call matches this request")
+
+// CassetteCall is one recorded request and its response. Failed calls are recorded too,
+// so a replay fails the same way the original session did.
+type CassetteCall struct {
+	Model  string      `json:"model"`
+	Prompt string      `json:"prompt,omitempty"`
+	Texts  []string    `json:"texts,omitempty"`
+	Result *Completion `json:"result,omitempty"`
+	// Vectors holds the response to an Embed call
+	Vectors [][]float32 `json:"vectors,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	// BlockedReason is set when Error came from a *BlockedError
+	BlockedReason string `json:"blockedReason,omitempty"`
+}
+
+// cassetteEntry is one file of the cassette: every call made with the same key, in order
+type cassetteEntry struct {
+	Key   string         `json:"key"`
+	Calls []CassetteCall `json:"calls"`
+}
+
+// CassetteProvider records calls to a real provider into a directory, one JSON file per
+// normalized request, and replays them later without the real provider. A request made several
+// times replays its recorded responses in order and then repeats the last one. Recording over an
+// existing cassette replaces each file the new session writes to and leaves the others alone.
+type CassetteProvider struct {
+	mu     sync.Mutex
+	dir    string
+	mode   CassetteMode
+	inner  Provider
+	replay map[string]int
+	// recorded holds the keys saved by this process, whose files are appended to rather than replaced
+	recorded map[string]bool
+}
+
+// NewCassetteProvider wraps inner, which may be nil when replaying
+func NewCassetteProvider(dir string, mode CassetteMode, inner Provider) (*CassetteProvider, error) {
+	switch mode {
+	case CassetteRecord:
+		if inner == nil {
+			return nil, errors.New("recording a cassette needs a provider to record")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	case CassetteReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q, expected record or replay", mode)
+	}
+
+	return &CassetteProvider{dir: dir, mode: mode, inner: inner, replay: make(map[string]int), recorded: make(map[string]bool)}, nil
+}
+
+func (p *CassetteProvider) Generate(ctx context.Context, model string, prompt string) (Completion, error) {
+	key := CassetteKey("generate", model, prompt)
+
+	if p.mode == CassetteReplay {
+		call, err := p.next(key)
+		if err != nil {
+			return Completion{}, err
+		}
+		if call.Error != "" {
+			return Completion{}, call.err()
+		}
+		return *call.Result, nil
+	}
+
+	completion, err := p.inner.Generate(ctx, model, prompt)
+	call := CassetteCall{Model: model, Prompt: prompt}
+	if err != nil {
+		call.recordError(err)
+	} else {
+		call.Result = &completion
+	}
+	if saveErr := p.save(key, call); saveErr != nil {
+		return Completion{}, saveErr
+	}
+	return completion, err
+}
+
+func (p *CassetteProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	key := CassetteKey("embed", model, texts...)
+
+	if p.mode == CassetteReplay {
+		call, err := p.next(key)
+		if err != nil {
+			return nil, err
+		}
+		if call.Error != "" {
+			return nil, call.err()
+		}
+		return call.Vectors, nil
+	}
+
+	vectors, err := p.inner.Embed(ctx, model, texts)
+	call := CassetteCall{Model: model, Texts: texts, Vectors: vectors}
+	if err != nil {
+		call.recordError(err)
+	}
+	if saveErr := p.save(key, call); saveErr != nil {
+		return nil, saveErr
+	}
+	return vectors, err
+}
+
+// Ping checks the recorded provider. Replaying needs nothing beyond the cassette directory.
+func (p *CassetteProvider) Ping(ctx context.Context) error {
+	if p.mode == CassetteReplay {
+		_, err := os.Stat(p.dir)
+		return err
+	}
+	if pinger, ok := p.inner.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (p *CassetteProvider) Close() error {
+	if p.inner == nil {
+		return nil
+	}
+	return p.inner.Close()
+}
+
+// CassetteKey hashes the call kind, the model and the normalized inputs. Whitespace differences,
+// such as a prompt template being re-indented, do not change the key.
+func CassetteKey(kind string, model string, inputs ...string) string {
+	hash := sha256.New()
+	hash.Write([]byte(kind + "\x00" + model))
+	for _, input := range inputs {
+		hash.Write([]byte("\x00" + NormalizePrompt(input)))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NormalizePrompt trims every line, collapses runs of spaces and tabs, and drops blank lines
+func NormalizePrompt(prompt string) string {
+	lines := strings.Split(strings.ReplaceAll(prompt, "\r\n", "\n"), "\n")
+	normalized := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			normalized = append(normalized, line)
+		}
+	}
+	return strings.Join(normalized, "\n")
+}
+
+func (p *CassetteProvider) path(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+func (p *CassetteProvider) next(key string) (CassetteCall, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, err := p.load(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return CassetteCall{}, fmt.Errorf("%w (key %s)", ErrCassetteMiss, key)
+	}
+	if err != nil {
+		return CassetteCall{}, err
+	}
+	if len(entry.Calls) == 0 {
+		return CassetteCall{}, fmt.Errorf("%w (key %s)", ErrCassetteMiss, key)
+	}
+
+	i := p.replay[key]
+	if i >= len(entry.Calls) {
+		i = len(entry.Calls) - 1
+	}
+	p.replay[key] = i + 1
+
+	call := entry.Calls[i]
+	if call.Error == "" && call.Result == nil && call.Vectors == nil {
+		return CassetteCall{}, fmt.Errorf("recorded call %d for key %s has no response", i, key)
+	}
+	return call, nil
+}
+
+func (p *CassetteProvider) load(key string) (cassetteEntry, error) {
+	data, err := os.ReadFile(p.path(key))
+	if err != nil {
+		return cassetteEntry{}, err
+	}
+
+	var entry cassetteEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return cassetteEntry{}, fmt.Errorf("reading cassette %s: %w", p.path(key), err)
+	}
+	return entry, nil
+}
+
+// save appends the call to its key's file, writing to a temporary file first so a crash
+// never leaves a half-written cassette. The first save of a key starts the file over, so calls
+// left by an earlier recording are not replayed ahead of this one's.
+func (p *CassetteProvider) save(key string, call CassetteCall) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var entry cassetteEntry
+	if p.recorded[key] {
+		loaded, err := p.load(key)
+		if err != nil {
+			return err
+		}
+		entry = loaded
+	}
+	entry.Key = key
+	entry.Calls = append(entry.Calls, call)
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(p.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p.path(key)); err != nil {
+		return err
+	}
+	p.recorded[key] = true
+	return nil
+}
+
+func (c *CassetteCall) recordError(err error) {
+	c.Error = err.Error()
+
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		c.BlockedReason = blocked.FinishReason
+	}
+}
+
+func (c CassetteCall) err() error {
+	if c.BlockedReason != "" {
+		return &BlockedError{FinishReason: c.BlockedReason, Err: errors.New(c.Error)}
+	}
+	return errors.New(c.Error)
+}
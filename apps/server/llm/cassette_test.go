@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	fake := newTestFake(t, FakeFixtures{Rules: []FakeRule{
+		{Prompt: "unsafe", Error: "blocked"},
+		{Agent: "Ada", Replies: []string{"First answer", "Second answer"}},
+	}})
+
+	recorder, err := NewCassetteProvider(dir, CassetteRecord, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	prompt := "\n\tYou are Ada, an AI agent.\n\tWhat now?\n"
+	var recorded []Completion
+	for i := 0; i < 2; i++ {
+		completion, err := recorder.Generate(ctx, "gemini-1.5-flash", prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, completion)
+	}
+	if _, err := recorder.Generate(ctx, "gemini-1.5-flash", "something unsafe"); err == nil {
+		t.Fatal("expected the blocked prompt to fail while recording")
+	}
+	vectors, err := recorder.Embed(ctx, "text-embedding-004", []string{"hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("got %d cassette files, want one per distinct request: %v", len(files), files)
+	}
+
+	player, err := NewCassetteProvider(dir, CassetteReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-indenting the prompt template must not change the key
+	reindented := "You are Ada,   an AI agent.\nWhat now?"
+	for i, want := range append(recorded, recorded[1]) {
+		got, err := player.Generate(ctx, "gemini-1.5-flash", reindented)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("replay %d: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	_, err = player.Generate(ctx, "gemini-1.5-flash", "something unsafe")
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.FinishReason != "SAFETY" {
+		t.Fatalf("got %v, want the recorded *BlockedError", err)
+	}
+
+	replayed, err := player.Embed(ctx, "text-embedding-004", []string{"hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, vectors) {
+		t.Fatal("replayed vectors differ from the recorded ones")
+	}
+
+	if _, err := player.Generate(ctx, "gemini-1.5-pro", prompt); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("got %v, want ErrCassetteMiss for a model that was never recorded", err)
+	}
+}
+
+func TestCassetteNeedsExistingDirectoryToReplay(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := NewCassetteProvider(missing, CassetteReplay, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v, want os.ErrNotExist", err)
+	}
+	if _, err := NewCassetteProvider(missing, CassetteRecord, nil); err == nil {
+		t.Fatal("expected recording without a provider to fail")
+	}
+}
+
+func TestRecordingAgainReplacesEarlierCalls(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	for _, reply := range []string{"Old answer", "New answer"} {
+		fake := newTestFake(t, FakeFixtures{Rules: []FakeRule{{Replies: []string{reply}}}})
+		recorder, err := NewCassetteProvider(dir, CassetteRecord, fake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := recorder.Generate(ctx, "gemini-1.5-flash", "What now?"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	player, err := NewCassetteProvider(dir, CassetteReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := player.Generate(ctx, "gemini-1.5-flash", "What now?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "New answer" {
+		t.Fatalf("got %q, want the second recording's reply", got.Text)
+	}
+}
@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FAKE_EMBEDDING_SIZE matches text-embedding-004 so fake vectors fit the Qdrant collections
+const FAKE_EMBEDDING_SIZE = 768
+
+var agentNamePattern = regexp.MustCompile(`You are ([^,\n]+),`)
+
+// FakeRule answers prompts that match every field it sets. Empty fields match anything.
+type FakeRule struct {
+	// Agent is compared case-insensitively with the name the prompt addresses ("You are <name>, ...")
+	Agent string `yaml:"agent"`
+	Model string `yaml:"model"`
+	// Prompt is a regular expression searched for in the prompt
+	Prompt string `yaml:"prompt"`
+
+	// Replies are returned in turn, starting over after the last one
+	Replies []string `yaml:"replies"`
+	// Verdict is "agree" or "alternate" and shapes the reply so the reflection loop reads it as that verdict
+	Verdict string `yaml:"verdict"`
+	// Error fails the call instead of replying. "blocked" returns a *BlockedError.
+	Error string `yaml:"error"`
+	// ErrorRate fails only that share of matching calls, between 0 and 1. Zero with Error set always fails.
+	ErrorRate float64 `yaml:"errorRate"`
+	// Latency replaces the fixtures' latency for this rule
+	Latency *time.Duration `yaml:"latency"`
+
+	pattern *regexp.Regexp
+	next    int
+}
+
+// FakeFixtures script the fake provider. The first matching rule answers; prompts no rule
+// matches get a reply built from the agent name and model.
+type FakeFixtures struct {
+	// Latency is waited out before every reply
+	Latency time.Duration `yaml:"latency"`
+	// ChunkDelay paces the chunks Stream delivers
+	ChunkDelay time.Duration `yaml:"chunkDelay"`
+	// Seed makes injected errors repeat across runs
+	Seed  int64      `yaml:"seed"`
+	Rules []FakeRule `yaml:"rules"`
+}
+
+// LoadFakeFixtures reads fixtures from a YAML file
+func LoadFakeFixtures(path string) (FakeFixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FakeFixtures{}, err
+	}
+
+	var fixtures FakeFixtures
+	if err := yaml.Unmarshal(data, &fixtures); err != nil {
+		return FakeFixtures{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// FakeProvider returns scripted replies so Trio runs without a Gemini API key.
+// Replies depend only on the fixtures and the order of calls.
+type FakeProvider struct {
+	mu         sync.Mutex
+	latency    time.Duration
+	chunkDelay time.Duration
+	rules      []*FakeRule
+	random     *rand.Rand
+}
+
+func NewFakeProvider(fixtures FakeFixtures) (*FakeProvider, error) {
+	p := &FakeProvider{
+		latency:    fixtures.Latency,
+		chunkDelay: fixtures.ChunkDelay,
+		random:     rand.New(rand.NewSource(fixtures.Seed)),
+	}
+
+	for i := range fixtures.Rules {
+		rule := fixtures.Rules[i]
+		if rule.Prompt != "" {
+			pattern, err := regexp.Compile(rule.Prompt)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid prompt pattern: %w", i, err)
+			}
+			rule.pattern = pattern
+		}
+		switch rule.Verdict {
+		case "", "agree", "alternate":
+		default:
+			return nil, fmt.Errorf("rule %d: verdict must be agree or alternate, got %q", i, rule.Verdict)
+		}
+		p.rules = append(p.rules, &rule)
+	}
+
+	return p, nil
+}
+
+func (p *FakeProvider) Generate(ctx context.Context, model string, prompt string) (Completion, error) {
+	text, latency, err := p.reply(model, prompt)
+	if err := sleep(ctx, latency); err != nil {
+		return Completion{}, err
+	}
+	if err != nil {
+		return Completion{}, err
+	}
+
+	return Completion{
+		Text:         text,
+		InputTokens:  estimateTokens(prompt),
+		OutputTokens: estimateTokens(text),
+		FinishReason: "STOP",
+	}, nil
+}
+
+// Stream delivers the reply a word at a time, waiting ChunkDelay between words
+func (p *FakeProvider) Stream(ctx context.Context, model string, prompt string, onChunk func(chunk string) error) (Completion, error) {
+	completion, err := p.Generate(ctx, model, prompt)
+	if err != nil {
+		return Completion{}, err
+	}
+
+	words := strings.SplitAfter(completion.Text, " ")
+	for i, word := range words {
+		if i > 0 {
+			if err := sleep(ctx, p.chunkDelay); err != nil {
+				return Completion{}, err
+			}
+		}
+		if err := onChunk(word); err != nil {
+			return Completion{}, err
+		}
+	}
+
+	return completion, nil
+}
+
+// Embed hashes each text into a vector, so equal texts always get equal vectors
+func (p *FakeProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		hash := fnv.New64a()
+		hash.Write([]byte(text))
+		random := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+		vector := make([]float32, FAKE_EMBEDDING_SIZE)
+		for i := range vector {
+			vector[i] = random.Float32()*2 - 1
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func (p *FakeProvider) Close() error {
+	return nil
+}
+
+func (p *FakeProvider) reply(model string, prompt string) (string, time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	agent := promptAgentName(prompt)
+	for _, rule := range p.rules {
+		if !rule.matches(agent, model, prompt) {
+			continue
+		}
+
+		latency := p.latency
+		if rule.Latency != nil {
+			latency = *rule.Latency
+		}
+
+		if rule.Error != "" && (rule.ErrorRate <= 0 || p.random.Float64() < rule.ErrorRate) {
+			return "", latency, fakeError(rule.Error)
+		}
+
+		text := defaultReply(agent, model, prompt)
+		if len(rule.Replies) > 0 {
+			text = rule.Replies[rule.next%len(rule.Replies)]
+			rule.next++
+		}
+		return withVerdict(text, rule.Verdict), latency, nil
+	}
+
+	return defaultReply(agent, model, prompt), p.latency, nil
+}
+
+func (r *FakeRule) matches(agent string, model string, prompt string) bool {
+	if r.Agent != "" && !strings.EqualFold(r.Agent, agent) {
+		return false
+	}
+	if r.Model != "" && r.Model != model {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(prompt)
+}
+
+// defaultReply answers reflection prompts that already carry the other agent's response with
+// "agree", so unscripted reflection chats still end after one round
+func defaultReply(agent string, model string, prompt string) string {
+	if strings.Contains(prompt, "The other agent's response:") {
+		return "agree"
+	}
+	return fmt.Sprintf("This is a scripted reply from %s.", agentOrModel(agent, model))
+}
+
+func withVerdict(text string, verdict string) string {
+	switch verdict {
+	case "agree":
+		return "agree"
+	case "alternate":
+		return strings.TrimSpace(text) + " alternate"
+	}
+	return text
+}
+
+func fakeError(message string) error {
+	if message == "blocked" {
+		return &BlockedError{FinishReason: "SAFETY"}
+	}
+	return errors.New(message)
+}
+
+func promptAgentName(prompt string) string {
+	if match := agentNamePattern.FindStringSubmatch(prompt); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return ""
+}
+
+func agentOrModel(agent string, model string) string {
+	if agent != "" {
+		return agent
+	}
+	return model
+}
+
+// estimateTokens approximates Gemini's count at four characters a token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/somtojf/trio/types"
+)
+
+func newTestFake(t *testing.T, fixtures FakeFixtures) *FakeProvider {
+	t.Helper()
+
+	provider, err := NewFakeProvider(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func generate(t *testing.T, provider *FakeProvider, model string, prompt string) string {
+	t.Helper()
+
+	completion, err := provider.Generate(context.Background(), model, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return completion.Text
+}
+
+func TestFakeMatchesRulesInOrder(t *testing.T) {
+	provider := newTestFake(t, FakeFixtures{Rules: []FakeRule{
+		{Agent: "ada", Prompt: "(?i)weather", Replies: []string{"Sunny"}},
+		{Agent: "Ada", Replies: []string{"First", "Second"}},
+		{Model: "gemini-1.5-pro", Replies: []string{"Pro"}},
+	}})
+
+	if got := generate(t, provider, "gemini-1.5-flash", "You are Ada, an AI agent. How is the weather?"); got != "Sunny" {
+		t.Fatalf("got %q, want Sunny", got)
+	}
+	for _, want := range []string{"First", "Second", "First"} {
+		if got := generate(t, provider, "gemini-1.5-flash", "You are Ada, an AI agent. Hello"); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if got := generate(t, provider, "gemini-1.5-pro", "You are Grace, an AI agent."); got != "Pro" {
+		t.Fatalf("got %q, want Pro", got)
+	}
+	if got := generate(t, provider, "gemini-1.5-flash", "You are Grace, an AI agent."); !strings.Contains(got, "Grace") {
+		t.Fatalf("got %q, want the default reply naming Grace", got)
+	}
+}
+
+func TestFakeVerdicts(t *testing.T) {
+	provider := newTestFake(t, FakeFixtures{Rules: []FakeRule{
+		{Agent: "Ada", Verdict: "agree", Replies: []string{"Sounds right to me"}},
+		{Agent: "Grace", Verdict: "alternate", Replies: []string{"Partly, but consider caching."}},
+	}})
+
+	cases := map[string]types.ReflectionVerdict{
+		"You are Ada, a helpful AI agent.":   types.ReflectionVerdictAgree,
+		"You are Grace, a helpful AI agent.": types.ReflectionVerdictAlternate,
+	}
+	for prompt, want := range cases {
+		if got := types.GetReflectionVerdict(generate(t, provider, "gemini-1.5-pro", prompt)); got != want {
+			t.Fatalf("%s: got verdict %s, want %s", prompt, got, want)
+		}
+	}
+
+	// Unscripted reflection agents agree once the other agent has answered
+	unscripted := newTestFake(t, FakeFixtures{})
+	prompt := "You are Linus, a helpful AI agent.\nThe other agent's response: use a map"
+	if got := types.GetReflectionVerdict(generate(t, unscripted, "gemini-1.5-pro", prompt)); got != types.ReflectionVerdictAgree {
+		t.Fatalf("got verdict %s, want agree", got)
+	}
+}
+
+func TestFakeInjectsErrors(t *testing.T) {
+	provider := newTestFake(t, FakeFixtures{Seed: 7, Rules: []FakeRule{
+		{Prompt: "unsafe", Error: "blocked"},
+		{Prompt: "flaky", Error: "unavailable", ErrorRate: 0.5},
+	}})
+
+	_, err := provider.Generate(context.Background(), "m", "something unsafe")
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("got %v, want a *BlockedError", err)
+	}
+
+	failures := func(provider *FakeProvider) []bool {
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, err := provider.Generate(context.Background(), "m", "flaky")
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first := failures(provider)
+	again := failures(newTestFake(t, FakeFixtures{Seed: 7, Rules: []FakeRule{
+		{Prompt: "unsafe", Error: "blocked"},
+		{Prompt: "flaky", Error: "unavailable", ErrorRate: 0.5},
+	}}))
+
+	var count int
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("failures differ between runs with the same seed: %v and %v", first, again)
+		}
+		if first[i] {
+			count++
+		}
+	}
+	if count == 0 || count == len(first) {
+		t.Fatalf("got %d failures out of %d, want some but not all", count, len(first))
+	}
+}
+
+func TestFakeLatencyAndStreaming(t *testing.T) {
+	latency := 50 * time.Millisecond
+	provider := newTestFake(t, FakeFixtures{ChunkDelay: time.Millisecond, Rules: []FakeRule{
+		{Agent: "Sloth", Latency: &latency, Replies: []string{"one two three"}},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Generate(ctx, "m", "You are Sloth, an AI agent."); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline to cut the simulated latency short", err)
+	}
+
+	var chunks []string
+	start := time.Now()
+	completion, err := provider.Stream(context.Background(), "m", "You are Sloth, an AI agent.", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Fatalf("reply took %s, want at least %s", elapsed, latency)
+	}
+	if len(chunks) != 3 || strings.Join(chunks, "") != completion.Text {
+		t.Fatalf("got chunks %q for %q", chunks, completion.Text)
+	}
+	if completion.InputTokens == 0 || completion.OutputTokens == 0 {
+		t.Fatalf("got %+v, want estimated token counts", completion)
+	}
+}
+
+func TestLoadExampleFixtures(t *testing.T) {
+	fixtures, err := LoadFakeFixtures("fixtures/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixtures.Latency != 300*time.Millisecond || len(fixtures.Rules) == 0 {
+		t.Fatalf("unexpected fixtures %+v", fixtures)
+	}
+	if _, err := NewFakeProvider(fixtures); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type GeminiProvider struct {
+	client *genai.Client
+}
+
+func NewGeminiProvider(ctx context.Context, apiKey string) (*GeminiProvider, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeminiProvider{client: client}, nil
+}
+
+func (p *GeminiProvider) Generate(ctx context.Context, model string, prompt string) (Completion, error) {
+	resp, err := p.client.GenerativeModel(model).GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		var blocked *genai.BlockedError
+		if errors.As(err, &blocked) {
+			finishReason := "PROMPT_BLOCKED"
+			if blocked.Candidate != nil {
+				finishReason = blocked.Candidate.FinishReason.String()
+			}
+			return Completion{}, &BlockedError{FinishReason: finishReason, Err: err}
+		}
+		return Completion{}, err
+	}
+	if resp == nil {
+		return Completion{}, fmt.Errorf("received nil response from Gemini")
+	}
+
+	var completion Completion
+	if resp.UsageMetadata != nil {
+		completion.InputTokens = int(resp.UsageMetadata.PromptTokenCount)
+		completion.OutputTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+	}
+	if len(resp.Candidates) > 0 {
+		candidate := resp.Candidates[0]
+		completion.FinishReason = candidate.FinishReason.String()
+
+		if candidate.Content != nil {
+			var text strings.Builder
+			for _, part := range candidate.Content.Parts {
+				if t, ok := part.(genai.Text); ok {
+					text.WriteString(string(t))
+				}
+			}
+			completion.Text = text.String()
+		}
+	}
+
+	return completion, nil
+}
+
+func (p *GeminiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	embeddingModel := p.client.EmbeddingModel(model)
+	embeddingModel.TaskType = genai.TaskTypeRetrievalDocument
+
+	batch := embeddingModel.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+
+	resp, err := embeddingModel.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, 0, len(resp.Embeddings))
+	for _, embedding := range resp.Embeddings {
+		vectors = append(vectors, embedding.Values)
+	}
+	return vectors, nil
+}
+
+// Ping lists the available models, which checks the API key without spending tokens
+func (p *GeminiProvider) Ping(ctx context.Context) error {
+	_, err := p.client.ListModels(ctx).Next()
+	if errors.Is(err, iterator.Done) {
+		return nil
+	}
+	return err
+}
+
+func (p *GeminiProvider) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider is a language model backend. Handlers only see this interface, so tests and local
+// development can run without a Gemini API key.
+type Provider interface {
+	// Generate completes a single text prompt
+	Generate(ctx context.Context, model string, prompt string) (Completion, error)
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+	Close() error
+}
+
+// Streamer is implemented by providers that can deliver a completion in chunks as it is generated
+type Streamer interface {
+	Stream(ctx context.Context, model string, prompt string, onChunk func(chunk string) error) (Completion, error)
+}
+
+// Pinger is implemented by providers that can check they are reachable without generating anything
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type Completion struct {
+	Text         string
+	InputTokens  int
+	OutputTokens int
+	FinishReason string
+}
+
+func (c Completion) TotalTokens() int {
+	return c.InputTokens + c.OutputTokens
+}
+
+// BlockedError is returned when the provider refuses the prompt or the response on safety grounds
+type BlockedError struct {
+	FinishReason string
+	Err          error
+}
+
+func (e *BlockedError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("blocked: %s", e.FinishReason)
+}
+
+func (e *BlockedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ConsoleMailer writes emails to the log instead of sending them, for local development
+type ConsoleMailer struct{}
+
+func NewConsoleMailer() *ConsoleMailer {
+	return &ConsoleMailer{}
+}
+
+func (m *ConsoleMailer) Send(ctx context.Context, message Message) error {
+	slog.Info("Email", "to", message.To, "subject", message.Subject, "body", message.Text)
+	return nil
+}
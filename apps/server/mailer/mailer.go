@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/somtojf/trio/config"
+)
+
+const SEND_TIMEOUT = 30 * time.Second
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New returns the mailer named by MAILER, an SMTPMailer for "smtp" and a ConsoleMailer otherwise
+func New(cfg config.MailConfig) (Mailer, error) {
+	if cfg.Mailer != "smtp" {
+		slog.Info("Using console mailer")
+		return NewConsoleMailer(), nil
+	}
+
+	mailer, err := NewSMTPMailer(cfg.SMTP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure smtp mailer: %w", err)
+	}
+	slog.Info("Using smtp mailer", "host", mailer.Host)
+	return mailer, nil
+}
+
+// SendAsync delivers the message in the background so slow mail servers never hold up a request
+// and response times do not reveal whether an address exists. Failures are logged.
+func SendAsync(mailer Mailer, message Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), SEND_TIMEOUT)
+		defer cancel()
+
+		if err := mailer.Send(ctx, message); err != nil {
+			slog.Error("Failed to send email", "subject", message.Subject, "error", err)
+		}
+	}()
+}
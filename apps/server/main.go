@@ -3,39 +3,18 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/somtojf/trio/clients"
-	"github.com/somtojf/trio/controllers"
-	"github.com/somtojf/trio/qdrantpackage"
-
-	docs "github.com/somtojf/trio/docs"
-	"github.com/somtojf/trio/initializers"
-	"github.com/somtojf/trio/middleware"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/somtojf/trio/app"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/migration"
+	"github.com/somtojf/trio/server"
 )
 
-func init() {
-	var collections = []qdrantpackage.CollectionName{qdrantpackage.Messages}
-
-	initializers.LoadEnvVariables()
-	initializers.ConnectToDb()
-	qdrantpackage.ConnectToQdrant()
-
-	qdrantpackage.CreateQdrantCollections(qdrantpackage.QdrantClient, collections)
-}
-
-func SetContext(geminiClient *genai.Client) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Set("GeminiClient", geminiClient)
-		c.Next()
-	}
-}
-
 // @title			Trio API
 // @Schemes
 // @version		1.0
@@ -45,67 +24,62 @@ func SetContext(geminiClient *genai.Client) gin.HandlerFunc {
 // @host		localhost:4000
 // @BasePath	/
 func main() {
-	r := gin.Default()
-	clientAddress := os.Getenv("CLIENT_ADDRESS")
-
-	geminiClient, err := clients.CreateGeminiClient(context.Background())
+	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer geminiClient.Close()
+	slog.Info("Loaded configuration", "config", cfg)
 
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{clientAddress}
-	config.AllowCredentials = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	a, err := app.New(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.Use(cors.New(config))
-	r.Use(SetContext(geminiClient))
+	// Migrations are applied with `go run ./cmd/migrate up`, never implicitly on boot
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migration.CheckPending(context.Background(), sqlDB); err != nil {
+		log.Fatal(err)
+	}
+	if err := a.Metrics.RegisterDB(sqlDB); err != nil {
+		log.Fatal(err)
+	}
 
-	docs.SwaggerInfo.BasePath = "/"
+	// Validated by config.Load
+	limits, _ := cfg.RateLimits.Limits()
 
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r := server.NewRouter(a, limits)
 
-	public := r.Group("/")
-	{
-		public.POST("/login", controllers.Login)
-		public.POST("/signup", controllers.Signup)
+	listener, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	authenticated := r.Group("/")
-	authenticated.Use(middleware.CheckAuth())
-	{
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		authenticated.POST("/logout", controllers.Logout)
-		authenticated.POST("/reset-password", controllers.ResetPassword)
-		authenticated.GET("/completions", controllers.GetCompletion)
-
-		// Chat related endpoints
-		chats := authenticated.Group("/chats")
-		{
-			chats.POST("", controllers.CreateChat)
-			chats.GET("", controllers.GetUserChats)
-			chats.GET("/:chatId", controllers.GetChatInfo)
-			chats.DELETE("/:chatId", controllers.DeleteChat)
-			chats.PUT("/:chatId", controllers.UpdateChat)
-			chats.POST("/:chatId/messages", controllers.NewMessage)
-			chats.POST("/:chatId/agents", controllers.AddAgentToChat)
-		}
-
-		user := authenticated.Group("/me")
-		{
-			user.GET("", controllers.GetCurrentUser)
+	if cfg.Metrics.Address != "" {
+		metricsListener, err := net.Listen("tcp", cfg.Metrics.Address)
+		if err != nil {
+			log.Fatal(err)
 		}
+		go func() {
+			if err := server.ServeMetrics(ctx, a, metricsListener); err != nil {
+				slog.Error("Metrics listener stopped unexpectedly", "error", err)
+			}
+		}()
+	} else if cfg.Metrics.Token == "" {
+		slog.Warn("Metrics are disabled, set METRICS_TOKEN or METRICS_ADDRESS to expose them")
+	}
 
-		// Agent related endpoints
-		agents := authenticated.Group("/agents")
-		{
-			agents.GET("/:agentId", controllers.GetAgent)
-			agents.PUT("/:agentId", controllers.UpdateAgent)
-			agents.DELETE("/:agentId", controllers.DeleteAgent)
-		}
+	if err := server.Serve(ctx, a, listener, r, cfg.ShutdownTimeout); err != nil {
+		slog.Error("Server stopped unexpectedly", "error", err)
 	}
 
-	r.Run()
+	// Only once nothing is serving requests, so no handler is left mid-write
+	if err := a.Close(); err != nil {
+		slog.Error("Failed to close connections", "error", err)
+	}
 }
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/somtojf/trio/llm"
+)
+
+const NAMESPACE = "trio"
+
+// Metrics owns its registry, so every App, including each test's, starts from zero
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	llmDuration      *prometheus.HistogramVec
+	llmTokens        *prometheus.CounterVec
+	llmErrors        *prometheus.CounterVec
+	reflectionRounds prometheus.Histogram
+	sseConnections   prometheus.Gauge
+}
+
+// New registers the server's metrics along with the Go runtime and process collectors
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	m := &Metrics{
+		registry: registry,
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"method", "route", "status"}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route pattern and method. Streams are timed until they end.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		llmDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "llm",
+			Name:      "call_duration_seconds",
+			Help:      "Model call latency by model, including failed calls.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"model"}),
+
+		llmTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "llm",
+			Name:      "tokens_total",
+			Help:      "Tokens used by model and direction (input or output).",
+		}, []string{"model", "direction"}),
+
+		llmErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "llm",
+			Name:      "errors_total",
+			Help:      "Failed model calls by model and reason (blocked, canceled or error).",
+		}, []string{"model", "reason"}),
+
+		reflectionRounds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "reflection",
+			Name:      "rounds",
+			Help:      "Rounds each reflection request took before the agents reached a verdict or stopped.",
+			Buckets:   prometheus.LinearBuckets(1, 1, 10),
+		}),
+
+		sseConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "sse",
+			Name:      "connections_open",
+			Help:      "Server-sent event streams currently open.",
+		}),
+	}
+
+	registry.MustRegister(m.httpRequests, m.httpDuration, m.llmDuration, m.llmTokens, m.llmErrors, m.reflectionRounds, m.sseConnections)
+	return m
+}
+
+// Handler exposes the registry in the Prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool's stats, such as open, in use and idle connections and wait time
+func (m *Metrics) RegisterDB(db *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, NAMESPACE))
+}
+
+func (m *Metrics) ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveModelCall records one model call. Agents are named by users, so they are never a label.
+func (m *Metrics) ObserveModelCall(model string, duration time.Duration, completion llm.Completion, err error) {
+	m.llmDuration.WithLabelValues(model).Observe(duration.Seconds())
+	m.llmTokens.WithLabelValues(model, "input").Add(float64(completion.InputTokens))
+	m.llmTokens.WithLabelValues(model, "output").Add(float64(completion.OutputTokens))
+
+	if err != nil {
+		m.llmErrors.WithLabelValues(model, errorReason(err)).Inc()
+	}
+}
+
+func (m *Metrics) ObserveReflectionRounds(rounds int) {
+	m.reflectionRounds.Observe(float64(rounds))
+}
+
+// SSEStreamOpened counts an open stream. Call the returned function when it closes.
+func (m *Metrics) SSEStreamOpened() func() {
+	m.sseConnections.Inc()
+	return m.sseConnections.Dec
+}
+
+func errorReason(err error) string {
+	var blocked *llm.BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		return "blocked"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "error"
+}
@@ -1,62 +1,148 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
-	"os"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/somtojf/trio/initializers"
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/auth"
 	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
 )
 
-func CheckAuth() gin.HandlerFunc {
+// CheckAuth reads API keys, users and sessions through the repositories.
+// Access tokens are checked with keys, and cookies refreshed on the way are scoped to domain.
+func CheckAuth(repos repository.Repositories, keys auth.Keys, domain string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("Access_Token")
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+		if bearer, found := bearerToken(c); found {
+			authenticateBearer(c, repos, keys, bearer)
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(os.Getenv("SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		user, session, ok := authenticateAccessToken(c, repos, keys)
+		if !ok {
+			user, session, ok = refreshExpiredSession(c, repos.Sessions, keys, domain)
+		}
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		if rejectSuspended(c, user) {
 			return
 		}
 
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
-			c.AbortWithStatus(http.StatusUnauthorized)
+		c.Set("currentUser", user)
+		c.Set("currentSession", session)
+
+		c.Next()
+	}
+}
+
+// rejectSuspended stops suspended users, including those holding API keys or sessions from before the suspension
+func rejectSuspended(c *gin.Context, user models.User) bool {
+	if !user.IsSuspended() {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": auth.ErrAccountSuspended.Error()})
+	c.Abort()
+	return true
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[len("Bearer "):]), true
+}
+
+// authenticateBearer accepts an API key or an access token in the Authorization header
+func authenticateBearer(c *gin.Context, repos repository.Repositories, keys auth.Keys, token string) {
+	if auth.IsAPIKey(token) {
+		key, user, err := auth.AuthenticateAPIKey(c, repos.APIKeys, token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
 			return
 		}
 
-		var user models.User
-		result := initializers.DB.Where("external_id = ?", claims["id"]).First(&user)
-		if result.Error != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			c.Abort()
+		if rejectSuspended(c, user) {
 			return
 		}
 
 		c.Set("currentUser", user)
-
+		c.Set("currentAPIKey", key)
 		c.Next()
+		return
 	}
+
+	user, session, ok := verifyAccessToken(c, repos, keys, token)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	if rejectSuspended(c, user) {
+		return
+	}
+
+	c.Set("currentUser", user)
+	c.Set("currentSession", session)
+	c.Next()
+}
+
+func authenticateAccessToken(c *gin.Context, repos repository.Repositories, keys auth.Keys) (models.User, models.Session, bool) {
+	tokenString, err := c.Cookie(auth.ACCESS_TOKEN_COOKIE)
+	if err != nil {
+		return models.User{}, models.Session{}, false
+	}
+	return verifyAccessToken(c, repos, keys, tokenString)
+}
+
+func verifyAccessToken(c *gin.Context, repos repository.Repositories, keys auth.Keys, tokenString string) (models.User, models.Session, bool) {
+	claims, err := auth.ParseAccessToken(keys, tokenString)
+	if err != nil {
+		return models.User{}, models.Session{}, false
+	}
+
+	// Access tokens die with their session, so logout takes effect immediately
+	session, err := auth.FindActiveSession(c, repos.Sessions, claims.SessionID)
+	if err != nil {
+		return models.User{}, models.Session{}, false
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return models.User{}, models.Session{}, false
+	}
+
+	user, err := repos.Users.FindByExternalID(c, userID)
+	if err != nil || user.ID != session.UserID {
+		return models.User{}, models.Session{}, false
+	}
+
+	return user, session, true
+}
+
+// refreshExpiredSession rotates the refresh token when the access token has lapsed,
+// so browser clients stay logged in without calling /refresh themselves
+func refreshExpiredSession(c *gin.Context, sessions repository.SessionRepository, keys auth.Keys, domain string) (models.User, models.Session, bool) {
+	refreshToken, err := c.Cookie(auth.REFRESH_TOKEN_COOKIE)
+	if err != nil {
+		return models.User{}, models.Session{}, false
+	}
+
+	tokens, user, err := auth.RotateSession(c, sessions, keys, refreshToken)
+	if err != nil {
+		auth.ClearAuthCookies(c, domain)
+		return models.User{}, models.Session{}, false
+	}
+
+	auth.SetAuthCookies(c, domain, tokens)
+	return user, tokens.Session, true
 }
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/ratelimit"
+)
+
+// RateLimit applies the limit per user, falling back to the client IP on public routes.
+// It must run after CheckAuth to key by user.
+func RateLimit(store ratelimit.Store, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := limit.Name + ":ip:" + c.ClientIP()
+		if user, ok := c.Value("currentUser").(models.User); ok {
+			key = limit.Name + ":user:" + strconv.FormatUint(uint64(user.ID), 10)
+		}
+
+		result, err := store.Take(c.Request.Context(), key, limit)
+		if err != nil {
+			// Fail open so an unavailable store does not take the API down
+			slog.Error("Rate limit store failed", "limit", limit.Name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", limit.Policy())
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please slow down"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimitByMethod limits safe methods with read and everything else with write
+func RateLimitByMethod(store ratelimit.Store, read ratelimit.Limit, write ratelimit.Limit) gin.HandlerFunc {
+	readLimiter := RateLimit(store, read)
+	writeLimiter := RateLimit(store, write)
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			readLimiter(c)
+		default:
+			writeLimiter(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
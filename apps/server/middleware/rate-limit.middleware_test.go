@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/ratelimit"
+)
+
+type fakeStore struct {
+	result ratelimit.Result
+	err    error
+	keys   []string
+}
+
+func (s *fakeStore) Take(_ context.Context, key string, _ ratelimit.Limit) (ratelimit.Result, error) {
+	s.keys = append(s.keys, key)
+	return s.result, s.err
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+func serveLimited(store ratelimit.Store, method string, user *models.User) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	read := ratelimit.Limit{Name: "read", Requests: 100, Period: time.Minute}
+	write := ratelimit.Limit{Name: "write", Requests: 10, Period: time.Minute}
+	router.Use(func(c *gin.Context) {
+		if user != nil {
+			c.Set("currentUser", *user)
+		}
+	})
+	router.Handle(method, "/", RateLimitByMethod(store, read, write), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := httptest.NewRequest(method, "/", nil)
+	request.RemoteAddr = "198.51.100.4:40000"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimit(t *testing.T) {
+	user := models.User{}
+	user.ID = 42
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		user       *models.User
+		store      *fakeStore
+		status     int
+		key        string
+		headers    map[string]string
+		retryAfter string
+	}{
+		{
+			name:   "allowed",
+			method: http.MethodGet,
+			store:  &fakeStore{result: ratelimit.Result{Allowed: true, Remaining: 99, ResetAfter: 600 * time.Millisecond}},
+			status: http.StatusNoContent,
+			key:    "read:ip:198.51.100.4",
+			headers: map[string]string{
+				"RateLimit-Policy":    "100;w=60",
+				"RateLimit-Limit":     "100",
+				"RateLimit-Remaining": "99",
+				"RateLimit-Reset":     "1",
+			},
+		},
+		{
+			name:   "limited rounds Retry-After up",
+			method: http.MethodPost,
+			user:   &user,
+			store:  &fakeStore{result: ratelimit.Result{RetryAfter: 5100 * time.Millisecond, ResetAfter: time.Minute}},
+			status: http.StatusTooManyRequests,
+			key:    "write:user:42",
+			headers: map[string]string{
+				"RateLimit-Policy":    "10;w=60",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+			},
+			retryAfter: "6",
+		},
+		{
+			name:    "store failure fails open",
+			method:  http.MethodDelete,
+			store:   &fakeStore{err: errors.New("unavailable")},
+			status:  http.StatusNoContent,
+			key:     "write:ip:198.51.100.4",
+			headers: map[string]string{"RateLimit-Limit": ""},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := serveLimited(tc.store, tc.method, tc.user)
+
+			if recorder.Code != tc.status {
+				t.Fatalf("got status %d, want %d", recorder.Code, tc.status)
+			}
+			if len(tc.store.keys) != 1 || tc.store.keys[0] != tc.key {
+				t.Fatalf("got keys %v, want [%s]", tc.store.keys, tc.key)
+			}
+			for header, want := range tc.headers {
+				if got := recorder.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tc.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tc.retryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimitWithMemoryStore(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	defer store.Close()
+
+	if recorder := serveLimited(store, http.MethodPost, nil); recorder.Code != http.StatusNoContent {
+		t.Fatalf("first write got %d", recorder.Code)
+	}
+	for i := 0; i < 9; i++ {
+		serveLimited(store, http.MethodPost, nil)
+	}
+	recorder := serveLimited(store, http.MethodPost, nil)
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "6" {
+		t.Fatalf("eleventh write got %d with Retry-After %q, want 429 after 6s", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+	if recorder := serveLimited(store, http.MethodGet, nil); recorder.Code != http.StatusNoContent {
+		t.Fatalf("reads have their own bucket, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/models"
+)
+
+// RequireRole limits a route to users with the given role. It must run after CheckAuth.
+func RequireRole(role models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Value("currentUser").(models.User)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if user.Role != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/models"
+)
+
+// RequireScope limits API key requests to keys granted the scope. Browser sessions have every scope.
+func RequireScope(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := c.Value("currentAPIKey").(models.APIKey)
+		if ok && !key.HasScope(string(scope)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + string(scope) + " scope"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects API keys on account management routes such as creating more keys
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Value("currentSession").(models.Session); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires an interactive login"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/lifecycle"
+)
+
+// TrackGeneration registers the request as a generation, so shutdown waits for it and new
+// generations are refused once shutdown has started
+func TrackGeneration(generations *lifecycle.Generations) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, done, err := generations.Begin(c.Request.Context())
+		if err != nil {
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down, please try again shortly"})
+			c.Abort()
+			return
+		}
+		defer done()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func file(contents string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(contents)}
+}
+
+func TestLoadOrdersByVersionAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/000010_add_plans.up.sql":        file("ALTER TABLE users ADD COLUMN plan text;"),
+		"sql/000002_add_email.up.sql":        file("ALTER TABLE users ADD COLUMN email text;"),
+		"sql/000002_add_email.down.sql":      file("ALTER TABLE users DROP COLUMN email;"),
+		"sql/000001_initial_schema.up.sql":   file("CREATE TABLE users (id bigserial);"),
+		"sql/000001_initial_schema.down.sql": file("DROP TABLE users;"),
+	}
+
+	migrations, err := load(fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Fatalf("got versions %v, want [1 2 10] in numeric order", versions)
+	}
+	if migrations[1].Name != "add_email" || !strings.Contains(migrations[1].Down, "DROP COLUMN email") {
+		t.Fatalf("down file not paired with its up file: %+v", migrations[1])
+	}
+	if migrations[2].Down != "" {
+		t.Fatalf("a migration without a down file should have no down SQL, got %q", migrations[2].Down)
+	}
+}
+
+func TestLoadRejectsBadMigrationSets(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"unparseable name", fstest.MapFS{"sql/initial.up.sql": file("SELECT 1;")}, "unexpected migration file"},
+		{"duplicate version", fstest.MapFS{
+			"sql/000001_initial.up.sql": file("SELECT 1;"),
+			"sql/1_initial.up.sql":      file("SELECT 2;"),
+		}, "more than one up file"},
+		{"two names for one version", fstest.MapFS{
+			"sql/000001_initial.up.sql": file("SELECT 1;"),
+			"sql/000001_other.down.sql": file("SELECT 2;"),
+		}, "two names"},
+		{"down without up", fstest.MapFS{"sql/000001_initial.down.sql": file("SELECT 1;")}, "no up migration"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := load(tc.fsys, "sql"); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got %v, want an error mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := load(files, MIGRATIONS_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("expected the baseline migration first, got %+v", migrations)
+	}
+}
+
+func TestCreateNumbersAfterTheLatestMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000007_existing.up.sql"), []byte("SELECT 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	upPath, downPath, err := Create(dir, "Add User Plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(upPath) != "000008_add_user_plans.up.sql" || filepath.Base(downPath) != "000008_add_user_plans.down.sql" {
+		t.Fatalf("unexpected files %s and %s", upPath, downPath)
+	}
+
+	if _, _, err := Create(filepath.Join(dir, "missing"), "anything"); err == nil {
+		t.Fatal("expected a missing directory to fail")
+	}
+}
+
+func TestSourceDirHoldsTheEmbeddedMigrations(t *testing.T) {
+	if _, err := os.Stat(filepath.Join(SourceDir(), "000001_initial_schema.up.sql")); err != nil {
+		t.Fatalf("SourceDir should point at this package's sql directory: %v", err)
+	}
+}
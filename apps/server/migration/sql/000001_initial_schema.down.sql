@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS gemini_logs;
+DROP TABLE IF EXISTS usage_counters;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS chat_invitations;
+DROP TABLE IF EXISTS chat_members;
+DROP TABLE IF EXISTS chat_shares;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS agents;
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS users;
+DROP TYPE IF EXISTS sender_type_enum;
@@ -0,0 +1,315 @@
+-- Baseline schema. Every statement is idempotent so databases created by the
+-- old AutoMigrate based script can adopt versioned migrations. CREATE TABLE IF NOT
+-- EXISTS leaves their existing tables alone, so columns added to those tables since
+-- they were created are added separately below each table.
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'sender_type_enum') THEN
+		CREATE TYPE sender_type_enum AS ENUM ('User', 'Agent');
+	END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS users (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_users_external_id UNIQUE,
+	username text CONSTRAINT uni_users_username UNIQUE,
+	full_name text,
+	email text,
+	email_verified_at timestamptz,
+	password_hash text,
+	role text NOT NULL DEFAULT 'user',
+	suspended_at timestamptz,
+	plan text,
+	totp_secret text,
+	totp_last_step bigint,
+	two_factor_enabled_at timestamptz
+);
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS email text,
+	ADD COLUMN IF NOT EXISTS email_verified_at timestamptz,
+	ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user',
+	ADD COLUMN IF NOT EXISTS suspended_at timestamptz,
+	ADD COLUMN IF NOT EXISTS plan text,
+	ADD COLUMN IF NOT EXISTS totp_secret text,
+	ADD COLUMN IF NOT EXISTS totp_last_step bigint,
+	ADD COLUMN IF NOT EXISTS two_factor_enabled_at timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS chats (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_chats_external_id UNIQUE,
+	user_id bigint CONSTRAINT fk_users_chats REFERENCES users (id),
+	chat_name text,
+	type varchar(11) DEFAULT 'DEFAULT' CONSTRAINT chk_chats_type CHECK (type IN ('DEFAULT', 'REFLECTION'))
+);
+CREATE INDEX IF NOT EXISTS idx_chats_deleted_at ON chats (deleted_at);
+
+-- Agent metadata is embedded, which gives agents a composite primary key
+CREATE TABLE IF NOT EXISTS agents (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_agents_external_id UNIQUE,
+	name text,
+	chat_id bigint CONSTRAINT fk_chats_agents REFERENCES chats (id) ON DELETE CASCADE,
+	metadata_id bigserial,
+	metadata_created_at timestamptz,
+	metadata_updated_at timestamptz,
+	metadata_deleted_at timestamptz,
+	metadata_lingo text,
+	metadata_traits text[],
+	metadata_agent_id bigint,
+	PRIMARY KEY (id, metadata_id)
+);
+CREATE INDEX IF NOT EXISTS idx_agents_deleted_at ON agents (deleted_at, metadata_deleted_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id serial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid(),
+	content text,
+	chat_id integer,
+	sender_type sender_type_enum,
+	sender_id integer
+);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_messages_chat') THEN
+		ALTER TABLE messages ADD CONSTRAINT fk_messages_chat FOREIGN KEY (chat_id) REFERENCES chats (id);
+	END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS chat_shares (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_chat_shares_external_id UNIQUE,
+	token_hash text NOT NULL,
+	chat_id bigint NOT NULL CONSTRAINT fk_chat_shares_chat REFERENCES chats (id) ON DELETE CASCADE,
+	user_id bigint NOT NULL,
+	snapshot_at timestamptz,
+	expires_at timestamptz,
+	revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_chat_shares_deleted_at ON chat_shares (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_chat_shares_chat_id ON chat_shares (chat_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_shares_token_hash ON chat_shares (token_hash);
+
+CREATE TABLE IF NOT EXISTS chat_members (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_chat_members_external_id UNIQUE,
+	chat_id bigint NOT NULL CONSTRAINT fk_chats_members REFERENCES chats (id) ON DELETE CASCADE,
+	user_id bigint NOT NULL CONSTRAINT fk_chat_members_user REFERENCES users (id) ON DELETE CASCADE,
+	role varchar(6) NOT NULL CONSTRAINT chk_chat_members_role CHECK (role IN ('owner', 'editor', 'viewer'))
+);
+CREATE INDEX IF NOT EXISTS idx_chat_members_user_id ON chat_members (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_members_chat_user ON chat_members (chat_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_chat_members_deleted_at ON chat_members (deleted_at);
+
+CREATE TABLE IF NOT EXISTS chat_invitations (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_chat_invitations_external_id UNIQUE,
+	chat_id bigint NOT NULL CONSTRAINT fk_chat_invitations_chat REFERENCES chats (id) ON DELETE CASCADE,
+	inviter_id bigint NOT NULL CONSTRAINT fk_chat_invitations_inviter REFERENCES users (id),
+	invitee_id bigint NOT NULL CONSTRAINT fk_chat_invitations_invitee REFERENCES users (id),
+	role varchar(6) NOT NULL CONSTRAINT chk_chat_invitations_role CHECK (role IN ('editor', 'viewer')),
+	status varchar(8) NOT NULL DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS idx_chat_invitations_invitee_id ON chat_invitations (invitee_id);
+CREATE INDEX IF NOT EXISTS idx_chat_invitations_chat_id ON chat_invitations (chat_id);
+CREATE INDEX IF NOT EXISTS idx_chat_invitations_deleted_at ON chat_invitations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_sessions_external_id UNIQUE,
+	user_id bigint NOT NULL CONSTRAINT fk_sessions_user REFERENCES users (id) ON DELETE CASCADE,
+	refresh_token_hash text NOT NULL,
+	previous_token_hash text,
+	user_agent text,
+	ip_address text,
+	last_used_at timestamptz,
+	expires_at timestamptz,
+	revoked_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions (refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions (previous_token_hash);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_api_keys_external_id UNIQUE,
+	user_id bigint NOT NULL CONSTRAINT fk_api_keys_user REFERENCES users (id) ON DELETE CASCADE,
+	name text NOT NULL,
+	prefix text NOT NULL,
+	key_hash text NOT NULL,
+	scopes text[],
+	expires_at timestamptz,
+	last_used_at timestamptz,
+	revoked_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_user_identities_external_id UNIQUE,
+	user_id bigint NOT NULL CONSTRAINT fk_user_identities_user REFERENCES users (id) ON DELETE CASCADE,
+	provider text NOT NULL,
+	issuer text NOT NULL,
+	subject text NOT NULL,
+	email text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_issuer_subject ON user_identities (issuer, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_deleted_at ON user_identities (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL CONSTRAINT fk_user_tokens_user REFERENCES users (id) ON DELETE CASCADE,
+	purpose text NOT NULL,
+	token_hash text NOT NULL,
+	email text NOT NULL,
+	expires_at timestamptz,
+	used_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_purpose ON user_tokens (purpose);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_deleted_at ON user_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+	id bigserial PRIMARY KEY,
+	key text NOT NULL,
+	failures bigint NOT NULL DEFAULT 0,
+	last_failure_at timestamptz,
+	locked_until timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_throttles_key ON login_throttles (key);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_audit_events_external_id UNIQUE,
+	action text NOT NULL,
+	user_id bigint,
+	actor_id bigint,
+	ip_address text,
+	user_agent text,
+	details text
+);
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS actor_id bigint;
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_deleted_at ON audit_events (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	user_id bigint NOT NULL CONSTRAINT fk_recovery_codes_user REFERENCES users (id) ON DELETE CASCADE,
+	code_hash text NOT NULL,
+	used_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_deleted_at ON recovery_codes (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS usage_counters (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL CONSTRAINT fk_usage_counters_user REFERENCES users (id) ON DELETE CASCADE,
+	day date NOT NULL,
+	requests bigint NOT NULL DEFAULT 0,
+	tokens bigint NOT NULL DEFAULT 0,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_counters_user_day ON usage_counters (user_id, day);
+
+CREATE TABLE IF NOT EXISTS gemini_logs (
+	id serial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	external_id uuid DEFAULT gen_random_uuid() CONSTRAINT uni_gemini_logs_external_id UNIQUE,
+	prompt text,
+	input_tokens integer,
+	output_tokens integer,
+	total_tokens integer,
+	sender_type sender_type_enum,
+	sender_id integer
+);
+CREATE INDEX IF NOT EXISTS idx_gemini_logs_deleted_at ON gemini_logs (deleted_at);
+
+-- The old script created gemini_logs without the unique constraint the model declares
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'uni_gemini_logs_external_id') THEN
+		ALTER TABLE gemini_logs ADD CONSTRAINT uni_gemini_logs_external_id UNIQUE (external_id);
+	END IF;
+END
+$$;
+
+-- Usage accounting columns for every model call
+ALTER TABLE gemini_logs
+	ADD COLUMN IF NOT EXISTS user_id integer,
+	ADD COLUMN IF NOT EXISTS chat_id integer,
+	ADD COLUMN IF NOT EXISTS agent_id integer,
+	ADD COLUMN IF NOT EXISTS model text,
+	ADD COLUMN IF NOT EXISTS latency_ms bigint,
+	ADD COLUMN IF NOT EXISTS finish_reason text,
+	ADD COLUMN IF NOT EXISTS estimated_cost double precision,
+	ADD COLUMN IF NOT EXISTS error text;
+CREATE INDEX IF NOT EXISTS idx_gemini_logs_user_created ON gemini_logs (user_id, created_at);
+
+-- Completions logged before accounting was added were always made by the user
+UPDATE gemini_logs SET user_id = sender_id, model = 'gemini-1.5-flash'
+WHERE user_id IS NULL AND sender_type = 'User';
+
+-- Backfill owner memberships for chats created before collaborative chats
+INSERT INTO chat_members (created_at, updated_at, chat_id, user_id, role)
+SELECT NOW(), NOW(), chats.id, chats.user_id, 'owner'
+FROM chats
+WHERE chats.deleted_at IS NULL
+ON CONFLICT (chat_id, user_id) DO NOTHING;
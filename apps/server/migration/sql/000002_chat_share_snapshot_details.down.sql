@@ -0,0 +1,3 @@
+ALTER TABLE chat_shares
+	DROP COLUMN IF EXISTS snapshot_agents,
+	DROP COLUMN IF EXISTS snapshot_chat_name;
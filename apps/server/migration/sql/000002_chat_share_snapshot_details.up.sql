@@ -0,0 +1,20 @@
+-- Snapshot shares keep the chat's name and agents from the time they were created
+ALTER TABLE chat_shares
+	ADD COLUMN IF NOT EXISTS snapshot_chat_name text,
+	ADD COLUMN IF NOT EXISTS snapshot_agents jsonb;
+
+-- Existing snapshots get the chat's current name and agents, the closest record there is
+UPDATE chat_shares
+SET snapshot_chat_name = chats.chat_name,
+	snapshot_agents = COALESCE((
+		SELECT jsonb_agg(jsonb_build_object(
+			'id', agents.external_id,
+			'name', agents.name,
+			'lingo', NULLIF(agents.metadata_lingo, ''),
+			'traits', agents.metadata_traits
+		) ORDER BY agents.created_at)
+		FROM agents
+		WHERE agents.chat_id = chats.id AND agents.deleted_at IS NULL
+	), '[]'::jsonb)
+FROM chats
+WHERE chat_shares.chat_id = chats.id AND chat_shares.snapshot_at IS NOT NULL AND chat_shares.snapshot_agents IS NULL;
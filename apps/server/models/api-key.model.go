@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// APIKey is a personal access token for programmatic access. Only its hash is stored;
+// Prefix keeps the leading characters so users can tell their keys apart.
+type APIKey struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID      `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	UserID     uint           `gorm:"index;not null" json:"-"`
+	User       User           `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Name       string         `gorm:"not null" json:"name"`
+	Prefix     string         `gorm:"not null" json:"prefix"`
+	KeyHash    string         `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     pq.StringArray `gorm:"type:text[]" json:"scopes"`
+	ExpiresAt  *time.Time     `json:"expiresAt"`
+	LastUsedAt *time.Time     `json:"lastUsedAt"`
+	RevokedAt  *time.Time     `json:"revokedAt"`
+}
+
+func (k APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuditAction string
+
+const (
+	AuditLoginLockout   AuditAction = "login.lockout"
+	AuditLoginIPLockout AuditAction = "login.ip_lockout"
+
+	AuditAdminRoleChanged     AuditAction = "admin.role_changed"
+	AuditAdminPlanChanged     AuditAction = "admin.plan_changed"
+	AuditAdminUserSuspended   AuditAction = "admin.user_suspended"
+	AuditAdminUserUnsuspended AuditAction = "admin.user_unsuspended"
+	AuditAdminPasswordReset   AuditAction = "admin.password_reset"
+)
+
+// AuditEvent records a security relevant event. UserID is the affected account and is empty when
+// no account is involved; ActorID is the logged in user who caused it, if any.
+type AuditEvent struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID   `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	Action     AuditAction `gorm:"index;not null" json:"action"`
+	UserID     *uint       `gorm:"index" json:"-"`
+	ActorID    *uint       `gorm:"index" json:"-"`
+	IPAddress  string      `json:"ipAddress"`
+	UserAgent  string      `json:"userAgent"`
+	Details    string      `json:"details"`
+}
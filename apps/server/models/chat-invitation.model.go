@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusDeclined InvitationStatus = "declined"
+	InvitationStatusRevoked  InvitationStatus = "revoked"
+)
+
+type ChatInvitation struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID        `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	ChatID     uint             `gorm:"index;not null" json:"-"`
+	Chat       Chat             `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE" json:"-"`
+	InviterID  uint             `gorm:"not null" json:"-"`
+	Inviter    User             `gorm:"foreignKey:InviterID" json:"-"`
+	InviteeID  uint             `gorm:"index;not null" json:"-"`
+	Invitee    User             `gorm:"foreignKey:InviteeID" json:"-"`
+	Role       ChatRole         `gorm:"type:varchar(6);check:role IN ('editor', 'viewer');not null" json:"role"`
+	Status     InvitationStatus `gorm:"type:varchar(8);default:'pending';not null" json:"status"`
+}
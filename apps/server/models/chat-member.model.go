@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatRole string
+
+const (
+	ChatRoleOwner  ChatRole = "owner"
+	ChatRoleEditor ChatRole = "editor"
+	ChatRoleViewer ChatRole = "viewer"
+)
+
+var chatRoleRanks = map[ChatRole]int{
+	ChatRoleViewer: 1,
+	ChatRoleEditor: 2,
+	ChatRoleOwner:  3,
+}
+
+// IsValid checks if the ChatRole is valid
+func (r ChatRole) IsValid() bool {
+	_, ok := chatRoleRanks[r]
+	return ok
+}
+
+// Allows reports whether the role grants at least the permissions of the required role
+func (r ChatRole) Allows(required ChatRole) bool {
+	return chatRoleRanks[r] >= chatRoleRanks[required]
+}
+
+// RolesAllowing returns every role that grants at least the permissions of the required role
+func RolesAllowing(required ChatRole) []ChatRole {
+	var roles []ChatRole
+	for role := range chatRoleRanks {
+		if role.Allows(required) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Rows are hard deleted so a removed member can be invited again
+type ChatMember struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	ChatID     uint      `gorm:"uniqueIndex:idx_chat_members_chat_user;not null" json:"-"`
+	Chat       Chat      `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID     uint      `gorm:"uniqueIndex:idx_chat_members_chat_user;index;not null" json:"-"`
+	User       User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Role       ChatRole  `gorm:"type:varchar(6);check:role IN ('owner', 'editor', 'viewer');not null" json:"role"`
+}
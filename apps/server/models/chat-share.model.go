@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatShare struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	TokenHash  string    `gorm:"uniqueIndex;not null" json:"-"`
+	ChatID     uint      `gorm:"index;not null" json:"-"`
+	Chat       Chat      `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID     uint      `gorm:"not null" json:"-"`
+	// Messages created after SnapshotAt are hidden from the shared transcript
+	SnapshotAt *time.Time `json:"snapshotAt"`
+	// The chat's name and agents at SnapshotAt, so later renames and agent changes stay out of the share
+	SnapshotChatName string           `json:"-"`
+	SnapshotAgents   []ChatShareAgent `gorm:"serializer:json;type:jsonb" json:"-"`
+	ExpiresAt        *time.Time       `json:"expiresAt"`
+	RevokedAt        *time.Time       `json:"revokedAt"`
+}
+
+type ChatShareAgent struct {
+	ExternalID uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Lingo      string    `json:"lingo,omitempty"`
+	Traits     []string  `json:"traits,omitempty"`
+}
+
+func (s ChatShare) IsActive(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
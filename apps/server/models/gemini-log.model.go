@@ -5,13 +5,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// GeminiLogs records every model call. UserID is the user the call is charged to;
+// ChatID and AgentID are empty for calls made outside a chat or by no agent.
 type GeminiLogs struct {
-	gorm.Model   `json:"-"`
-	ExternalID   uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
-	Prompt       string    `json:"prompt"`
-	InputTokens  int       `json:"inputTokens"`
-	OutputTokens int       `json:"outputTokens"`
-	TotalTokens  int       `json:"totalTokens"`
-	SenderType   string    `gorm:"type:sender_type_enum" json:"senderType"`
-	SenderID     uint      `json:"_"`
+	gorm.Model    `json:"-"`
+	ExternalID    uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	Prompt        string    `json:"prompt"`
+	InputTokens   int       `json:"inputTokens"`
+	OutputTokens  int       `json:"outputTokens"`
+	TotalTokens   int       `json:"totalTokens"`
+	SenderType    string    `gorm:"type:sender_type_enum" json:"senderType"`
+	SenderID      uint      `json:"_"`
+	UserID        *uint     `json:"-"`
+	ChatID        *uint     `json:"-"`
+	AgentID       *uint     `json:"-"`
+	ModelName     string    `gorm:"column:model" json:"model"`
+	LatencyMs     int64     `json:"latencyMs"`
+	FinishReason  string    `json:"finishReason"`
+	EstimatedCost float64   `json:"estimatedCost"`
+	Error         string    `json:"error,omitempty"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one key, either an IP address or a username
+type LoginThrottle struct {
+	ID            uint   `gorm:"primarykey"`
+	Key           string `gorm:"uniqueIndex;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -14,4 +14,6 @@ type Message struct {
 	SenderType string `gorm:"type:sender_type_enum" json:"senderType"`
 	SenderID   uint   `json:"_"`
 	Chat       Chat   `gorm:"foreignKey:ChatID" json:"-"`
+	// Resolved from the sender's username or agent name when loading chat history
+	SenderName string `gorm:"-" json:"-"`
 }
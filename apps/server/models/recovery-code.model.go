@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time second factor for when the authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	User     User   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CodeHash string `gorm:"uniqueIndex;not null"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is a login on one device. Its refresh token rotates on every use;
+// the previous hash is kept so a replayed token can be detected and the session revoked.
+type Session struct {
+	gorm.Model        `json:"-"`
+	ExternalID        uuid.UUID  `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	UserID            uint       `gorm:"index;not null" json:"-"`
+	User              User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"`
+	UserAgent         string     `json:"userAgent"`
+	IPAddress         string     `json:"ipAddress"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	ExpiresAt         time.Time  `json:"expiresAt"`
+	RevokedAt         *time.Time `json:"revokedAt"`
+}
+
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// UsageCounter totals one user's model requests and tokens for one UTC day
+type UsageCounter struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"uniqueIndex:idx_usage_counters_user_day;not null"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Day       time.Time `gorm:"uniqueIndex:idx_usage_counters_user_day;type:date;not null"`
+	Requests  int64     `gorm:"not null;default:0"`
+	Tokens    int64     `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
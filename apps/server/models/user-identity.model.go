@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external OIDC provider
+type UserIdentity struct {
+	gorm.Model `json:"-"`
+	ExternalID uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"-"`
+	User       User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Provider   string    `gorm:"not null" json:"provider"`
+	Issuer     string    `gorm:"uniqueIndex:idx_user_identities_issuer_subject;not null" json:"issuer"`
+	Subject    string    `gorm:"uniqueIndex:idx_user_identities_issuer_subject;not null" json:"-"`
+	Email      string    `json:"email"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenPurpose string
+
+const (
+	UserTokenVerifyEmail   UserTokenPurpose = "verify_email"
+	UserTokenResetPassword UserTokenPurpose = "reset_password"
+)
+
+// UserToken is a single-use token emailed to a user. Only its hash is stored.
+type UserToken struct {
+	gorm.Model
+	UserID    uint             `gorm:"index;not null"`
+	User      User             `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Purpose   UserTokenPurpose `gorm:"index;not null"`
+	TokenHash string           `gorm:"uniqueIndex;not null"`
+	// The address the token was sent to, so a token for an old address cannot verify a new one
+	Email     string `gorm:"not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func (t UserToken) IsActive(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
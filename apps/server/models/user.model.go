@@ -1,15 +1,43 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+// IsValid checks if the UserRole is valid
+func (r UserRole) IsValid() bool {
+	return r == UserRoleUser || r == UserRoleAdmin
+}
+
 type User struct {
-	gorm.Model   `json:"-"`
-	ExternalID   uuid.UUID `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
-	Username     string    `gorm:"unique;type:string" json:"userName"`
-	FullName     string    `json:"fullName"`
-	PasswordHash string    `json:"-"`
-	Chats        []Chat    `gorm:"foreignKey:UserID" json:"chats"`
+	gorm.Model      `json:"-"`
+	ExternalID      uuid.UUID  `gorm:"unique;type:uuid;default:gen_random_uuid()" json:"id"`
+	Username        string     `gorm:"unique;type:string" json:"userName"`
+	FullName        string     `json:"fullName"`
+	Email           *string    `gorm:"uniqueIndex" json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	PasswordHash    string     `json:"-"`
+	Role            UserRole   `gorm:"not null;default:'user'" json:"role"`
+	SuspendedAt     *time.Time `json:"suspendedAt"`
+	// Quota plan name. Empty means the configured default plan.
+	Plan string `json:"plan"`
+	// Encrypted TOTP secret, set once enrollment starts and active once TwoFactorEnabledAt is set
+	TOTPSecret         string     `json:"-"`
+	TOTPLastStep       int64      `json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"twoFactorEnabledAt"`
+	Chats              []Chat     `gorm:"foreignKey:UserID" json:"chats"`
+}
+
+func (u User) IsSuspended() bool {
+	return u.SuspendedAt != nil
 }
@@ -9,23 +9,34 @@ import (
 )
 
 type Prompt struct {
-	agent       models.Agent
-	chatHistory []models.Message
-	userName    string
-	otherAgent  models.Agent
-	userMessage string
+	agent        models.Agent
+	chatHistory  []models.Message
+	userName     string
+	participants []string
+	otherAgent   models.Agent
+	userMessage  string
 }
 
-func NewPromptGenerator(agent models.Agent, chatHistory []models.Message, userName string, otherAgent models.Agent, userMessage string) Prompt {
+// userName is the human who sent userMessage; participants lists every human member of the chat
+func NewPromptGenerator(agent models.Agent, chatHistory []models.Message, userName string, participants []string, otherAgent models.Agent, userMessage string) Prompt {
 	return Prompt{
-		agent:       agent,
-		chatHistory: chatHistory,
-		userName:    userName,
-		otherAgent:  otherAgent,
-		userMessage: userMessage,
+		agent:        agent,
+		chatHistory:  chatHistory,
+		userName:     userName,
+		participants: participants,
+		otherAgent:   otherAgent,
+		userMessage:  userMessage,
 	}
 }
 
+func (p *Prompt) describeHumans() string {
+	if len(p.participants) <= 1 {
+		return fmt.Sprintf("a human user called %s", p.userName)
+	}
+	last := len(p.participants) - 1
+	return fmt.Sprintf("human users called %s and %s", strings.Join(p.participants[:last], ", "), p.participants[last])
+}
+
 func (p *Prompt) GenerateBasicPrompt() string {
 	var otherAgentTraits string = ""
 	if p.otherAgent.Metadata != nil {
@@ -33,18 +44,18 @@ func (p *Prompt) GenerateBasicPrompt() string {
 	}
 	return fmt.Sprintf(`
 You are %s, an AI agent with the following traits: %s.
-You are in a group chat with a human user called %s and another AI agent named %s with traits: %s.
+You are in a group chat with %s and another AI agent named %s with traits: %s.
 Chat History:
 %s
 
-The user's latest message is: "%s" 
+The latest message, from %s, is: "%s"
 
-Please respond to the user's message and, if appropriate, to the other agent's previous message. Refer to them as @<targetname>.
+Please respond to %s's message and, if appropriate, to the other agent's previous message. Refer to them as @<targetname>.
 Use your defined traits to guide your response style and content.
 Engage in a natural, flowing conversation while keeping responses as short as possible, and feel free to ask questions or make observations to keep the dialogue engaging.
 Remember as much context as you can from previous messages and use them when necessary.
-`, p.agent.Name, strings.Join(p.agent.Metadata.Traits, ", "), p.userName, p.otherAgent.Name, otherAgentTraits,
-		utils.FormatChatHistory(p.chatHistory), p.userMessage)
+`, p.agent.Name, strings.Join(p.agent.Metadata.Traits, ", "), p.describeHumans(), p.otherAgent.Name, otherAgentTraits,
+		utils.FormatChatHistory(p.chatHistory), p.userName, p.userMessage, p.userName)
 }
 
 func (p *Prompt) GenerateReflectionPrompt(otherAgentResponses map[uint]string) string {
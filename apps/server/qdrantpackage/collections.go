@@ -2,6 +2,7 @@ package qdrantpackage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -10,14 +11,36 @@ import (
 
 type CollectionName string
 
+var ErrVectorSizeMismatch = errors.New("collection vector size does not match the embedding model")
+
 const DEFAULT_VECTOR_SIZE = 1536
 
+// Matches the output dimensionality of text-embedding-004, the default embedding model
+const MESSAGES_VECTOR_SIZE = 768
+
 const (
-	Messages CollectionName = "messages"
+	// Messages is named for its dimensionality. Earlier deployments created a 1536-dimension "messages"
+	// collection, and reusing that name would fail every upsert of a 768-dimension vector.
+	Messages CollectionName = "messages_768"
 )
 
-func CreateQdrantCollections(client *qdrant.Client, collectionNames []CollectionName) error {
+var collectionVectorSizes = map[CollectionName]uint64{
+	Messages: MESSAGES_VECTOR_SIZE,
+}
+
+func vectorSize(collectionName CollectionName) uint64 {
+	if size, ok := collectionVectorSizes[collectionName]; ok {
+		return size
+	}
+	return DEFAULT_VECTOR_SIZE
+}
+
+func (s *QdrantStore) CreateCollections(collectionNames []CollectionName) error {
 	ctx := context.Background()
+	client := s.client
+	if client == nil {
+		return fmt.Errorf("qdrant client is not connected")
+	}
 
 	for _, collectionName := range collectionNames {
 		exists, err := client.CollectionExists(ctx, string(collectionName))
@@ -26,6 +49,9 @@ func CreateQdrantCollections(client *qdrant.Client, collectionNames []Collection
 		}
 
 		if exists {
+			if err := checkVectorSize(ctx, client, collectionName); err != nil {
+				return err
+			}
 			log.Printf("Collection %s already exists. skipping...", collectionName)
 			continue
 		}
@@ -34,7 +60,7 @@ func CreateQdrantCollections(client *qdrant.Client, collectionNames []Collection
 		err = client.CreateCollection(ctx, &qdrant.CreateCollection{
 			CollectionName: string(collectionName),
 			VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-				Size:     DEFAULT_VECTOR_SIZE,
+				Size:     vectorSize(collectionName),
 				Distance: qdrant.Distance_Cosine,
 			}),
 		})
@@ -47,3 +73,18 @@ func CreateQdrantCollections(client *qdrant.Client, collectionNames []Collection
 
 	return nil
 }
+
+// checkVectorSize refuses a collection created with a different dimensionality, which would
+// otherwise only show up as a failed upsert for every message
+func checkVectorSize(ctx context.Context, client *qdrant.Client, collectionName CollectionName) error {
+	info, err := client.GetCollectionInfo(ctx, string(collectionName))
+	if err != nil {
+		return fmt.Errorf("error reading collection %s: %w", collectionName, err)
+	}
+
+	got := info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+	if want := vectorSize(collectionName); got != want {
+		return fmt.Errorf("%w: collection %s has %d-dimension vectors but %d are expected, recreate it or point Trio at a new collection", ErrVectorSizeMismatch, collectionName, got, want)
+	}
+	return nil
+}
@@ -1,25 +1,50 @@
 package qdrantpackage
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
-var QdrantClient *qdrant.Client
+// VectorStore holds message embeddings for semantic search
+type VectorStore interface {
+	UpsertMessagePoints(ctx context.Context, points []MessagePoint) error
+	// Ping reports whether the store is reachable
+	Ping(ctx context.Context) error
+	Close() error
+}
 
-func ConnectToQdrant() error {
-	var err error
+type QdrantStore struct {
+	client *qdrant.Client
+}
 
-	QdrantClient, err = qdrant.NewClient(&qdrant.Config{
-		Host: os.Getenv("QDRANT_HOST"),
-		Port: 6334,
+// ConnectToQdrant always returns a usable store; if the connection failed its calls return an error
+func ConnectToQdrant(host string, port int) (*QdrantStore, error) {
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host: host,
+		Port: port,
 	})
 	if err != nil {
 		slog.Error("Failed to connect to qdrant", "error", err)
-		return err
+		return &QdrantStore{}, err
 	}
 	slog.Info("Successfully connected to qdrant")
-	return nil
+	return &QdrantStore{client: client}, nil
+}
+
+func (s *QdrantStore) Ping(ctx context.Context) error {
+	if s.client == nil {
+		return fmt.Errorf("qdrant client is not connected")
+	}
+	_, err := s.client.HealthCheck(ctx)
+	return err
+}
+
+func (s *QdrantStore) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Close()
 }
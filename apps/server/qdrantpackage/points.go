@@ -0,0 +1,53 @@
+package qdrantpackage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+type MessagePoint struct {
+	MessageID  string
+	ChatID     string
+	SenderType string
+	SenderName string
+	Content    string
+	Vector     []float32
+}
+
+func (s *QdrantStore) UpsertMessagePoints(ctx context.Context, points []MessagePoint) error {
+	client := s.client
+	if client == nil {
+		return fmt.Errorf("qdrant client is not connected")
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	structs := make([]*qdrant.PointStruct, 0, len(points))
+	for _, point := range points {
+		structs = append(structs, &qdrant.PointStruct{
+			Id:      qdrant.NewIDUUID(point.MessageID),
+			Vectors: qdrant.NewVectorsDense(point.Vector),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"chat_id":     point.ChatID,
+				"sender_type": point.SenderType,
+				"sender_name": point.SenderName,
+				"content":     point.Content,
+			}),
+		})
+	}
+
+	wait := true
+	_, err := client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: string(Messages),
+		Wait:           &wait,
+		Points:         structs,
+	})
+	if err != nil {
+		return fmt.Errorf("error upserting message points: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var quotaLimitNames = []string{"Requests-Day", "Tokens-Day", "Requests-Month", "Tokens-Month"}
+
+// HeaderNames lists every header SetHeaders and RespondIfExceeded may write, for CORS
+func HeaderNames() []string {
+	names := []string{"X-Quota-Plan", "X-Quota-Reset"}
+	for _, name := range quotaLimitNames {
+		names = append(names, "X-Quota-Limit-"+name, "X-Quota-Remaining-"+name)
+	}
+	return names
+}
+
+// SetHeaders reports the plan's remaining quota. Limits the plan does not have are left out.
+func SetHeaders(c *gin.Context, status Status) {
+	c.Header("X-Quota-Plan", status.Plan.Name)
+
+	setRemaining := func(name string, limit int64, used int64) {
+		if limit > 0 {
+			c.Header("X-Quota-Limit-"+name, strconv.FormatInt(limit, 10))
+			c.Header("X-Quota-Remaining-"+name, strconv.FormatInt(remaining(limit, used), 10))
+		}
+	}
+	setRemaining("Requests-Day", status.Plan.DailyRequests, status.Day.Requests)
+	setRemaining("Tokens-Day", status.Plan.DailyTokens, status.Day.Tokens)
+	setRemaining("Requests-Month", status.Plan.MonthlyRequests, status.Month.Requests)
+	setRemaining("Tokens-Month", status.Plan.MonthlyTokens, status.Month.Tokens)
+}
+
+// RespondIfExceeded writes a 429 with quota headers when err is a quota error and reports whether it did
+func RespondIfExceeded(c *gin.Context, err error) bool {
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+
+	resetsAt := exceeded.Status.ResetsAt()
+	SetHeaders(c, exceeded.Status)
+	c.Header("X-Quota-Reset", strconv.FormatInt(resetsAt.Unix(), 10))
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(resetsAt).Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": exceeded.Error(), "resetsAt": resetsAt})
+	return true
+}
@@ -0,0 +1,102 @@
+package quota
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/somtojf/trio/config"
+)
+
+const (
+	PlanFree      = "free"
+	PlanPro       = "pro"
+	PlanUnlimited = "unlimited"
+)
+
+// Plan caps how much a user may use the models. A zero limit means unlimited.
+type Plan struct {
+	Name            string `json:"name"`
+	DailyRequests   int64  `json:"dailyRequests"`
+	MonthlyRequests int64  `json:"monthlyRequests"`
+	DailyTokens     int64  `json:"dailyTokens"`
+	MonthlyTokens   int64  `json:"monthlyTokens"`
+}
+
+var defaultPlans = map[string]Plan{
+	PlanFree: {
+		Name:            PlanFree,
+		DailyRequests:   200,
+		MonthlyRequests: 3000,
+		DailyTokens:     200_000,
+		MonthlyTokens:   3_000_000,
+	},
+	PlanPro: {
+		Name:            PlanPro,
+		DailyRequests:   2000,
+		MonthlyRequests: 40_000,
+		DailyTokens:     2_000_000,
+		MonthlyTokens:   40_000_000,
+	},
+	PlanUnlimited: {Name: PlanUnlimited},
+}
+
+// Plans is the loaded set of tiers. It is read-only once loaded, so handlers share one freely.
+type Plans struct {
+	plans       map[string]Plan
+	defaultPlan string
+}
+
+// LoadPlans adds or replaces tiers with the configured plans and sets the default plan
+func LoadPlans(cfg config.QuotaConfig) (*Plans, error) {
+	loaded := make(map[string]Plan, len(defaultPlans)+len(cfg.Plans))
+	for name, plan := range defaultPlans {
+		loaded[name] = plan
+	}
+	for name, plan := range cfg.Plans {
+		if name == "" {
+			return nil, fmt.Errorf("QUOTA_PLANS has a plan without a name")
+		}
+		if plan.DailyRequests < 0 || plan.MonthlyRequests < 0 || plan.DailyTokens < 0 || plan.MonthlyTokens < 0 {
+			return nil, fmt.Errorf("quota plan %q has a negative limit", name)
+		}
+		loaded[name] = Plan{
+			Name:            name,
+			DailyRequests:   plan.DailyRequests,
+			MonthlyRequests: plan.MonthlyRequests,
+			DailyTokens:     plan.DailyTokens,
+			MonthlyTokens:   plan.MonthlyTokens,
+		}
+	}
+
+	if _, ok := loaded[cfg.DefaultPlan]; !ok {
+		return nil, fmt.Errorf("QUOTA_DEFAULT_PLAN %q is not a known plan", cfg.DefaultPlan)
+	}
+
+	return &Plans{plans: loaded, defaultPlan: cfg.DefaultPlan}, nil
+}
+
+// Get returns the named plan, falling back to the default plan for unknown names
+func (p *Plans) Get(name string) Plan {
+	if plan, ok := p.plans[name]; ok {
+		return plan
+	}
+	return p.plans[p.defaultPlan]
+}
+
+func (p *Plans) Has(name string) bool {
+	_, ok := p.plans[name]
+	return ok
+}
+
+func (p *Plans) Default() string {
+	return p.defaultPlan
+}
+
+func (p *Plans) List() []Plan {
+	list := make([]Plan, 0, len(p.plans))
+	for _, plan := range p.plans {
+		list = append(list, plan)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
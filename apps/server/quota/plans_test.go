@@ -0,0 +1,47 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/somtojf/trio/config"
+)
+
+func TestLoadPlans(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  config.QuotaConfig
+	}{
+		{"unknown default plan", config.QuotaConfig{DefaultPlan: "gold"}},
+		{"negative limit", config.QuotaConfig{
+			Plans:       map[string]config.QuotaPlan{"team": {DailyRequests: -1}},
+			DefaultPlan: PlanFree,
+		}},
+		{"unnamed plan", config.QuotaConfig{
+			Plans:       map[string]config.QuotaPlan{"": {DailyRequests: 10}},
+			DefaultPlan: PlanFree,
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := LoadPlans(tc.cfg); err == nil {
+				t.Fatal("expected the plans to be rejected")
+			}
+		})
+	}
+
+	plans, err := LoadPlans(config.QuotaConfig{
+		Plans:       map[string]config.QuotaPlan{"team": {DailyRequests: 500}, PlanFree: {DailyRequests: 20}},
+		DefaultPlan: "team",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plans.Default() != "team" || plans.Get("missing").DailyRequests != 500 {
+		t.Fatal("unknown plan names should fall back to the configured default")
+	}
+	if free := plans.Get(PlanFree); free.DailyRequests != 20 || free.MonthlyRequests != 0 {
+		t.Fatalf("a configured plan should replace the built-in one entirely, got %+v", free)
+	}
+	if !plans.Has(PlanPro) {
+		t.Fatal("built-in plans that are not overridden should remain")
+	}
+}
@@ -0,0 +1,128 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+var ErrQuotaExceeded = errors.New("usage quota exceeded")
+
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// Status is a user's plan and consumption in the current day and month, both in UTC
+type Status struct {
+	Plan          Plan      `json:"plan"`
+	Day           Usage     `json:"day"`
+	Month         Usage     `json:"month"`
+	DayResetsAt   time.Time `json:"dayResetsAt"`
+	MonthResetsAt time.Time `json:"monthResetsAt"`
+}
+
+// ExceededError carries the status so handlers can report the remaining quota
+type ExceededError struct {
+	Status Status
+	Limit  string
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s: %s limit of the %s plan reached", ErrQuotaExceeded, e.Limit, e.Status.Plan.Name)
+}
+
+func (e *ExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+func remaining(limit int64, used int64) int64 {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+// exceeded names the first limit that has been reached and when it resets
+func (s Status) exceeded() (string, time.Time, bool) {
+	switch {
+	case s.Plan.DailyRequests > 0 && s.Day.Requests >= s.Plan.DailyRequests:
+		return "daily request", s.DayResetsAt, true
+	case s.Plan.DailyTokens > 0 && s.Day.Tokens >= s.Plan.DailyTokens:
+		return "daily token", s.DayResetsAt, true
+	case s.Plan.MonthlyRequests > 0 && s.Month.Requests >= s.Plan.MonthlyRequests:
+		return "monthly request", s.MonthResetsAt, true
+	case s.Plan.MonthlyTokens > 0 && s.Month.Tokens >= s.Plan.MonthlyTokens:
+		return "monthly token", s.MonthResetsAt, true
+	}
+	return "", time.Time{}, false
+}
+
+// ResetsAt is when the exhausted limit frees up again, or zero if nothing is exhausted
+func (s Status) ResetsAt() time.Time {
+	_, resetsAt, _ := s.exceeded()
+	return resetsAt
+}
+
+func GetStatus(ctx context.Context, usage repository.UsageRepository, plans *Plans, user models.User) (Status, error) {
+	return statusAt(ctx, usage, plans, user, time.Now().UTC())
+}
+
+func statusAt(ctx context.Context, usage repository.UsageRepository, plans *Plans, user models.User, now time.Time) (Status, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	status := Status{
+		Plan:          plans.Get(user.Plan),
+		DayResetsAt:   today.AddDate(0, 0, 1),
+		MonthResetsAt: monthStart.AddDate(0, 1, 0),
+	}
+
+	counters, err := usage.Counters(ctx, user.ID, monthStart)
+	if err != nil {
+		return status, err
+	}
+	for _, counter := range counters {
+		status.Month.Requests += counter.Requests
+		status.Month.Tokens += counter.Tokens
+		if counter.Day.Equal(today) {
+			status.Day.Requests += counter.Requests
+			status.Day.Tokens += counter.Tokens
+		}
+	}
+
+	return status, nil
+}
+
+// Check returns an *ExceededError once any limit of the user's plan is used up.
+// Token usage is only known after a call, so the last call of a period may overshoot the token limit.
+func Check(ctx context.Context, usage repository.UsageRepository, plans *Plans, user models.User) (Status, error) {
+	return checkAt(ctx, usage, plans, user, time.Now().UTC())
+}
+
+func checkAt(ctx context.Context, usage repository.UsageRepository, plans *Plans, user models.User, now time.Time) (Status, error) {
+	status, err := statusAt(ctx, usage, plans, user, now)
+	if err != nil {
+		return status, err
+	}
+
+	if limit, _, ok := status.exceeded(); ok {
+		return status, &ExceededError{Status: status, Limit: limit}
+	}
+	return status, nil
+}
+
+// Record adds one model request and its tokens to the user's counter for today
+func Record(ctx context.Context, usage repository.UsageRepository, userID uint, tokens int64) error {
+	return recordAt(ctx, usage, userID, tokens, time.Now().UTC())
+}
+
+func recordAt(ctx context.Context, usage repository.UsageRepository, userID uint, tokens int64, now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	return usage.Record(ctx, userID, today, tokens)
+}
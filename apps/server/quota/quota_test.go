@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+)
+
+func TestCheckRollsOverByDayAndMonth(t *testing.T) {
+	ctx := context.Background()
+	usage := repository.NewMemory().Usage
+	user := models.User{Plan: PlanFree}
+	user.ID = 1
+	plans, err := LoadPlans(config.QuotaConfig{DefaultPlan: PlanFree})
+	if err != nil {
+		t.Fatal(err)
+	}
+	free := plans.Get(PlanFree)
+
+	// The last day of January uses up the daily requests
+	lastDay := time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC)
+	for i := int64(0); i < free.DailyRequests; i++ {
+		if err := recordAt(ctx, usage, user.ID, 10, lastDay); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = checkAt(ctx, usage, plans, user, lastDay)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Limit != "daily request" {
+		t.Fatalf("expected the daily request limit, got %v", err)
+	}
+	if want := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC); !exceeded.Status.ResetsAt().Equal(want) {
+		t.Fatalf("daily limit should reset at midnight UTC, got %s", exceeded.Status.ResetsAt())
+	}
+
+	// A minute later it is a new day and a new month, so none of it counts
+	nextMonth := lastDay.Add(2 * time.Minute)
+	status, err := checkAt(ctx, usage, plans, user, nextMonth)
+	if err != nil {
+		t.Fatalf("quota should roll over with the month, got %v", err)
+	}
+	if status.Day.Requests != 0 || status.Month.Requests != 0 {
+		t.Fatalf("expected no usage in the new period, got %+v", status)
+	}
+
+	// Earlier days of the same month still count toward the month but not the day
+	if err := recordAt(ctx, usage, user.ID, 500, nextMonth); err != nil {
+		t.Fatal(err)
+	}
+	status, err = checkAt(ctx, usage, plans, user, nextMonth.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Day.Requests != 0 || status.Month.Requests != 1 || status.Month.Tokens != 500 {
+		t.Fatalf("expected yesterday's usage in the month only, got %+v", status)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !status.MonthResetsAt.Equal(want) {
+		t.Fatalf("month should reset on the first, got %s", status.MonthResetsAt)
+	}
+}
+
+func TestRespondIfExceeded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	if RespondIfExceeded(c, errors.New("something else")) {
+		t.Fatal("only quota errors should be answered")
+	}
+
+	resetsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	status := Status{
+		Plan:          Plan{Name: "tiny", DailyRequests: 5, MonthlyTokens: 1000},
+		Day:           Usage{Requests: 5, Tokens: 300},
+		Month:         Usage{Requests: 40, Tokens: 300},
+		DayResetsAt:   resetsAt,
+		MonthResetsAt: resetsAt.AddDate(0, 1, 0),
+	}
+	err := &ExceededError{Status: status, Limit: "daily request"}
+
+	if !RespondIfExceeded(c, err) {
+		t.Fatal("a quota error should be answered")
+	}
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want 429", recorder.Code)
+	}
+
+	for header, want := range map[string]string{
+		"X-Quota-Plan":                     "tiny",
+		"X-Quota-Limit-Requests-Day":       "5",
+		"X-Quota-Remaining-Requests-Day":   "0",
+		"X-Quota-Limit-Tokens-Month":       "1000",
+		"X-Quota-Remaining-Tokens-Month":   "700",
+		"X-Quota-Limit-Tokens-Day":         "",
+		"X-Quota-Remaining-Requests-Month": "",
+		"X-Quota-Reset":                    strconv.FormatInt(resetsAt.Unix(), 10),
+	} {
+		if got := recorder.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	retryAfter, _ := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if retryAfter < 3590 || retryAfter > 3601 {
+		t.Errorf("Retry-After = %d, want about an hour", retryAfter)
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if jsonErr := json.Unmarshal(recorder.Body.Bytes(), &body); jsonErr != nil || body.Error != err.Error() {
+		t.Fatalf("unexpected body %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket holding up to Requests tokens that refills completely over Period
+type Limit struct {
+	Name     string
+	Requests int
+	Period   time.Duration
+}
+
+// RefillInterval is how long one token takes to come back
+func (l Limit) RefillInterval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Policy formats the limit for the RateLimit-Policy header
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(math.Ceil(l.Period.Seconds())))
+}
+
+// Limits groups endpoints by cost. Auth is keyed by IP, the others by user.
+type Limits struct {
+	Auth       Limit
+	Generation Limit
+	Read       Limit
+	Write      Limit
+}
+
+// ParseLimit reads a limit formatted as "<requests>/<period>", such as "10/m", "500/h" or "5/30s"
+func ParseLimit(name string, value string) (Limit, error) {
+	requests, period, err := parseLimit(value)
+	if err != nil {
+		return Limit{}, err
+	}
+	return Limit{Name: name, Requests: requests, Period: period}, nil
+}
+
+func parseLimit(value string) (int, time.Duration, error) {
+	count, window, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return 0, 0, fmt.Errorf("expected <requests>/<period>")
+	}
+
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests < 1 {
+		return 0, 0, fmt.Errorf("requests must be a positive number")
+	}
+
+	var period time.Duration
+	switch window {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(window)
+		if err != nil || period <= 0 {
+			return 0, 0, fmt.Errorf("period must be s, m, h or a duration such as 30s")
+		}
+	}
+
+	return requests, period, nil
+}
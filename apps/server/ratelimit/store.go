@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const CLEANUP_INTERVAL = time.Minute
+
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Until one more request is allowed, zero when Allowed
+	RetryAfter time.Duration
+	// Until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets. MemoryStore serves a single process; replicas that must
+// share limits need a Store backed by something shared, such as Redis or Postgres.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	Close() error
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+		done:    make(chan struct{}),
+	}
+	go store.cleanup()
+	return store
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.RefillInterval()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now, period: limit.Period}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time since the last request
+	b.tokens = min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((capacity - b.tokens) * float64(perToken))
+
+	return result, nil
+}
+
+// cleanup forgets buckets that have been idle long enough to be full again
+func (s *MemoryStore) cleanup() {
+	ticker := time.NewTicker(CLEANUP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *MemoryStore) sweep() {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore(t *testing.T) (*MemoryStore, *clock) {
+	t.Helper()
+	c := &clock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = func() time.Time { return c.now }
+	t.Cleanup(func() { store.Close() })
+	return store, c
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// One token every 10 seconds
+	limit := Limit{Name: "test", Requests: 3, Period: 30 * time.Second}
+
+	type step struct {
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{"burst drains the bucket", []step{
+			{0, true, 2, 0, 10 * time.Second},
+			{0, true, 1, 0, 20 * time.Second},
+			{0, true, 0, 0, 30 * time.Second},
+			{0, false, 0, 10 * time.Second, 30 * time.Second},
+		}},
+		{"partial refill counts toward the next token", []step{
+			{0, true, 2, 0, 10 * time.Second},
+			{0, true, 1, 0, 20 * time.Second},
+			{0, true, 0, 0, 30 * time.Second},
+			{4 * time.Second, false, 0, 6 * time.Second, 26 * time.Second},
+			{6 * time.Second, true, 0, 0, 30 * time.Second},
+		}},
+		{"refill never exceeds capacity", []step{
+			{0, true, 2, 0, 10 * time.Second},
+			{time.Hour, true, 2, 0, 10 * time.Second},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, c := newTestStore(t)
+			for i, s := range tc.steps {
+				c.advance(s.advance)
+				result, err := store.Take(context.Background(), "key", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := Result{Allowed: s.allowed, Remaining: s.remaining, RetryAfter: s.retryAfter, ResetAfter: s.resetAfter}
+				if result != want {
+					t.Fatalf("step %d: got %+v, want %+v", i, result, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore(t)
+	limit := Limit{Name: "test", Requests: 1, Period: time.Minute}
+
+	if result, _ := store.Take(context.Background(), "a", limit); !result.Allowed {
+		t.Fatal("first request for a should pass")
+	}
+	if result, _ := store.Take(context.Background(), "b", limit); !result.Allowed {
+		t.Fatal("a's bucket should not limit b")
+	}
+}
+
+func TestMemoryStoreSweepForgetsIdleBuckets(t *testing.T) {
+	store, c := newTestStore(t)
+	short := Limit{Name: "short", Requests: 1, Period: time.Minute}
+	long := Limit{Name: "long", Requests: 1, Period: time.Hour}
+
+	store.Take(context.Background(), "short", short)
+	store.Take(context.Background(), "long", long)
+
+	c.advance(time.Minute)
+	store.sweep()
+	if len(store.buckets) != 2 {
+		t.Fatalf("buckets idle for exactly their period should be kept, have %d", len(store.buckets))
+	}
+
+	c.advance(time.Second)
+	store.sweep()
+	if _, ok := store.buckets["short"]; ok {
+		t.Fatal("a bucket idle past its period should be forgotten")
+	}
+	if _, ok := store.buckets["long"]; !ok {
+		t.Fatal("a bucket still refilling should be kept")
+	}
+}
@@ -0,0 +1,93 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+)
+
+const subscriptionBuffer = 64
+
+// Broker fans events out to the connections of the users they are addressed to.
+// MemoryBroker serves a single server process; PostgresBroker relays events between replicas.
+type Broker interface {
+	Publish(ctx context.Context, envelope Envelope) error
+	Subscribe(userID uint) *Subscription
+	Close() error
+}
+
+type Subscription struct {
+	Events <-chan Event
+	cancel func()
+}
+
+// Unsubscribe stops delivery and closes Events
+func (s *Subscription) Unsubscribe() {
+	s.cancel()
+}
+
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan Event]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[uint]map[chan Event]struct{})}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, envelope Envelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, userID := range envelope.UserIDs {
+		for events := range b.subscribers[userID] {
+			select {
+			case events <- envelope.Event:
+			default:
+				// Slow connections drop events rather than blocking generation
+			}
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(userID uint) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][events] = struct{}{}
+	b.mu.Unlock()
+
+	return &Subscription{
+		Events: events,
+		cancel: func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			// Already closed by Close or a previous Unsubscribe
+			if _, ok := b.subscribers[userID][events]; !ok {
+				return
+			}
+			delete(b.subscribers[userID], events)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			close(events)
+		},
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, subscribers := range b.subscribers {
+		for events := range subscribers {
+			close(events)
+		}
+		delete(b.subscribers, userID)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package realtime
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+)
+
+type EventType string
+
+const (
+	EventMessageCreated     EventType = "message.created"
+	EventGenerationStarted  EventType = "generation.started"
+	EventGenerationFinished EventType = "generation.finished"
+	EventTyping             EventType = "typing"
+	EventAgentCreated       EventType = "agent.created"
+	EventAgentUpdated       EventType = "agent.updated"
+	EventAgentDeleted       EventType = "agent.deleted"
+	EventChatRenamed        EventType = "chat.renamed"
+	EventChatUpdated        EventType = "chat.updated"
+	EventChatDeleted        EventType = "chat.deleted"
+	EventMemberJoined       EventType = "member.joined"
+	EventMemberRemoved      EventType = "member.removed"
+)
+
+type Event struct {
+	Type   EventType `json:"type"`
+	ChatID uuid.UUID `json:"chatId"`
+	Data   any       `json:"data,omitempty"`
+	// Set when the payload was too large for the broker. Clients should refetch the chat.
+	Truncated bool      `json:"truncated,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Envelope is an event addressed to a set of users
+type Envelope struct {
+	UserIDs []uint `json:"userIds"`
+	Event   Event  `json:"event"`
+}
+
+type MessagePayload struct {
+	ID         uuid.UUID `json:"id"`
+	SenderType string    `json:"senderType"`
+	SenderID   uuid.UUID `json:"senderId"`
+	SenderName string    `json:"senderName"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type ActivityPayload struct {
+	// Username of the human typing, or name of the agent generating
+	Name string `json:"name"`
+}
+
+type MessageSender struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func AgentSender(agent models.Agent) MessageSender {
+	return MessageSender{ID: agent.ExternalID, Name: agent.Name}
+}
+
+func UserSender(user models.User) MessageSender {
+	return MessageSender{ID: user.ExternalID, Name: user.Username}
+}
@@ -0,0 +1,103 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	NOTIFY_CHANNEL = "trio_events"
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more
+	MAX_NOTIFY_PAYLOAD = 7900
+)
+
+// PostgresBroker relays events through LISTEN/NOTIFY so every replica can deliver
+// them to the connections it holds. Local delivery is handled by a MemoryBroker.
+type PostgresBroker struct {
+	db       *gorm.DB
+	listener *pq.Listener
+	local    *MemoryBroker
+	done     chan struct{}
+}
+
+func NewPostgresBroker(db *gorm.DB, dsn string) (*PostgresBroker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("Realtime listener connection event", "event", event, "error", err)
+		}
+	})
+	if err := listener.Listen(NOTIFY_CHANNEL); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", NOTIFY_CHANNEL, err)
+	}
+
+	broker := &PostgresBroker{
+		db:       db,
+		listener: listener,
+		local:    NewMemoryBroker(),
+		done:     make(chan struct{}),
+	}
+	go broker.relay()
+
+	return broker, nil
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, envelope Envelope) error {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > MAX_NOTIFY_PAYLOAD {
+		envelope.Event.Data = nil
+		envelope.Event.Truncated = true
+		if payload, err = json.Marshal(envelope); err != nil {
+			return err
+		}
+	}
+
+	return b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", NOTIFY_CHANNEL, string(payload)).Error
+}
+
+func (b *PostgresBroker) Subscribe(userID uint) *Subscription {
+	return b.local.Subscribe(userID)
+}
+
+func (b *PostgresBroker) Close() error {
+	close(b.done)
+	err := b.listener.Close()
+	b.local.Close()
+	return err
+}
+
+func (b *PostgresBroker) relay() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case notification, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification means the connection was re-established and events may have been missed
+			if notification == nil {
+				continue
+			}
+
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(notification.Extra), &envelope); err != nil {
+				slog.Error("Failed to decode realtime event", "error", err)
+				continue
+			}
+			b.local.Publish(context.Background(), envelope)
+		case <-time.After(90 * time.Second):
+			go b.listener.Ping()
+		}
+	}
+}
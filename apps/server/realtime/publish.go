@@ -0,0 +1,78 @@
+package realtime
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"gorm.io/gorm"
+)
+
+// NewBroker returns a PostgresBroker for the "postgres" kind, so events reach users
+// connected to other replicas, and an in-process MemoryBroker otherwise
+func NewBroker(kind string, db *gorm.DB, dsn string) (Broker, error) {
+	if kind != "postgres" {
+		slog.Info("Using in-process realtime broker")
+		return NewMemoryBroker(), nil
+	}
+
+	broker, err := NewPostgresBroker(db, dsn)
+	if err != nil {
+		slog.Error("Failed to start postgres realtime broker", "error", err)
+		return nil, err
+	}
+	slog.Info("Using postgres realtime broker")
+	return broker, nil
+}
+
+// PublishToChat sends an event to every member of the chat. Failures are logged, never returned,
+// so realtime delivery cannot break the request that triggered it.
+func PublishToChat(ctx context.Context, broker Broker, chats repository.ChatRepository, chat models.Chat, eventType EventType, data any) {
+	memberIDs, err := chats.MemberIDs(ctx, chat.ID)
+	if err != nil {
+		slog.Error("Failed to resolve chat members for realtime event", "chatId", chat.ExternalID, "error", err)
+		return
+	}
+
+	PublishToUsers(ctx, broker, memberIDs, Event{
+		Type:   eventType,
+		ChatID: chat.ExternalID,
+		Data:   data,
+	})
+}
+
+func PublishToUsers(ctx context.Context, broker Broker, userIDs []uint, event Event) {
+	if len(userIDs) == 0 {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+
+	if err := broker.Publish(context.WithoutCancel(ctx), Envelope{UserIDs: userIDs, Event: event}); err != nil {
+		slog.Error("Failed to publish realtime event", "type", event.Type, "error", err)
+	}
+}
+
+func NewMessagePayload(message models.Message, sender MessageSender) MessagePayload {
+	return MessagePayload{
+		ID:         message.ExternalID,
+		SenderType: message.SenderType,
+		SenderID:   sender.ID,
+		SenderName: sender.Name,
+		Content:    message.Content,
+		CreatedAt:  message.CreatedAt,
+	}
+}
+
+// PublishToChatByID is PublishToChat for callers that only hold the chat's primary key
+func PublishToChatByID(ctx context.Context, broker Broker, chats repository.ChatRepository, chatID uint, eventType EventType, data any) {
+	chat, err := chats.FindByID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to resolve chat for realtime event", "chatId", chatID, "error", err)
+		return
+	}
+	PublishToChat(ctx, broker, chats, chat, eventType, data)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type AgentRepository interface {
+	// FindForMember loads an agent belonging to a chat the user is a member of, with the same errors as ChatRepository.FindForMember
+	FindForMember(ctx context.Context, agentID uuid.UUID, userID uint, required models.ChatRole) (models.Agent, error)
+	// FindByIDs loads agents by id, including deleted ones, for resolving the senders of old messages
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Agent, error)
+	// ListForChat returns the chat's current agents, oldest first
+	ListForChat(ctx context.Context, chatID uint) ([]models.Agent, error)
+	// Create adds an agent to agent.ChatID
+	Create(ctx context.Context, agent *models.Agent) error
+	Update(ctx context.Context, agent *models.Agent) error
+	Delete(ctx context.Context, agent models.Agent) error
+}
+
+type postgresAgentRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresAgentRepository) FindForMember(ctx context.Context, agentID uuid.UUID, userID uint, required models.ChatRole) (models.Agent, error) {
+	db := r.db.WithContext(ctx)
+
+	var agent models.Agent
+	if err := db.Where("agents.chat_id IN (?)", memberChatIDs(db, userID, models.ChatRoleViewer)).
+		First(&agent, "agents.external_id = ?", agentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Agent{}, ErrAgentNotFound
+		}
+		return models.Agent{}, err
+	}
+
+	var member models.ChatMember
+	if err := db.Where("chat_id = ? AND user_id = ?", agent.ChatID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Agent{}, ErrAgentNotFound
+		}
+		return models.Agent{}, err
+	}
+	if !member.Role.Allows(required) {
+		return models.Agent{}, ErrForbidden
+	}
+
+	return agent, nil
+}
+
+func (r *postgresAgentRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Agent, error) {
+	var agents []models.Agent
+	if len(ids) == 0 {
+		return agents, nil
+	}
+	err := r.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&agents).Error
+	return agents, err
+}
+
+func (r *postgresAgentRepository) ListForChat(ctx context.Context, chatID uint) ([]models.Agent, error) {
+	var agents []models.Agent
+	err := r.db.WithContext(ctx).Where("chat_id = ?", chatID).Order("created_at ASC").Find(&agents).Error
+	return agents, err
+}
+
+func (r *postgresAgentRepository) Create(ctx context.Context, agent *models.Agent) error {
+	return r.db.WithContext(ctx).Create(agent).Error
+}
+
+func (r *postgresAgentRepository) Update(ctx context.Context, agent *models.Agent) error {
+	return r.db.WithContext(ctx).Save(agent).Error
+}
+
+func (r *postgresAgentRepository) Delete(ctx context.Context, agent models.Agent) error {
+	return r.db.WithContext(ctx).Delete(&agent).Error
+}
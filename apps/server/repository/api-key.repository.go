@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	// ListForUser returns the user's keys newest first, including expired and revoked ones
+	ListForUser(ctx context.Context, userID uint) ([]models.APIKey, error)
+	// FindByHash loads the key with its owner. A deleted owner leaves key.User zero.
+	FindByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// Revoke revokes the user's key unless it already is, and reports whether it did
+	Revoke(ctx context.Context, keyID uuid.UUID, userID uint, now time.Time) (bool, error)
+	TouchLastUsed(ctx context.Context, keyID uint, now time.Time) error
+}
+
+type postgresAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *postgresAPIKeyRepository) ListForUser(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *postgresAPIKeyRepository) FindByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Preload("User").Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.APIKey{}, ErrAPIKeyNotFound
+		}
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (r *postgresAPIKeyRepository) Revoke(ctx context.Context, keyID uuid.UUID, userID uint, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("external_id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *postgresAPIKeyRepository) TouchLastUsed(ctx context.Context, keyID uint, now time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", keyID).Update("last_used_at", now).Error
+}
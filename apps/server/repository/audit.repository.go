@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+}
+
+type postgresAuditRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
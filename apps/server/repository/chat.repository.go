@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+// ChatPreload picks the associations FindForMember loads along with the chat
+type ChatPreload struct {
+	Agents bool
+	// Oldest first
+	Messages bool
+	// Oldest first, with each member's user
+	Members bool
+}
+
+type ChatRepository interface {
+	// FindForMember loads a chat the user is a member of.
+	// Non-members get ErrChatNotFound so a chat's existence is not revealed; members without the required role get ErrForbidden.
+	FindForMember(ctx context.Context, chatID uuid.UUID, userID uint, required models.ChatRole, preload ChatPreload) (models.Chat, models.ChatRole, error)
+	// FindByID loads a chat by primary key, including deleted ones, without checking membership
+	FindByID(ctx context.Context, id uint) (models.Chat, error)
+	// FindByIDs loads chats by primary key, including deleted ones, for labelling past usage
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Chat, error)
+	// ListForMember returns every chat the user belongs to with at least the required role, oldest first
+	ListForMember(ctx context.Context, userID uint, required models.ChatRole) ([]models.Chat, error)
+	// Role returns the user's role in the chat, or ErrChatNotFound if they are not a member
+	Role(ctx context.Context, chatID uint, userID uint) (models.ChatRole, error)
+	// MemberIDs returns the user ids of every member of the chat
+	MemberIDs(ctx context.Context, chatID uint) ([]uint, error)
+	// MemberNames returns the usernames of every human member of the chat, owner first
+	MemberNames(ctx context.Context, chatID uint) ([]string, error)
+	// FindMember loads the membership of the user with the external id, with the user, or ErrMemberNotFound
+	FindMember(ctx context.Context, chatID uint, userID uuid.UUID) (models.ChatMember, error)
+	UpdateMemberRole(ctx context.Context, member *models.ChatMember, role models.ChatRole) error
+	// RemoveMember hard deletes the membership so the user can be invited again
+	RemoveMember(ctx context.Context, member models.ChatMember) error
+	// CountOwnedBy counts the chats the user owns, including deleted ones
+	CountOwnedBy(ctx context.Context, userID uint) (int64, error)
+	// Create saves a new chat with its agents and makes chat.UserID its owner
+	Create(ctx context.Context, chat *models.Chat, agents []models.Agent) error
+	// Update saves the chat's name and replaces its agents
+	Update(ctx context.Context, chat *models.Chat, agents []models.Agent) error
+	Delete(ctx context.Context, chat models.Chat) error
+	// DeleteOwnedBy deletes every chat the user owns
+	DeleteOwnedBy(ctx context.Context, userID uint) error
+}
+
+type postgresChatRepository struct {
+	db *gorm.DB
+}
+
+// memberChatIDs is a subquery selecting the ids of every chat the user belongs to with at least the required role
+func memberChatIDs(db *gorm.DB, userID uint, required models.ChatRole) *gorm.DB {
+	return db.Model(&models.ChatMember{}).
+		Select("chat_id").
+		Where("user_id = ? AND role IN ?", userID, models.RolesAllowing(required))
+}
+
+func (r *postgresChatRepository) FindForMember(ctx context.Context, chatID uuid.UUID, userID uint, required models.ChatRole, preload ChatPreload) (models.Chat, models.ChatRole, error) {
+	db := r.db.WithContext(ctx)
+
+	query := db
+	if preload.Agents {
+		query = query.Preload("Agents")
+	}
+	if preload.Messages {
+		query = query.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		})
+	}
+	if preload.Members {
+		query = query.Preload("Members", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).Preload("Members.User")
+	}
+
+	var chat models.Chat
+	if err := query.Where("chats.id IN (?)", memberChatIDs(db, userID, models.ChatRoleViewer)).
+		First(&chat, "chats.external_id = ?", chatID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Chat{}, "", ErrChatNotFound
+		}
+		return models.Chat{}, "", err
+	}
+
+	role, err := r.Role(ctx, chat.ID, userID)
+	if err != nil {
+		return models.Chat{}, "", err
+	}
+	if !role.Allows(required) {
+		return models.Chat{}, role, ErrForbidden
+	}
+
+	return chat, role, nil
+}
+
+func (r *postgresChatRepository) FindByID(ctx context.Context, id uint) (models.Chat, error) {
+	var chat models.Chat
+	if err := r.db.WithContext(ctx).Unscoped().First(&chat, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Chat{}, ErrChatNotFound
+		}
+		return models.Chat{}, err
+	}
+	return chat, nil
+}
+
+func (r *postgresChatRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Chat, error) {
+	var chats []models.Chat
+	if len(ids) == 0 {
+		return chats, nil
+	}
+	err := r.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&chats).Error
+	return chats, err
+}
+
+func (r *postgresChatRepository) ListForMember(ctx context.Context, userID uint, required models.ChatRole) ([]models.Chat, error) {
+	db := r.db.WithContext(ctx)
+
+	var chats []models.Chat
+	err := db.Where("id IN (?)", memberChatIDs(db, userID, required)).Order("created_at ASC").Find(&chats).Error
+	return chats, err
+}
+
+func (r *postgresChatRepository) Role(ctx context.Context, chatID uint, userID uint) (models.ChatRole, error) {
+	var member models.ChatMember
+	if err := r.db.WithContext(ctx).Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrChatNotFound
+		}
+		return "", err
+	}
+	return member.Role, nil
+}
+
+func (r *postgresChatRepository) MemberIDs(ctx context.Context, chatID uint) ([]uint, error) {
+	var memberIDs []uint
+	err := r.db.WithContext(ctx).Model(&models.ChatMember{}).Where("chat_id = ?", chatID).Pluck("user_id", &memberIDs).Error
+	return memberIDs, err
+}
+
+func (r *postgresChatRepository) MemberNames(ctx context.Context, chatID uint) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).Model(&models.ChatMember{}).
+		Joins("JOIN users ON users.id = chat_members.user_id").
+		Where("chat_members.chat_id = ?", chatID).
+		Order("CASE chat_members.role WHEN 'owner' THEN 0 ELSE 1 END, chat_members.created_at ASC").
+		Pluck("users.username", &names).Error
+	return names, err
+}
+
+func (r *postgresChatRepository) FindMember(ctx context.Context, chatID uint, userID uuid.UUID) (models.ChatMember, error) {
+	var member models.ChatMember
+	err := r.db.WithContext(ctx).Joins("User").
+		Where("chat_members.chat_id = ? AND \"User\".external_id = ?", chatID, userID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ChatMember{}, ErrMemberNotFound
+	}
+	return member, err
+}
+
+func (r *postgresChatRepository) UpdateMemberRole(ctx context.Context, member *models.ChatMember, role models.ChatRole) error {
+	if err := r.db.WithContext(ctx).Model(member).Update("role", role).Error; err != nil {
+		return err
+	}
+	member.Role = role
+	return nil
+}
+
+func (r *postgresChatRepository) RemoveMember(ctx context.Context, member models.ChatMember) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&member).Error
+}
+
+func (r *postgresChatRepository) CountOwnedBy(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ChatMember{}).
+		Where("user_id = ? AND role = ?", userID, models.ChatRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *postgresChatRepository) Create(ctx context.Context, chat *models.Chat, agents []models.Agent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(chat).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.ChatMember{ChatID: chat.ID, UserID: chat.UserID, Role: models.ChatRoleOwner}).Error; err != nil {
+			return err
+		}
+		return createAgents(tx, chat, agents)
+	})
+}
+
+func (r *postgresChatRepository) Update(ctx context.Context, chat *models.Chat, agents []models.Agent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Agents", "Messages", "Members").Save(chat).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(chat).Association("Agents").Clear(); err != nil {
+			return err
+		}
+		return createAgents(tx, chat, agents)
+	})
+}
+
+func createAgents(tx *gorm.DB, chat *models.Chat, agents []models.Agent) error {
+	for i := range agents {
+		agents[i].ChatID = chat.ID
+		if err := tx.Create(&agents[i]).Error; err != nil {
+			return err
+		}
+	}
+	chat.Agents = agents
+	return nil
+}
+
+func (r *postgresChatRepository) Delete(ctx context.Context, chat models.Chat) error {
+	return r.db.WithContext(ctx).Delete(&chat).Error
+}
+
+func (r *postgresChatRepository) DeleteOwnedBy(ctx context.Context, userID uint) error {
+	db := r.db.WithContext(ctx)
+	return db.Where("id IN (?)", memberChatIDs(db, userID, models.ChatRoleOwner)).Delete(&models.Chat{}).Error
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type IdentityRepository interface {
+	// FindBySubject loads the identity the issuer knows by subject, with its user. A deleted user leaves identity.User zero.
+	FindBySubject(ctx context.Context, issuer string, subject string) (models.UserIdentity, error)
+	FindForUser(ctx context.Context, identityID uuid.UUID, userID uint) (models.UserIdentity, error)
+	// ListForUser returns the user's identities, oldest first
+	ListForUser(ctx context.Context, userID uint) ([]models.UserIdentity, error)
+	CountForUser(ctx context.Context, userID uint) (int64, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	UpdateEmail(ctx context.Context, identity *models.UserIdentity, email string) error
+	// Provision creates the user and links the identity to them in one transaction.
+	// A non-nil stale identity, one whose user was deleted, is removed first.
+	Provision(ctx context.Context, user *models.User, identity *models.UserIdentity, stale *models.UserIdentity) error
+	Delete(ctx context.Context, identity models.UserIdentity) error
+}
+
+type postgresIdentityRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresIdentityRepository) FindBySubject(ctx context.Context, issuer string, subject string) (models.UserIdentity, error) {
+	return r.first(r.db.WithContext(ctx).Preload("User"), "issuer = ? AND subject = ?", issuer, subject)
+}
+
+func (r *postgresIdentityRepository) FindForUser(ctx context.Context, identityID uuid.UUID, userID uint) (models.UserIdentity, error) {
+	return r.first(r.db.WithContext(ctx), "external_id = ? AND user_id = ?", identityID, userID)
+}
+
+func (r *postgresIdentityRepository) first(query *gorm.DB, conditions string, args ...any) (models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := query.Where(conditions, args...).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.UserIdentity{}, ErrIdentityNotFound
+		}
+		return models.UserIdentity{}, err
+	}
+	return identity, nil
+}
+
+func (r *postgresIdentityRepository) ListForUser(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+func (r *postgresIdentityRepository) CountForUser(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *postgresIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Omit("User").Create(identity).Error
+}
+
+func (r *postgresIdentityRepository) UpdateEmail(ctx context.Context, identity *models.UserIdentity, email string) error {
+	if err := r.db.WithContext(ctx).Model(identity).Update("email", email).Error; err != nil {
+		return err
+	}
+	identity.Email = email
+	return nil
+}
+
+func (r *postgresIdentityRepository) Provision(ctx context.Context, user *models.User, identity *models.UserIdentity, stale *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if stale != nil {
+			if err := tx.Unscoped().Delete(stale).Error; err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.ID
+		return tx.Omit("User").Create(identity).Error
+	})
+}
+
+func (r *postgresIdentityRepository) Delete(ctx context.Context, identity models.UserIdentity) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&identity).Error
+}
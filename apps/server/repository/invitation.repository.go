@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	// HasPending reports whether the user already has an unanswered invitation to the chat
+	HasPending(ctx context.Context, chatID uint, inviteeID uint) (bool, error)
+	Create(ctx context.Context, invitation *models.ChatInvitation) error
+	// ListPendingForChat returns the chat's unanswered invitations newest first, with the chat, inviter and invitee
+	ListPendingForChat(ctx context.Context, chatID uint) ([]models.ChatInvitation, error)
+	// ListPendingForInvitee returns the user's unanswered invitations newest first, with the chat, inviter and invitee
+	ListPendingForInvitee(ctx context.Context, userID uint) ([]models.ChatInvitation, error)
+	// Revoke revokes the chat's pending invitation and reports whether there was one
+	Revoke(ctx context.Context, chatID uint, invitationID uuid.UUID) (bool, error)
+	// Answer sets the status of the user's pending invitation, adding them to the chat with the invited
+	// role in the same transaction when it is accepted. Anything but a pending invitation of theirs is ErrInvitationNotFound.
+	Answer(ctx context.Context, invitationID uuid.UUID, inviteeID uint, status models.InvitationStatus) (models.ChatInvitation, error)
+}
+
+type postgresInvitationRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresInvitationRepository) HasPending(ctx context.Context, chatID uint, inviteeID uint) (bool, error) {
+	var pending int64
+	err := r.db.WithContext(ctx).Model(&models.ChatInvitation{}).
+		Where("chat_id = ? AND invitee_id = ? AND status = ?", chatID, inviteeID, models.InvitationStatusPending).
+		Count(&pending).Error
+	return pending > 0, err
+}
+
+func (r *postgresInvitationRepository) Create(ctx context.Context, invitation *models.ChatInvitation) error {
+	return r.db.WithContext(ctx).Omit("Chat", "Inviter", "Invitee").Create(invitation).Error
+}
+
+func (r *postgresInvitationRepository) ListPendingForChat(ctx context.Context, chatID uint) ([]models.ChatInvitation, error) {
+	var invitations []models.ChatInvitation
+	err := r.db.WithContext(ctx).Preload("Chat").Preload("Inviter").Preload("Invitee").
+		Where("chat_id = ? AND status = ?", chatID, models.InvitationStatusPending).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *postgresInvitationRepository) ListPendingForInvitee(ctx context.Context, userID uint) ([]models.ChatInvitation, error) {
+	var invitations []models.ChatInvitation
+	err := r.db.WithContext(ctx).Joins("Chat").Preload("Inviter").Preload("Invitee").
+		Where("chat_invitations.invitee_id = ? AND chat_invitations.status = ?", userID, models.InvitationStatusPending).
+		Order("chat_invitations.created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *postgresInvitationRepository) Revoke(ctx context.Context, chatID uint, invitationID uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.ChatInvitation{}).
+		Where("chat_id = ? AND external_id = ? AND status = ?", chatID, invitationID, models.InvitationStatusPending).
+		Update("status", models.InvitationStatusRevoked)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *postgresInvitationRepository) Answer(ctx context.Context, invitationID uuid.UUID, inviteeID uint, status models.InvitationStatus) (models.ChatInvitation, error) {
+	var invitation models.ChatInvitation
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("external_id = ? AND invitee_id = ? AND status = ?", invitationID, inviteeID, models.InvitationStatusPending).
+			First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&invitation).Update("status", status).Error; err != nil {
+			return err
+		}
+
+		if status == models.InvitationStatusAccepted {
+			return tx.Create(&models.ChatMember{ChatID: invitation.ChatID, UserID: inviteeID, Role: invitation.Role}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ChatInvitation{}, err
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type LoginThrottleRepository interface {
+	Find(ctx context.Context, keys []string) ([]models.LoginThrottle, error)
+	// RecordFailure counts one failed login for the key and returns the updated counter.
+	// The count starts over once an earlier lockout has ended or the last failure is older than windowStart.
+	RecordFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (models.LoginThrottle, error)
+	Lock(ctx context.Context, throttleID uint, until time.Time) error
+	Clear(ctx context.Context, key string) error
+}
+
+type postgresLoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresLoginThrottleRepository) Find(ctx context.Context, keys []string) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	err := r.db.WithContext(ctx).Where("key IN ?", keys).Find(&throttles).Error
+	return throttles, err
+}
+
+func (r *postgresLoginThrottleRepository) RecordFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO login_throttles (key, failures, last_failure_at, created_at, updated_at)
+		VALUES (?, 1, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.locked_until <= ? THEN 1
+				WHEN login_throttles.locked_until IS NULL AND login_throttles.last_failure_at < ? THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			locked_until = CASE
+				WHEN login_throttles.locked_until <= ? THEN NULL
+				ELSE login_throttles.locked_until
+			END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING *
+	`, key, now, now, now, now, windowStart, now).Scan(&throttle).Error
+	return throttle, err
+}
+
+func (r *postgresLoginThrottleRepository) Lock(ctx context.Context, throttleID uint, until time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.LoginThrottle{}).Where("id = ?", throttleID).Update("locked_until", until).Error
+}
+
+func (r *postgresLoginThrottleRepository) Clear(ctx context.Context, key string) error {
+	return r.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
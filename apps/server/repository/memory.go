@@ -0,0 +1,1558 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/types"
+	"gorm.io/gorm"
+)
+
+var errDuplicateKey = errors.New("duplicate key value violates unique constraint")
+
+// memoryStore keeps every table in maps guarded by one lock. Ids increase across all tables,
+// so ordering by id matches the order rows were created in.
+type memoryStore struct {
+	mu       sync.RWMutex
+	lastID   uint
+	users    map[uint]models.User
+	chats    map[uint]models.Chat
+	agents   map[uint]models.Agent
+	members  map[uint]models.ChatMember
+	messages map[uint]models.Message
+
+	invitations map[uint]models.ChatInvitation
+	shares      map[uint]models.ChatShare
+
+	sessions   map[uint]models.Session
+	throttles  map[string]models.LoginThrottle
+	userTokens map[uint]models.UserToken
+	audit      []models.AuditEvent
+
+	recoveryCodes map[uint]models.RecoveryCode
+	apiKeys       map[uint]models.APIKey
+	identities    map[uint]models.UserIdentity
+
+	usage      map[usageKey]models.UsageCounter
+	modelCalls []models.GeminiLogs
+}
+
+type usageKey struct {
+	userID uint
+	day    time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:      map[uint]models.User{},
+		chats:      map[uint]models.Chat{},
+		agents:     map[uint]models.Agent{},
+		members:    map[uint]models.ChatMember{},
+		messages:   map[uint]models.Message{},
+		sessions:   map[uint]models.Session{},
+		throttles:  map[string]models.LoginThrottle{},
+		userTokens: map[uint]models.UserToken{},
+		usage:      map[usageKey]models.UsageCounter{},
+
+		invitations:   map[uint]models.ChatInvitation{},
+		shares:        map[uint]models.ChatShare{},
+		recoveryCodes: map[uint]models.RecoveryCode{},
+		apiKeys:       map[uint]models.APIKey{},
+		identities:    map[uint]models.UserIdentity{},
+	}
+}
+
+// nextID hands out an id for tables without a gorm.Model
+func (s *memoryStore) nextID() uint {
+	s.lastID++
+	return s.lastID
+}
+
+// newModel fills in what Postgres would generate for a new row. Like gorm, it keeps timestamps the caller set.
+func (s *memoryStore) newModel(model *gorm.Model, externalID *uuid.UUID) {
+	now := time.Now()
+	created := gorm.Model{ID: s.nextID(), CreatedAt: model.CreatedAt, UpdatedAt: model.UpdatedAt}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = now
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = now
+	}
+	*model = created
+	if externalID != nil && *externalID == uuid.Nil {
+		*externalID = uuid.New()
+	}
+}
+
+func (s *memoryStore) role(chatID uint, userID uint) (models.ChatRole, bool) {
+	for _, member := range s.members {
+		if member.ChatID == chatID && member.UserID == userID {
+			return member.Role, true
+		}
+	}
+	return "", false
+}
+
+func (s *memoryStore) addMember(chatID uint, userID uint, role models.ChatRole) {
+	member := models.ChatMember{ChatID: chatID, UserID: userID, Role: role}
+	s.newModel(&member.Model, &member.ExternalID)
+	s.members[member.ID] = member
+}
+
+func (s *memoryStore) chatAgents(chatID uint) []models.Agent {
+	var agents []models.Agent
+	for _, agent := range s.agents {
+		if agent.ChatID == chatID && !agent.DeletedAt.Valid {
+			agents = append(agents, cloneAgent(agent))
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return agents
+}
+
+func (s *memoryStore) chatMembers(chatID uint) []models.ChatMember {
+	var members []models.ChatMember
+	for _, member := range s.members {
+		if member.ChatID == chatID {
+			member.User = s.users[member.UserID]
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members
+}
+
+func (s *memoryStore) chatMessages(chatID uint) []models.Message {
+	var messages []models.Message
+	for _, message := range s.messages {
+		if message.ChatID == chatID {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages
+}
+
+// cloneAgent copies the metadata so callers cannot change the stored agent through the pointer
+func cloneAgent(agent models.Agent) models.Agent {
+	if agent.Metadata != nil {
+		metadata := *agent.Metadata
+		metadata.Traits = append([]string(nil), agent.Metadata.Traits...)
+		agent.Metadata = &metadata
+	}
+	return agent
+}
+
+type memoryChatRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryChatRepository) FindForMember(_ context.Context, chatID uuid.UUID, userID uint, required models.ChatRole, preload ChatPreload) (models.Chat, models.ChatRole, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, chat := range s.chats {
+		if chat.ExternalID != chatID || chat.DeletedAt.Valid {
+			continue
+		}
+		role, ok := s.role(chat.ID, userID)
+		if !ok {
+			break
+		}
+		if !role.Allows(required) {
+			return models.Chat{}, role, ErrForbidden
+		}
+
+		if preload.Agents {
+			chat.Agents = s.chatAgents(chat.ID)
+		}
+		if preload.Messages {
+			chat.Messages = s.chatMessages(chat.ID)
+		}
+		if preload.Members {
+			chat.Members = s.chatMembers(chat.ID)
+		}
+		return chat, role, nil
+	}
+	return models.Chat{}, "", ErrChatNotFound
+}
+
+func (r *memoryChatRepository) FindByID(_ context.Context, id uint) (models.Chat, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	chat, ok := r.store.chats[id]
+	if !ok {
+		return models.Chat{}, ErrChatNotFound
+	}
+	return chat, nil
+}
+
+func (r *memoryChatRepository) FindByIDs(_ context.Context, ids []uint) ([]models.Chat, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var chats []models.Chat
+	for _, id := range ids {
+		if chat, ok := r.store.chats[id]; ok {
+			chats = append(chats, chat)
+		}
+	}
+	return chats, nil
+}
+
+func (r *memoryChatRepository) ListForMember(_ context.Context, userID uint, required models.ChatRole) ([]models.Chat, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chats []models.Chat
+	for _, chat := range s.chats {
+		if role, ok := s.role(chat.ID, userID); ok && role.Allows(required) && !chat.DeletedAt.Valid {
+			chats = append(chats, chat)
+		}
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ID < chats[j].ID })
+	return chats, nil
+}
+
+func (r *memoryChatRepository) Role(_ context.Context, chatID uint, userID uint) (models.ChatRole, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	role, ok := r.store.role(chatID, userID)
+	if !ok {
+		return "", ErrChatNotFound
+	}
+	return role, nil
+}
+
+func (r *memoryChatRepository) MemberIDs(_ context.Context, chatID uint) ([]uint, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var memberIDs []uint
+	for _, member := range r.store.chatMembers(chatID) {
+		memberIDs = append(memberIDs, member.UserID)
+	}
+	return memberIDs, nil
+}
+
+func (r *memoryChatRepository) MemberNames(_ context.Context, chatID uint) ([]string, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := s.chatMembers(chatID)
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Role == models.ChatRoleOwner && members[j].Role != models.ChatRoleOwner
+	})
+
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.User.Username)
+	}
+	return names, nil
+}
+
+func (r *memoryChatRepository) FindMember(_ context.Context, chatID uint, userID uuid.UUID) (models.ChatMember, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, member := range r.store.chatMembers(chatID) {
+		if member.User.ExternalID == userID {
+			return member, nil
+		}
+	}
+	return models.ChatMember{}, ErrMemberNotFound
+}
+
+func (r *memoryChatRepository) UpdateMemberRole(_ context.Context, member *models.ChatMember, role models.ChatRole) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.members[member.ID]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	stored.Role = role
+	stored.UpdatedAt = time.Now()
+	r.store.members[member.ID] = stored
+	member.Role = role
+	return nil
+}
+
+func (r *memoryChatRepository) RemoveMember(_ context.Context, member models.ChatMember) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.members, member.ID)
+	return nil
+}
+
+func (r *memoryChatRepository) CountOwnedBy(_ context.Context, userID uint) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, member := range r.store.members {
+		if member.UserID == userID && member.Role == models.ChatRoleOwner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryChatRepository) Create(_ context.Context, chat *models.Chat, agents []models.Agent) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.newModel(&chat.Model, &chat.ExternalID)
+	if chat.Type == "" {
+		chat.Type = models.ChatTypeDefault
+	}
+	s.chats[chat.ID] = *chat
+	s.addMember(chat.ID, chat.UserID, models.ChatRoleOwner)
+	r.createAgents(chat, agents)
+	return nil
+}
+
+func (r *memoryChatRepository) Update(_ context.Context, chat *models.Chat, agents []models.Agent) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.chats[chat.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrChatNotFound
+	}
+	stored.ChatName = chat.ChatName
+	stored.UpdatedAt = time.Now()
+	s.chats[chat.ID] = stored
+
+	// Like clearing the association in Postgres, the old agents are detached rather than deleted
+	for id, agent := range s.agents {
+		if agent.ChatID == chat.ID {
+			agent.ChatID = 0
+			s.agents[id] = agent
+		}
+	}
+	r.createAgents(chat, agents)
+	return nil
+}
+
+func (r *memoryChatRepository) createAgents(chat *models.Chat, agents []models.Agent) {
+	for i := range agents {
+		agents[i].ChatID = chat.ID
+		r.store.newModel(&agents[i].Model, &agents[i].ExternalID)
+		r.store.agents[agents[i].ID] = cloneAgent(agents[i])
+	}
+	chat.Agents = agents
+}
+
+func (r *memoryChatRepository) Delete(_ context.Context, chat models.Chat) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.deleteChat(chat.ID)
+	return nil
+}
+
+func (r *memoryChatRepository) DeleteOwnedBy(_ context.Context, userID uint) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.chats {
+		if role, ok := s.role(id, userID); ok && role == models.ChatRoleOwner {
+			s.deleteChat(id)
+		}
+	}
+	return nil
+}
+
+// deleteChat soft deletes the chat like gorm does, leaving its members, agents and messages in place
+func (s *memoryStore) deleteChat(chatID uint) {
+	chat, ok := s.chats[chatID]
+	if !ok || chat.DeletedAt.Valid {
+		return
+	}
+	chat.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.chats[chatID] = chat
+}
+
+type memoryAgentRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAgentRepository) FindForMember(_ context.Context, agentID uuid.UUID, userID uint, required models.ChatRole) (models.Agent, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, agent := range s.agents {
+		if agent.ExternalID != agentID || agent.DeletedAt.Valid {
+			continue
+		}
+		role, ok := s.role(agent.ChatID, userID)
+		if !ok {
+			break
+		}
+		if !role.Allows(required) {
+			return models.Agent{}, ErrForbidden
+		}
+		return cloneAgent(agent), nil
+	}
+	return models.Agent{}, ErrAgentNotFound
+}
+
+func (r *memoryAgentRepository) FindByIDs(_ context.Context, ids []uint) ([]models.Agent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var agents []models.Agent
+	for _, id := range ids {
+		if agent, ok := r.store.agents[id]; ok {
+			agents = append(agents, cloneAgent(agent))
+		}
+	}
+	return agents, nil
+}
+
+func (r *memoryAgentRepository) ListForChat(_ context.Context, chatID uint) ([]models.Agent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.chatAgents(chatID), nil
+}
+
+func (r *memoryAgentRepository) Create(_ context.Context, agent *models.Agent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&agent.Model, &agent.ExternalID)
+	r.store.agents[agent.ID] = cloneAgent(*agent)
+	return nil
+}
+
+func (r *memoryAgentRepository) Update(_ context.Context, agent *models.Agent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.agents[agent.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrAgentNotFound
+	}
+	agent.UpdatedAt = time.Now()
+	r.store.agents[agent.ID] = cloneAgent(*agent)
+	return nil
+}
+
+func (r *memoryAgentRepository) Delete(_ context.Context, agent models.Agent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.agents[agent.ID]
+	if !ok {
+		return nil
+	}
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.store.agents[agent.ID] = stored
+	return nil
+}
+
+type memoryMessageRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryMessageRepository) Create(_ context.Context, message *models.Message) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&message.Model, &message.ExternalID)
+	r.store.messages[message.ID] = *message
+	return nil
+}
+
+func (r *memoryMessageRepository) CreateMany(ctx context.Context, messages []models.Message) error {
+	for i := range messages {
+		if err := r.Create(ctx, &messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryMessageRepository) History(_ context.Context, chatID uint, maxTokens int) ([]models.Message, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := s.chatMessages(chatID)
+	for i, message := range messages {
+		if message.SenderType == string(types.SenderTypeAgent) {
+			messages[i].SenderName = s.agents[message.SenderID].Name
+		} else {
+			messages[i].SenderName = s.users[message.SenderID].Username
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryMessageRepository) ListForChat(_ context.Context, chatID uint, until time.Time) ([]models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var messages []models.Message
+	for _, message := range r.store.chatMessages(chatID) {
+		if until.IsZero() || !message.CreatedAt.After(until) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *memoryMessageRepository) CountSentBy(_ context.Context, userID uint, since time.Time) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, message := range r.store.messages {
+		if message.SenderType == string(types.SenderTypeUser) && message.SenderID == userID && !message.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryUserRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUserRepository) FindByID(_ context.Context, id uint) (models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) FindByExternalID(_ context.Context, id uuid.UUID) (models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.ExternalID == id && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+func (r *memoryUserRepository) FindByUsername(_ context.Context, username string) (models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Username == username && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+func (r *memoryUserRepository) FindByIDs(_ context.Context, ids []uint) ([]models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var users []models.User
+	for _, id := range ids {
+		if user, ok := r.store.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(_ context.Context, email string) (models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Email != nil && *user.Email == email && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+func (r *memoryUserRepository) List(_ context.Context, search string, offset int, limit int) ([]models.User, int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	search = strings.ToLower(search)
+	var matches []models.User
+	for _, user := range r.store.users {
+		if user.DeletedAt.Valid {
+			continue
+		}
+		if search == "" || strings.Contains(strings.ToLower(user.Username), search) ||
+			strings.Contains(strings.ToLower(user.FullName), search) ||
+			(user.Email != nil && strings.Contains(*user.Email, search)) {
+			matches = append(matches, user)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID > matches[j].ID })
+
+	total := int64(len(matches))
+	if offset >= len(matches) {
+		return []models.User{}, total, nil
+	}
+	matches = matches[offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
+
+func (r *memoryUserRepository) EmailTaken(_ context.Context, email string, exceptUserID uint) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Email != nil && *user.Email == email && user.ID != exceptUserID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) UsernameTaken(_ context.Context, username string) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) Create(_ context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.users {
+		if existing.Username == user.Username || (user.Email != nil && existing.Email != nil && *existing.Email == *user.Email) {
+			return errDuplicateKey
+		}
+	}
+
+	r.store.newModel(&user.Model, &user.ExternalID)
+	if user.Role == "" {
+		user.Role = models.UserRoleUser
+	}
+	r.store.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) Update(_ context.Context, user *models.User, columns ...string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.updateUser(*user, columns)
+}
+
+// updateUser copies the named columns from user onto the stored row, like updating with a column list in Postgres
+func (s *memoryStore) updateUser(user models.User, columns []string) error {
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	for _, column := range columns {
+		switch column {
+		case "email":
+			stored.Email = user.Email
+		case "email_verified_at":
+			stored.EmailVerifiedAt = user.EmailVerifiedAt
+		case "password_hash":
+			stored.PasswordHash = user.PasswordHash
+		case "role":
+			stored.Role = user.Role
+		case "plan":
+			stored.Plan = user.Plan
+		case "suspended_at":
+			stored.SuspendedAt = user.SuspendedAt
+		case "totp_secret":
+			stored.TOTPSecret = user.TOTPSecret
+		case "totp_last_step":
+			stored.TOTPLastStep = user.TOTPLastStep
+		case "two_factor_enabled_at":
+			stored.TwoFactorEnabledAt = user.TwoFactorEnabledAt
+		default:
+			return fmt.Errorf("the memory store cannot update user column %q", column)
+		}
+	}
+	stored.UpdatedAt = time.Now()
+	s.users[user.ID] = stored
+	return nil
+}
+
+type memorySessionRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySessionRepository) Create(_ context.Context, session *models.Session) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&session.Model, &session.ExternalID)
+	r.store.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) FindByExternalID(_ context.Context, sessionID uuid.UUID) (models.Session, error) {
+	return r.find(func(session models.Session) bool { return session.ExternalID == sessionID })
+}
+
+func (r *memorySessionRepository) FindForUser(_ context.Context, sessionID uuid.UUID, userID uint) (models.Session, error) {
+	return r.find(func(session models.Session) bool {
+		return session.ExternalID == sessionID && session.UserID == userID
+	})
+}
+
+func (r *memorySessionRepository) FindByRefreshTokenHash(_ context.Context, hash string) (models.Session, error) {
+	return r.find(func(session models.Session) bool { return session.RefreshTokenHash == hash })
+}
+
+func (r *memorySessionRepository) FindByPreviousTokenHash(_ context.Context, hash string) (models.Session, error) {
+	return r.find(func(session models.Session) bool { return session.PreviousTokenHash == hash })
+}
+
+// find returns the first session matching, with its user attached like the Postgres preload
+func (r *memorySessionRepository) find(match func(session models.Session) bool) (models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, session := range r.store.sessions {
+		if match(session) {
+			if user, ok := r.store.users[session.UserID]; ok && !user.DeletedAt.Valid {
+				session.User = user
+			}
+			return session, nil
+		}
+	}
+	return models.Session{}, ErrSessionNotFound
+}
+
+func (r *memorySessionRepository) ListActive(_ context.Context, userID uint, now time.Time) ([]models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range r.store.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Rotate(_ context.Context, sessionID uint, currentHash string, rotation SessionRotation) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.sessions[sessionID]
+	if !ok || session.RefreshTokenHash != currentHash {
+		return false, nil
+	}
+	session.PreviousTokenHash = currentHash
+	session.RefreshTokenHash = rotation.RefreshTokenHash
+	session.LastUsedAt = rotation.LastUsedAt
+	session.ExpiresAt = rotation.ExpiresAt
+	session.UserAgent = rotation.UserAgent
+	session.IPAddress = rotation.IPAddress
+	session.UpdatedAt = time.Now()
+	r.store.sessions[sessionID] = session
+	return true, nil
+}
+
+func (r *memorySessionRepository) Revoke(_ context.Context, sessionID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if session, ok := r.store.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now().UTC()
+		session.RevokedAt = &now
+		r.store.sessions[sessionID] = session
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAll(_ context.Context, userID uint, exceptSessionID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, session := range r.store.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && id != exceptSessionID {
+			session.RevokedAt = &now
+			r.store.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+type memoryLoginThrottleRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryLoginThrottleRepository) Find(_ context.Context, keys []string) ([]models.LoginThrottle, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var throttles []models.LoginThrottle
+	for _, key := range keys {
+		if throttle, ok := r.store.throttles[key]; ok {
+			throttles = append(throttles, throttle)
+		}
+	}
+	return throttles, nil
+}
+
+func (r *memoryLoginThrottleRepository) RecordFailure(_ context.Context, key string, now time.Time, windowStart time.Time) (models.LoginThrottle, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	throttle, ok := r.store.throttles[key]
+	switch {
+	case !ok:
+		throttle = models.LoginThrottle{ID: r.store.nextID(), Key: key, Failures: 1, CreatedAt: now}
+	case throttle.LockedUntil != nil && !throttle.LockedUntil.After(now):
+		throttle.Failures = 1
+		throttle.LockedUntil = nil
+	case throttle.LockedUntil == nil && throttle.LastFailureAt.Before(windowStart):
+		throttle.Failures = 1
+	default:
+		throttle.Failures++
+	}
+	throttle.LastFailureAt = now
+	throttle.UpdatedAt = now
+	r.store.throttles[key] = throttle
+	return throttle, nil
+}
+
+func (r *memoryLoginThrottleRepository) Lock(_ context.Context, throttleID uint, until time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for key, throttle := range r.store.throttles {
+		if throttle.ID == throttleID {
+			throttle.LockedUntil = &until
+			r.store.throttles[key] = throttle
+		}
+	}
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) Clear(_ context.Context, key string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.throttles, key)
+	return nil
+}
+
+type memoryUserTokenRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUserTokenRepository) Issue(_ context.Context, token *models.UserToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, existing := range r.store.userTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose && existing.UsedAt == nil {
+			existing.UsedAt = &now
+			r.store.userTokens[id] = existing
+		}
+	}
+
+	r.store.newModel(&token.Model, nil)
+	r.store.userTokens[token.ID] = *token
+	return nil
+}
+
+func (r *memoryUserTokenRepository) Consume(_ context.Context, hash string, purpose models.UserTokenPurpose, now time.Time, apply UserTokenApply) (models.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.userTokens {
+		if token.TokenHash != hash || token.Purpose != purpose {
+			continue
+		}
+		user, ok := s.users[token.UserID]
+		if !token.IsActive(now) || !ok || user.DeletedAt.Valid {
+			break
+		}
+
+		token.User = user
+		columns, err := apply(token, &user)
+		if err != nil {
+			return models.User{}, err
+		}
+		if err := s.updateUser(user, columns); err != nil {
+			return models.User{}, err
+		}
+
+		token.UsedAt = &now
+		token.User = models.User{}
+		s.userTokens[id] = token
+		return user, nil
+	}
+	return models.User{}, ErrUserTokenNotFound
+}
+
+type memoryAuditRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAuditRepository) Create(_ context.Context, event *models.AuditEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&event.Model, &event.ExternalID)
+	r.store.audit = append(r.store.audit, *event)
+	return nil
+}
+
+type memoryUsageRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUsageRepository) Counters(_ context.Context, userID uint, since time.Time) ([]models.UsageCounter, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var counters []models.UsageCounter
+	for key, counter := range r.store.usage {
+		if key.userID == userID && !key.day.Before(since) {
+			counters = append(counters, counter)
+		}
+	}
+	return counters, nil
+}
+
+func (r *memoryUsageRepository) Record(_ context.Context, userID uint, day time.Time, tokens int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now().UTC()
+	key := usageKey{userID: userID, day: day}
+	counter, ok := r.store.usage[key]
+	if !ok {
+		counter = models.UsageCounter{ID: r.store.nextID(), UserID: userID, Day: day, CreatedAt: now}
+	}
+	counter.Requests++
+	counter.Tokens += tokens
+	counter.UpdatedAt = now
+	r.store.usage[key] = counter
+	return nil
+}
+
+func (r *memoryUsageRepository) LogModelCall(_ context.Context, entry *models.GeminiLogs) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&entry.Model, &entry.ExternalID)
+	r.store.modelCalls = append(r.store.modelCalls, *entry)
+	return nil
+}
+
+func (r *memoryUsageRepository) Totals(_ context.Context, since time.Time, userID uint) (UsageTotals, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var totals UsageTotals
+	for _, call := range r.store.modelCallsSince(since) {
+		if userID == 0 || (call.UserID != nil && *call.UserID == userID) {
+			totals.add(call)
+		}
+	}
+	return totals, nil
+}
+
+func (r *memoryUsageRepository) TopUsers(_ context.Context, since time.Time, limit int) ([]UserUsageTotals, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	byUser := map[uint]*UserUsageTotals{}
+	for _, call := range r.store.modelCallsSince(since) {
+		if call.UserID == nil {
+			continue
+		}
+		row, ok := byUser[*call.UserID]
+		if !ok {
+			row = &UserUsageTotals{UserID: *call.UserID}
+			byUser[*call.UserID] = row
+		}
+		row.add(call)
+	}
+
+	rows := make([]UserUsageTotals, 0, len(byUser))
+	for _, row := range byUser {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].TotalTokens > rows[j].TotalTokens })
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (r *memoryUsageRepository) Daily(_ context.Context, userID uint, since time.Time) ([]DailyUsageTotals, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	type dailyKey struct {
+		day     time.Time
+		chatID  uint
+		agentID uint
+	}
+	byKey := map[dailyKey]*DailyUsageTotals{}
+	latency := map[dailyKey]int64{}
+	for _, call := range r.store.modelCallsSince(since) {
+		if call.UserID == nil || *call.UserID != userID {
+			continue
+		}
+		created := call.CreatedAt.UTC()
+		key := dailyKey{
+			day:     time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC),
+			chatID:  derefID(call.ChatID),
+			agentID: derefID(call.AgentID),
+		}
+		row, ok := byKey[key]
+		if !ok {
+			row = &DailyUsageTotals{Day: key.day, ChatID: call.ChatID, AgentID: call.AgentID}
+			byKey[key] = row
+		}
+		row.add(call)
+		if call.Error != "" {
+			row.Errors++
+		}
+		latency[key] += call.LatencyMs
+	}
+
+	rows := make([]DailyUsageTotals, 0, len(byKey))
+	for key, row := range byKey {
+		row.AvgLatencyMs = float64(latency[key]) / float64(row.Requests)
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].Day.Equal(rows[j].Day) {
+			return rows[i].Day.After(rows[j].Day)
+		}
+		if a, b := derefID(rows[i].ChatID), derefID(rows[j].ChatID); a != b {
+			return a < b
+		}
+		return derefID(rows[i].AgentID) < derefID(rows[j].AgentID)
+	})
+	return rows, nil
+}
+
+func (s *memoryStore) modelCallsSince(since time.Time) []models.GeminiLogs {
+	var calls []models.GeminiLogs
+	for _, call := range s.modelCalls {
+		if !call.CreatedAt.Before(since) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (t *UsageTotals) add(call models.GeminiLogs) {
+	t.Requests++
+	t.InputTokens += int64(call.InputTokens)
+	t.OutputTokens += int64(call.OutputTokens)
+	t.TotalTokens += int64(call.TotalTokens)
+	t.EstimatedCost += call.EstimatedCost
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+type memoryInvitationRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryInvitationRepository) HasPending(_ context.Context, chatID uint, inviteeID uint) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, invitation := range r.store.invitations {
+		if invitation.ChatID == chatID && invitation.InviteeID == inviteeID && invitation.Status == models.InvitationStatusPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryInvitationRepository) Create(_ context.Context, invitation *models.ChatInvitation) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&invitation.Model, &invitation.ExternalID)
+	if invitation.Status == "" {
+		invitation.Status = models.InvitationStatusPending
+	}
+	stored := *invitation
+	stored.Chat, stored.Inviter, stored.Invitee = models.Chat{}, models.User{}, models.User{}
+	r.store.invitations[invitation.ID] = stored
+	return nil
+}
+
+func (r *memoryInvitationRepository) ListPendingForChat(_ context.Context, chatID uint) ([]models.ChatInvitation, error) {
+	return r.listPending(func(invitation models.ChatInvitation) bool { return invitation.ChatID == chatID }), nil
+}
+
+func (r *memoryInvitationRepository) ListPendingForInvitee(_ context.Context, userID uint) ([]models.ChatInvitation, error) {
+	return r.listPending(func(invitation models.ChatInvitation) bool { return invitation.InviteeID == userID }), nil
+}
+
+// listPending returns matching pending invitations of chats that still exist, newest first
+func (r *memoryInvitationRepository) listPending(match func(invitation models.ChatInvitation) bool) []models.ChatInvitation {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var invitations []models.ChatInvitation
+	for _, invitation := range r.store.invitations {
+		chat, ok := r.store.chats[invitation.ChatID]
+		if invitation.Status != models.InvitationStatusPending || !match(invitation) || !ok || chat.DeletedAt.Valid {
+			continue
+		}
+		invitation.Chat = chat
+		invitation.Inviter = r.store.users[invitation.InviterID]
+		invitation.Invitee = r.store.users[invitation.InviteeID]
+		invitations = append(invitations, invitation)
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID > invitations[j].ID })
+	return invitations
+}
+
+func (r *memoryInvitationRepository) Revoke(_ context.Context, chatID uint, invitationID uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, invitation := range r.store.invitations {
+		if invitation.ChatID == chatID && invitation.ExternalID == invitationID && invitation.Status == models.InvitationStatusPending {
+			invitation.Status = models.InvitationStatusRevoked
+			invitation.UpdatedAt = time.Now()
+			r.store.invitations[id] = invitation
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryInvitationRepository) Answer(_ context.Context, invitationID uuid.UUID, inviteeID uint, status models.InvitationStatus) (models.ChatInvitation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, invitation := range r.store.invitations {
+		if invitation.ExternalID != invitationID || invitation.InviteeID != inviteeID || invitation.Status != models.InvitationStatusPending {
+			continue
+		}
+
+		invitation.Status = status
+		invitation.UpdatedAt = time.Now()
+		r.store.invitations[id] = invitation
+		if status == models.InvitationStatusAccepted {
+			r.store.addMember(invitation.ChatID, inviteeID, invitation.Role)
+		}
+		return invitation, nil
+	}
+	return models.ChatInvitation{}, ErrInvitationNotFound
+}
+
+type memoryShareRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryShareRepository) Create(_ context.Context, share *models.ChatShare) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.shares {
+		if existing.TokenHash == share.TokenHash {
+			return errDuplicateKey
+		}
+	}
+
+	r.store.newModel(&share.Model, &share.ExternalID)
+	stored := *share
+	stored.Chat = models.Chat{}
+	r.store.shares[share.ID] = stored
+	return nil
+}
+
+func (r *memoryShareRepository) ListForChat(_ context.Context, chatID uint) ([]models.ChatShare, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var shares []models.ChatShare
+	for _, share := range r.store.shares {
+		if share.ChatID == chatID {
+			shares = append(shares, share)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].ID > shares[j].ID })
+	return shares, nil
+}
+
+func (r *memoryShareRepository) FindForChat(_ context.Context, chatID uint, shareID uuid.UUID) (models.ChatShare, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, share := range r.store.shares {
+		if share.ChatID == chatID && share.ExternalID == shareID {
+			return share, nil
+		}
+	}
+	return models.ChatShare{}, ErrShareNotFound
+}
+
+func (r *memoryShareRepository) FindByTokenHash(_ context.Context, hash string) (models.ChatShare, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, share := range r.store.shares {
+		if share.TokenHash != hash {
+			continue
+		}
+		if chat, ok := r.store.chats[share.ChatID]; ok && !chat.DeletedAt.Valid {
+			share.Chat = chat
+		}
+		return share, nil
+	}
+	return models.ChatShare{}, ErrShareNotFound
+}
+
+func (r *memoryShareRepository) Revoke(_ context.Context, share *models.ChatShare, now time.Time) error {
+	if share.RevokedAt != nil {
+		return nil
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.shares[share.ID]
+	if !ok {
+		return ErrShareNotFound
+	}
+	stored.RevokedAt = &now
+	stored.UpdatedAt = now
+	r.store.shares[share.ID] = stored
+	share.RevokedAt = &now
+	return nil
+}
+
+type memoryTwoFactorRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTwoFactorRepository) BeginEnrollment(_ context.Context, userID uint, secret string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.updateUser(models.User{Model: gorm.Model{ID: userID}, TOTPSecret: secret}, []string{"totp_secret", "totp_last_step"})
+}
+
+func (r *memoryTwoFactorRepository) Enable(_ context.Context, userID uint, enabledAt time.Time, codeHashes []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.updateUser(models.User{Model: gorm.Model{ID: userID}, TwoFactorEnabledAt: &enabledAt}, []string{"two_factor_enabled_at"}); err != nil {
+		return err
+	}
+	r.store.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+func (r *memoryTwoFactorRepository) Disable(_ context.Context, userID uint) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.replaceRecoveryCodes(userID, nil)
+	return r.store.updateUser(models.User{Model: gorm.Model{ID: userID}}, []string{"totp_secret", "totp_last_step", "two_factor_enabled_at"})
+}
+
+func (r *memoryTwoFactorRepository) AdvanceStep(_ context.Context, userID uint, step int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	return true, r.store.updateUser(user, []string{"totp_last_step"})
+}
+
+func (r *memoryTwoFactorRepository) ReplaceRecoveryCodes(_ context.Context, userID uint, codeHashes []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+func (s *memoryStore) replaceRecoveryCodes(userID uint, codeHashes []string) {
+	for id, code := range s.recoveryCodes {
+		if code.UserID == userID {
+			delete(s.recoveryCodes, id)
+		}
+	}
+	for _, hash := range codeHashes {
+		code := models.RecoveryCode{UserID: userID, CodeHash: hash}
+		s.newModel(&code.Model, nil)
+		s.recoveryCodes[code.ID] = code
+	}
+}
+
+func (r *memoryTwoFactorRepository) UseRecoveryCode(_ context.Context, userID uint, codeHash string, now time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, code := range r.store.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			code.UsedAt = &now
+			r.store.recoveryCodes[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryTwoFactorRepository) CountRecoveryCodes(_ context.Context, userID uint) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, code := range r.store.recoveryCodes {
+		if code.UserID == userID && code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryAPIKeyRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAPIKeyRepository) Create(_ context.Context, key *models.APIKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.newModel(&key.Model, &key.ExternalID)
+	stored := *key
+	stored.User = models.User{}
+	r.store.apiKeys[key.ID] = stored
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) ListForUser(_ context.Context, userID uint) ([]models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range r.store.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(_ context.Context, hash string) (models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, key := range r.store.apiKeys {
+		if key.KeyHash != hash {
+			continue
+		}
+		if user, ok := r.store.users[key.UserID]; ok && !user.DeletedAt.Valid {
+			key.User = user
+		}
+		return key, nil
+	}
+	return models.APIKey{}, ErrAPIKeyNotFound
+}
+
+func (r *memoryAPIKeyRepository) Revoke(_ context.Context, keyID uuid.UUID, userID uint, now time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, key := range r.store.apiKeys {
+		if key.ExternalID == keyID && key.UserID == userID && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			r.store.apiKeys[id] = key
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryAPIKeyRepository) TouchLastUsed(_ context.Context, keyID uint, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if key, ok := r.store.apiKeys[keyID]; ok {
+		key.LastUsedAt = &now
+		r.store.apiKeys[keyID] = key
+	}
+	return nil
+}
+
+type memoryIdentityRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryIdentityRepository) FindBySubject(_ context.Context, issuer string, subject string) (models.UserIdentity, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, identity := range r.store.identities {
+		if identity.Issuer != issuer || identity.Subject != subject {
+			continue
+		}
+		if user, ok := r.store.users[identity.UserID]; ok && !user.DeletedAt.Valid {
+			identity.User = user
+		}
+		return identity, nil
+	}
+	return models.UserIdentity{}, ErrIdentityNotFound
+}
+
+func (r *memoryIdentityRepository) FindForUser(_ context.Context, identityID uuid.UUID, userID uint) (models.UserIdentity, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, identity := range r.store.identities {
+		if identity.ExternalID == identityID && identity.UserID == userID {
+			return identity, nil
+		}
+	}
+	return models.UserIdentity{}, ErrIdentityNotFound
+}
+
+func (r *memoryIdentityRepository) ListForUser(_ context.Context, userID uint) ([]models.UserIdentity, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.userIdentities(userID), nil
+}
+
+func (r *memoryIdentityRepository) CountForUser(_ context.Context, userID uint) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return int64(len(r.store.userIdentities(userID))), nil
+}
+
+func (s *memoryStore) userIdentities(userID uint) []models.UserIdentity {
+	var identities []models.UserIdentity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities
+}
+
+func (r *memoryIdentityRepository) Create(_ context.Context, identity *models.UserIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.createIdentity(identity)
+}
+
+func (s *memoryStore) createIdentity(identity *models.UserIdentity) error {
+	for _, existing := range s.identities {
+		if existing.Issuer == identity.Issuer && existing.Subject == identity.Subject {
+			return errDuplicateKey
+		}
+	}
+
+	s.newModel(&identity.Model, &identity.ExternalID)
+	stored := *identity
+	stored.User = models.User{}
+	s.identities[identity.ID] = stored
+	return nil
+}
+
+func (r *memoryIdentityRepository) UpdateEmail(_ context.Context, identity *models.UserIdentity, email string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.identities[identity.ID]
+	if !ok {
+		return ErrIdentityNotFound
+	}
+	stored.Email = email
+	stored.UpdatedAt = time.Now()
+	r.store.identities[identity.ID] = stored
+	identity.Email = email
+	return nil
+}
+
+func (r *memoryIdentityRepository) Provision(ctx context.Context, user *models.User, identity *models.UserIdentity, stale *models.UserIdentity) error {
+	if err := (&memoryUserRepository{r.store}).Create(ctx, user); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if stale != nil {
+		delete(r.store.identities, stale.ID)
+	}
+	identity.UserID = user.ID
+	if err := r.store.createIdentity(identity); err != nil {
+		// Undo the user so a failed provision leaves nothing behind, like the Postgres transaction
+		delete(r.store.users, user.ID)
+		return err
+	}
+	return nil
+}
+
+func (r *memoryIdentityRepository) Delete(_ context.Context, identity models.UserIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.identities, identity.ID)
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/types"
+	"gorm.io/gorm"
+)
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *models.Message) error
+	// CreateMany saves the messages in one statement, filling in their ids
+	CreateMany(ctx context.Context, messages []models.Message) error
+	// History returns the chat's messages oldest first, with SenderName resolved
+	History(ctx context.Context, chatID uint, maxTokens int) ([]models.Message, error)
+	// ListForChat returns the chat's messages created up to until, oldest first. A zero until includes every message.
+	ListForChat(ctx context.Context, chatID uint, until time.Time) ([]models.Message, error)
+	// CountSentBy counts the messages the user has sent since the given time
+	CountSentBy(ctx context.Context, userID uint, since time.Time) (int64, error)
+}
+
+type postgresMessageRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+func (r *postgresMessageRepository) CreateMany(ctx context.Context, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&messages).Error
+}
+
+func (r *postgresMessageRepository) History(ctx context.Context, chatID uint, maxTokens int) ([]models.Message, error) {
+	db := r.db.WithContext(ctx)
+
+	var messages []models.Message
+	if err := db.Where("chat_id = ?", chatID).Order("created_at ASC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	if err := attachSenderNames(db, messages); err != nil {
+		return nil, err
+	}
+
+	// TODO: Implement token counting and truncation logic here
+	// For now, we'll just return all messages
+	return messages, nil
+}
+
+func (r *postgresMessageRepository) ListForChat(ctx context.Context, chatID uint, until time.Time) ([]models.Message, error) {
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID)
+	if !until.IsZero() {
+		query = query.Where("created_at <= ?", until)
+	}
+
+	var messages []models.Message
+	err := query.Order("created_at ASC").Find(&messages).Error
+	return messages, err
+}
+
+func (r *postgresMessageRepository) CountSentBy(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("sender_type = ? AND sender_id = ? AND created_at >= ?", types.SenderTypeUser, userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// attachSenderNames resolves who sent each message so multiple humans and agents can be told apart
+func attachSenderNames(db *gorm.DB, messages []models.Message) error {
+	var userIDs, agentIDs []uint
+	for _, msg := range messages {
+		if msg.SenderType == string(types.SenderTypeAgent) {
+			agentIDs = append(agentIDs, msg.SenderID)
+		} else {
+			userIDs = append(userIDs, msg.SenderID)
+		}
+	}
+
+	userNames := make(map[uint]string)
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := db.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			userNames[user.ID] = user.Username
+		}
+	}
+
+	agentNames := make(map[uint]string)
+	if len(agentIDs) > 0 {
+		var agents []models.Agent
+		if err := db.Unscoped().Where("id IN ?", agentIDs).Find(&agents).Error; err != nil {
+			return err
+		}
+		for _, agent := range agents {
+			agentNames[agent.ID] = agent.Name
+		}
+	}
+
+	for i, msg := range messages {
+		if msg.SenderType == string(types.SenderTypeAgent) {
+			messages[i].SenderName = agentNames[msg.SenderID]
+		} else {
+			messages[i].SenderName = userNames[msg.SenderID]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChatNotFound       = errors.New("chat not found")
+	ErrAgentNotFound      = errors.New("agent not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrShareNotFound      = errors.New("share link not found")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrIdentityNotFound   = errors.New("identity not found")
+	// ErrUserTokenNotFound covers unknown, used and expired tokens alike
+	ErrUserTokenNotFound = errors.New("user token not found")
+	ErrForbidden         = errors.New("your role in this chat does not allow this action")
+)
+
+// Repositories groups the stores handlers read chats, agents, messages, users and their
+// sessions, credentials and usage through. Ownership and role checks live here, so handlers
+// never build their own membership queries.
+type Repositories struct {
+	Users          UserRepository
+	Chats          ChatRepository
+	Agents         AgentRepository
+	Messages       MessageRepository
+	Invitations    InvitationRepository
+	Shares         ShareRepository
+	Sessions       SessionRepository
+	LoginThrottles LoginThrottleRepository
+	UserTokens     UserTokenRepository
+	TwoFactor      TwoFactorRepository
+	APIKeys        APIKeyRepository
+	Identities     IdentityRepository
+	Audit          AuditRepository
+	Usage          UsageRepository
+}
+
+// NewPostgres returns repositories backed by the database
+func NewPostgres(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:          &postgresUserRepository{db: db},
+		Chats:          &postgresChatRepository{db: db},
+		Agents:         &postgresAgentRepository{db: db},
+		Messages:       &postgresMessageRepository{db: db},
+		Invitations:    &postgresInvitationRepository{db: db},
+		Shares:         &postgresShareRepository{db: db},
+		Sessions:       &postgresSessionRepository{db: db},
+		LoginThrottles: &postgresLoginThrottleRepository{db: db},
+		UserTokens:     &postgresUserTokenRepository{db: db},
+		TwoFactor:      &postgresTwoFactorRepository{db: db},
+		APIKeys:        &postgresAPIKeyRepository{db: db},
+		Identities:     &postgresIdentityRepository{db: db},
+		Audit:          &postgresAuditRepository{db: db},
+		Usage:          &postgresUsageRepository{db: db},
+	}
+}
+
+// NewMemory returns repositories that share one in-memory store, for tests that run without a database
+func NewMemory() Repositories {
+	store := newMemoryStore()
+	return Repositories{
+		Users:          &memoryUserRepository{store},
+		Chats:          &memoryChatRepository{store},
+		Agents:         &memoryAgentRepository{store},
+		Messages:       &memoryMessageRepository{store},
+		Invitations:    &memoryInvitationRepository{store},
+		Shares:         &memoryShareRepository{store},
+		Sessions:       &memorySessionRepository{store},
+		LoginThrottles: &memoryLoginThrottleRepository{store},
+		UserTokens:     &memoryUserTokenRepository{store},
+		TwoFactor:      &memoryTwoFactorRepository{store},
+		APIKeys:        &memoryAPIKeyRepository{store},
+		Identities:     &memoryIdentityRepository{store},
+		Audit:          &memoryAuditRepository{store},
+		Usage:          &memoryUsageRepository{store},
+	}
+}
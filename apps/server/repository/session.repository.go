@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+// SessionRotation is what changes when a session's refresh token is exchanged
+type SessionRotation struct {
+	RefreshTokenHash string
+	UserAgent        string
+	IPAddress        string
+	LastUsedAt       time.Time
+	ExpiresAt        time.Time
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByExternalID(ctx context.Context, sessionID uuid.UUID) (models.Session, error)
+	FindForUser(ctx context.Context, sessionID uuid.UUID, userID uint) (models.Session, error)
+	// FindByRefreshTokenHash loads the session holding the refresh token, with its user
+	FindByRefreshTokenHash(ctx context.Context, hash string) (models.Session, error)
+	// FindByPreviousTokenHash loads the session the refresh token was rotated out of, with its user
+	FindByPreviousTokenHash(ctx context.Context, hash string) (models.Session, error)
+	// ListActive returns the user's unrevoked and unexpired sessions, most recently used first
+	ListActive(ctx context.Context, userID uint, now time.Time) ([]models.Session, error)
+	// Rotate swaps in a new refresh token only if currentHash is still the session's token, and reports whether it did
+	Rotate(ctx context.Context, sessionID uint, currentHash string, rotation SessionRotation) (bool, error)
+	Revoke(ctx context.Context, sessionID uint) error
+	// RevokeAll revokes every session of the user except exceptSessionID, which may be zero
+	RevokeAll(ctx context.Context, userID uint, exceptSessionID uint) error
+}
+
+type postgresSessionRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *postgresSessionRepository) FindByExternalID(ctx context.Context, sessionID uuid.UUID) (models.Session, error) {
+	return r.first(r.db.WithContext(ctx), "external_id = ?", sessionID)
+}
+
+func (r *postgresSessionRepository) FindForUser(ctx context.Context, sessionID uuid.UUID, userID uint) (models.Session, error) {
+	return r.first(r.db.WithContext(ctx), "external_id = ? AND user_id = ?", sessionID, userID)
+}
+
+func (r *postgresSessionRepository) FindByRefreshTokenHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.first(r.db.WithContext(ctx).Preload("User"), "refresh_token_hash = ?", hash)
+}
+
+func (r *postgresSessionRepository) FindByPreviousTokenHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.first(r.db.WithContext(ctx).Preload("User"), "previous_token_hash = ?", hash)
+}
+
+func (r *postgresSessionRepository) first(query *gorm.DB, conditions string, args ...any) (models.Session, error) {
+	var session models.Session
+	if err := query.Where(conditions, args...).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Session{}, ErrSessionNotFound
+		}
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+func (r *postgresSessionRepository) ListActive(ctx context.Context, userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *postgresSessionRepository) Rotate(ctx context.Context, sessionID uint, currentHash string, rotation SessionRotation) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", sessionID, currentHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  rotation.RefreshTokenHash,
+			"previous_token_hash": currentHash,
+			"last_used_at":        rotation.LastUsedAt,
+			"expires_at":          rotation.ExpiresAt,
+			"user_agent":          rotation.UserAgent,
+			"ip_address":          rotation.IPAddress,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *postgresSessionRepository) Revoke(ctx context.Context, sessionID uint) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+func (r *postgresSessionRepository) RevokeAll(ctx context.Context, userID uint, exceptSessionID uint) error {
+	query := r.db.WithContext(ctx).Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptSessionID != 0 {
+		query = query.Where("id <> ?", exceptSessionID)
+	}
+	return query.Update("revoked_at", time.Now().UTC()).Error
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type ShareRepository interface {
+	Create(ctx context.Context, share *models.ChatShare) error
+	// ListForChat returns every share link of the chat newest first, including expired and revoked ones
+	ListForChat(ctx context.Context, chatID uint) ([]models.ChatShare, error)
+	FindForChat(ctx context.Context, chatID uint, shareID uuid.UUID) (models.ChatShare, error)
+	// FindByTokenHash loads the share link with its chat. A deleted chat leaves share.Chat zero.
+	FindByTokenHash(ctx context.Context, hash string) (models.ChatShare, error)
+	// Revoke stops the share link from working, leaving an already revoked one as it is
+	Revoke(ctx context.Context, share *models.ChatShare, now time.Time) error
+}
+
+type postgresShareRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresShareRepository) Create(ctx context.Context, share *models.ChatShare) error {
+	return r.db.WithContext(ctx).Create(share).Error
+}
+
+func (r *postgresShareRepository) ListForChat(ctx context.Context, chatID uint) ([]models.ChatShare, error) {
+	var shares []models.ChatShare
+	err := r.db.WithContext(ctx).Where("chat_id = ?", chatID).Order("created_at DESC").Find(&shares).Error
+	return shares, err
+}
+
+func (r *postgresShareRepository) FindForChat(ctx context.Context, chatID uint, shareID uuid.UUID) (models.ChatShare, error) {
+	return r.first(r.db.WithContext(ctx), "chat_id = ? AND external_id = ?", chatID, shareID)
+}
+
+func (r *postgresShareRepository) FindByTokenHash(ctx context.Context, hash string) (models.ChatShare, error) {
+	return r.first(r.db.WithContext(ctx).Preload("Chat"), "token_hash = ?", hash)
+}
+
+func (r *postgresShareRepository) first(query *gorm.DB, conditions string, args ...any) (models.ChatShare, error) {
+	var share models.ChatShare
+	if err := query.Where(conditions, args...).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ChatShare{}, ErrShareNotFound
+		}
+		return models.ChatShare{}, err
+	}
+	return share, nil
+}
+
+func (r *postgresShareRepository) Revoke(ctx context.Context, share *models.ChatShare, now time.Time) error {
+	if share.RevokedAt != nil {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Model(share).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	share.RevokedAt = &now
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+// TwoFactorRepository keeps each user's TOTP secret, the last TOTP step they used and their recovery codes.
+// Secrets arrive already encrypted and codes already hashed.
+type TwoFactorRepository interface {
+	// BeginEnrollment stores a pending secret and forgets the last step used with the previous one
+	BeginEnrollment(ctx context.Context, userID uint, secret string) error
+	// Enable turns two-factor authentication on and replaces the recovery codes in one transaction
+	Enable(ctx context.Context, userID uint, enabledAt time.Time, codeHashes []string) error
+	// Disable clears the secret and deletes the recovery codes
+	Disable(ctx context.Context, userID uint) error
+	// AdvanceStep records step as the last TOTP step used, only if it is later than the stored one, and reports whether it did
+	AdvanceStep(ctx context.Context, userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	// UseRecoveryCode marks the user's unused code with the hash used and reports whether there was one
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) (bool, error)
+	// CountRecoveryCodes counts the user's unused recovery codes
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+}
+
+type postgresTwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresTwoFactorRepository) BeginEnrollment(ctx context.Context, userID uint, secret string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error
+}
+
+func (r *postgresTwoFactorRepository) Enable(ctx context.Context, userID uint, enabledAt time.Time, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled_at", enabledAt).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *postgresTwoFactorRepository) Disable(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Unscoped().Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":           "",
+			"totp_last_step":        0,
+			"two_factor_enabled_at": nil,
+		}).Error
+	})
+}
+
+func (r *postgresTwoFactorRepository) AdvanceStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	// Guarded on the stored step so two concurrent requests cannot both use the same code
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *postgresTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Unscoped().Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+
+	records := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	return tx.Create(&records).Error
+}
+
+func (r *postgresTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *postgresTwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
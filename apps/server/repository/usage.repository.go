@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type UsageRepository interface {
+	// Counters returns the user's daily counters from since onwards
+	Counters(ctx context.Context, userID uint, since time.Time) ([]models.UsageCounter, error)
+	// Record adds one model request and its tokens to the user's counter for the day
+	Record(ctx context.Context, userID uint, day time.Time, tokens int64) error
+	LogModelCall(ctx context.Context, entry *models.GeminiLogs) error
+	// Totals sums the model calls logged since the given time, for one user or for everyone when userID is 0
+	Totals(ctx context.Context, since time.Time, userID uint) (UsageTotals, error)
+	// TopUsers returns the users with the most tokens since the given time, heaviest first
+	TopUsers(ctx context.Context, since time.Time, limit int) ([]UserUsageTotals, error)
+	// Daily groups the user's model calls by UTC day, chat and agent, newest day first
+	Daily(ctx context.Context, userID uint, since time.Time) ([]DailyUsageTotals, error)
+}
+
+type UsageTotals struct {
+	Requests      int64   `json:"requests"`
+	InputTokens   int64   `json:"inputTokens"`
+	OutputTokens  int64   `json:"outputTokens"`
+	TotalTokens   int64   `json:"totalTokens"`
+	EstimatedCost float64 `json:"estimatedCost"`
+}
+
+type UserUsageTotals struct {
+	UsageTotals
+	UserID uint
+}
+
+type DailyUsageTotals struct {
+	UsageTotals
+	Day          time.Time
+	ChatID       *uint
+	AgentID      *uint
+	Errors       int64
+	AvgLatencyMs float64
+}
+
+const usageTotalsColumns = "COUNT(*) AS requests, COALESCE(SUM(input_tokens), 0) AS input_tokens, COALESCE(SUM(output_tokens), 0) AS output_tokens, COALESCE(SUM(total_tokens), 0) AS total_tokens, COALESCE(SUM(estimated_cost), 0) AS estimated_cost"
+
+type postgresUsageRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresUsageRepository) Counters(ctx context.Context, userID uint, since time.Time) ([]models.UsageCounter, error) {
+	var counters []models.UsageCounter
+	err := r.db.WithContext(ctx).Where("user_id = ? AND day >= ?", userID, since).Find(&counters).Error
+	return counters, err
+}
+
+func (r *postgresUsageRepository) Record(ctx context.Context, userID uint, day time.Time, tokens int64) error {
+	now := time.Now().UTC()
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO usage_counters (user_id, day, requests, tokens, created_at, updated_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON CONFLICT (user_id, day) DO UPDATE SET
+			requests = usage_counters.requests + 1,
+			tokens = usage_counters.tokens + EXCLUDED.tokens,
+			updated_at = EXCLUDED.updated_at
+	`, userID, day, tokens, now, now).Error
+}
+
+func (r *postgresUsageRepository) LogModelCall(ctx context.Context, entry *models.GeminiLogs) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// modelCalls selects the model calls logged since the given time
+func (r *postgresUsageRepository) modelCalls(ctx context.Context, since time.Time) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.GeminiLogs{}).Where("created_at >= ?", since)
+}
+
+func (r *postgresUsageRepository) Totals(ctx context.Context, since time.Time, userID uint) (UsageTotals, error) {
+	query := r.modelCalls(ctx, since).Select(usageTotalsColumns)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var totals UsageTotals
+	err := query.Scan(&totals).Error
+	return totals, err
+}
+
+func (r *postgresUsageRepository) TopUsers(ctx context.Context, since time.Time, limit int) ([]UserUsageTotals, error) {
+	var rows []UserUsageTotals
+	err := r.modelCalls(ctx, since).
+		Select("user_id, " + usageTotalsColumns).
+		Where("user_id IS NOT NULL").
+		Group("user_id").
+		Order("total_tokens DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *postgresUsageRepository) Daily(ctx context.Context, userID uint, since time.Time) ([]DailyUsageTotals, error) {
+	var rows []DailyUsageTotals
+	err := r.modelCalls(ctx, since).
+		Select("DATE(created_at AT TIME ZONE 'UTC') AS day, chat_id, agent_id, "+usageTotalsColumns+", COUNT(*) FILTER (WHERE error <> '') AS errors, COALESCE(AVG(latency_ms), 0) AS avg_latency_ms").
+		Where("user_id = ?", userID).
+		Group("day, chat_id, agent_id").
+		Order("day DESC, chat_id, agent_id").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+// UserTokenApply changes the user a token was consumed for and returns the columns it changed.
+// An error leaves both the token and the user untouched.
+type UserTokenApply func(token models.UserToken, user *models.User) ([]string, error)
+
+// UserTokenRepository issues and consumes emailed tokens. Consuming one happens in the same
+// transaction as the change it authorizes, so a failed change leaves the token usable.
+type UserTokenRepository interface {
+	// Issue saves the token and invalidates any earlier unused token the user has for the same purpose
+	Issue(ctx context.Context, token *models.UserToken) error
+	// Consume marks the active token with the hash used and saves what apply changes on its user.
+	// Only one caller can consume a token; the rest get ErrUserTokenNotFound.
+	Consume(ctx context.Context, hash string, purpose models.UserTokenPurpose, now time.Time, apply UserTokenApply) (models.User, error)
+}
+
+type postgresUserTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresUserTokenRepository) Issue(ctx context.Context, token *models.UserToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now().UTC()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(token).Error
+	})
+}
+
+func (r *postgresUserTokenRepository) Consume(ctx context.Context, hash string, purpose models.UserTokenPurpose, now time.Time, apply UserTokenApply) (models.User, error) {
+	var user models.User
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token models.UserToken
+		err := tx.Preload("User").Where("token_hash = ? AND purpose = ?", hash, purpose).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserTokenNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !token.IsActive(now) || token.User.ID == 0 {
+			return ErrUserTokenNotFound
+		}
+
+		result := tx.Model(&models.UserToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserTokenNotFound
+		}
+
+		user = token.User
+		columns, err := apply(token, &user)
+		if err != nil {
+			return err
+		}
+		return updateUser(tx, &user, columns)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
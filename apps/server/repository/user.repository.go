@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uint) (models.User, error)
+	FindByExternalID(ctx context.Context, id uuid.UUID) (models.User, error)
+	FindByUsername(ctx context.Context, username string) (models.User, error)
+	// FindByEmail loads the user with the normalized email
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// FindByIDs loads users by id, including deleted ones, for resolving the senders of old messages
+	FindByIDs(ctx context.Context, ids []uint) ([]models.User, error)
+	// List returns a page of users, newest first, whose username, full name or email contains search,
+	// along with how many users match in total. An empty search matches everyone.
+	List(ctx context.Context, search string, offset int, limit int) ([]models.User, int64, error)
+	// EmailTaken reports whether any account other than exceptUserID, deleted or not, already uses
+	// the normalized email. exceptUserID may be zero.
+	EmailTaken(ctx context.Context, email string, exceptUserID uint) (bool, error)
+	// UsernameTaken reports whether any account, deleted or not, already uses the username
+	UsernameTaken(ctx context.Context, username string) (bool, error)
+	Create(ctx context.Context, user *models.User) error
+	// Update saves the named columns of the user, such as "role" or "password_hash"
+	Update(ctx context.Context, user *models.User, columns ...string) error
+}
+
+type postgresUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *postgresUserRepository) FindByID(ctx context.Context, id uint) (models.User, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *postgresUserRepository) FindByExternalID(ctx context.Context, id uuid.UUID) (models.User, error) {
+	return r.first(ctx, "external_id = ?", id)
+}
+
+func (r *postgresUserRepository) FindByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.first(ctx, "username = ?", username)
+}
+
+func (r *postgresUserRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.first(ctx, "email = ?", email)
+}
+
+func (r *postgresUserRepository) first(ctx context.Context, query string, args ...any) (models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where(query, args...).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (r *postgresUserRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *postgresUserRepository) List(ctx context.Context, search string, offset int, limit int) ([]models.User, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{})
+	if search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(full_name) LIKE ? OR email LIKE ?", pattern, pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func (r *postgresUserRepository) EmailTaken(ctx context.Context, email string, exceptUserID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", email, exceptUserID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *postgresUserRepository) UsernameTaken(ctx context.Context, username string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *postgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *postgresUserRepository) Update(ctx context.Context, user *models.User, columns ...string) error {
+	return updateUser(r.db.WithContext(ctx), user, columns)
+}
+
+// updateUser saves only the named columns, zero values included, so clearing a column works
+func updateUser(db *gorm.DB, user *models.User, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	return db.Model(user).Select(columns).Updates(user).Error
+}
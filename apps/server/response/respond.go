@@ -3,17 +3,21 @@ package response
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/generative-ai-go/genai"
-	"github.com/somtojf/trio/initializers"
+	aihelpers "github.com/somtojf/trio/ai-helpers"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/lifecycle"
 	"github.com/somtojf/trio/models"
 	"github.com/somtojf/trio/prompts"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
 	"github.com/somtojf/trio/types"
 	"github.com/somtojf/trio/utils"
 )
@@ -24,27 +28,40 @@ type ReflectionAgentResponse struct {
 	Content   string `json:"content"`
 }
 
+// ShutdownNotice is the final event of a reflection stream cut short by a server shutdown
+type ShutdownNotice struct {
+	Message string `json:"message"`
+}
+
 type Response struct {
 	ChatHistory []models.Message
 	Chat        models.Chat
 	Agents      []models.Agent
 	User        models.User
 	Context     *gin.Context
-	Client      *genai.Client
+	Repos       repository.Repositories
+	Caller      aihelpers.Caller
+	Models      config.ModelConfig
+	Broker      realtime.Broker
 }
 
-func NewResponse(chatHistory []models.Message, chat models.Chat, agents []models.Agent, user models.User, context *gin.Context, client *genai.Client) Response {
+func NewResponse(chatHistory []models.Message, chat models.Chat, agents []models.Agent, user models.User, context *gin.Context, repos repository.Repositories, caller aihelpers.Caller, modelNames config.ModelConfig, broker realtime.Broker) Response {
 	return Response{
 		ChatHistory: chatHistory,
 		Chat:        chat,
 		Agents:      agents,
 		User:        user,
 		Context:     context,
-		Client:      client,
+		Repos:       repos,
+		Caller:      caller,
+		Models:      modelNames,
+		Broker:      broker,
 	}
 }
 
 func (r *Response) GenerateBasicResponse(prompt string) ([]models.Message, error) {
+	ctx := r.Context.Request.Context()
+
 	userMessage := models.Message{
 		Content:    prompt,
 		SenderType: string(types.SenderTypeUser),
@@ -52,16 +69,22 @@ func (r *Response) GenerateBasicResponse(prompt string) ([]models.Message, error
 		ChatID:     r.Chat.ID,
 	}
 
-	if err := initializers.DB.Create(&userMessage).Error; err != nil {
+	if err := r.Repos.Messages.Create(ctx, &userMessage); err != nil {
 		return nil, fmt.Errorf("Failed to add user message to chat")
 	}
+	realtime.PublishToChat(r.Context, r.Broker, r.Repos.Chats, r.Chat, realtime.EventMessageCreated, realtime.NewMessagePayload(userMessage, realtime.UserSender(r.User)))
 
 	// Get chat history
-	chatHistory, err := utils.GetChatHistory(r.Chat.ID, utils.MAX_TOKENS)
+	chatHistory, err := r.Repos.Messages.History(ctx, r.Chat.ID, utils.MAX_TOKENS)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve chat history")
 	}
 
+	participants, err := r.Repos.Chats.MemberNames(ctx, r.Chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve chat members")
+	}
+
 	shuffledAgents := utils.RandomizeArrayElements(r.Chat.Agents)
 
 	var agentResponses []models.Message
@@ -75,7 +98,12 @@ func (r *Response) GenerateBasicResponse(prompt string) ([]models.Message, error
 			otherAgent = shuffledAgents[0]
 		}
 
-		response, err := generateAgentResponse(r.Context.Request.Context(), r.Client, agent, chatHistory, prompt, r.User.Username, otherAgent)
+		realtime.PublishToChat(r.Context, r.Broker, r.Repos.Chats, r.Chat, realtime.EventGenerationStarted, realtime.ActivityPayload{Name: agent.Name})
+		response, err := generateAgentResponse(ctx, r.Caller, r.Models.Basic, agent, chatHistory, prompt, r.User, participants, otherAgent)
+		realtime.PublishToChat(r.Context, r.Broker, r.Repos.Chats, r.Chat, realtime.EventGenerationFinished, realtime.ActivityPayload{Name: agent.Name})
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			return nil, err
+		}
 		if err != nil {
 			return nil, fmt.Errorf("Failed to generate response for %s", agent.Name)
 		}
@@ -88,6 +116,13 @@ func (r *Response) GenerateBasicResponse(prompt string) ([]models.Message, error
 }
 
 func (r *Response) GenerateReflectionResponse(prompt string) error {
+	ctx := r.Context.Request.Context()
+
+	// Checked before anything is saved, since a quota error cannot be reported once the stream starts
+	if _, err := quota.Check(ctx, r.Caller.Usage, r.Caller.Plans, r.User); err != nil {
+		return err
+	}
+
 	userMessage := models.Message{
 		Content:    prompt,
 		SenderType: string(types.SenderTypeUser),
@@ -95,11 +130,12 @@ func (r *Response) GenerateReflectionResponse(prompt string) error {
 		ChatID:     r.Chat.ID,
 	}
 
-	if err := initializers.DB.Create(&userMessage).Error; err != nil {
+	if err := r.Repos.Messages.Create(ctx, &userMessage); err != nil {
 		return fmt.Errorf("Failed to add user message to chat")
 	}
+	realtime.PublishToChat(r.Context, r.Broker, r.Repos.Chats, r.Chat, realtime.EventMessageCreated, realtime.NewMessagePayload(userMessage, realtime.UserSender(r.User)))
 
-	chatHistory, err := utils.GetChatHistory(r.Chat.ID, utils.MAX_TOKENS)
+	chatHistory, err := r.Repos.Messages.History(ctx, r.Chat.ID, utils.MAX_TOKENS)
 	if err != nil {
 		return fmt.Errorf("Failed to retrieve chat history")
 	}
@@ -111,40 +147,62 @@ func (r *Response) GenerateReflectionResponse(prompt string) error {
 	r.Context.Writer.Header().Set("Connection", "keep-alive")
 	r.Context.Writer.Header().Set("Transfer-Encoding", "chunked")
 
+	closeStream := r.Caller.Metrics.SSEStreamOpened()
+	defer closeStream()
+
 	responseChan := make(chan ReflectionAgentResponse, len(shuffledAgents))
-	doneChan := make(chan struct{})
 
 	// Start the agent response loop
-	go ReflectionAgentResponseLoop(r.Context.Request.Context(), r.Client, shuffledAgents, chatHistory, userMessage.Content, responseChan, doneChan)
+	go ReflectionAgentResponseLoop(ctx, r.Repos, r.Caller, r.Models.Reflection, r.Broker, r.Chat, r.User, shuffledAgents, chatHistory, userMessage.Content, responseChan)
 
-	// Stream responses to the client
-	for {
-		select {
-		case response, ok := <-responseChan:
-			if !ok {
-				return nil
-			}
-			data, err := json.Marshal(response)
-			if err != nil {
-				log.Printf("Error marshaling response: %v", err)
-				continue
-			}
-			r.Context.SSEvent("message", string(data))
-			r.Context.Writer.Flush()
-		case <-doneChan:
-			return nil
+	// Stream responses to the client until the loop closes the channel, so the final verdict is never dropped
+	var last ReflectionAgentResponse
+	for response := range responseChan {
+		last = response
+		data, err := json.Marshal(response)
+		if err != nil {
+			log.Printf("Error marshaling response: %v", err)
+			continue
 		}
+		r.Context.SSEvent("message", string(data))
+		r.Context.Writer.Flush()
+	}
+
+	// The loop stops at the last saved turn once shutdown starts, so tell the client why no verdict came
+	if isDraining(ctx) && (last.Content == "" || !types.GetReflectionVerdict(last.Content).IsTerminal()) {
+		data, _ := json.Marshal(ShutdownNotice{Message: "The server is restarting. The conversation so far has been saved, send another message to continue."})
+		r.Context.SSEvent("shutdown", string(data))
+		r.Context.Writer.Flush()
+	}
+	return nil
+}
+
+func isDraining(ctx context.Context) bool {
+	select {
+	case <-lifecycle.Draining(ctx):
+		return true
+	default:
+		return false
 	}
 }
 
-func ReflectionAgentResponseLoop(ctx context.Context, client *genai.Client, agents []models.Agent, chatHistory []models.Message, userMessage string, responseChan chan<- ReflectionAgentResponse, doneChan chan<- struct{}) {
+func ReflectionAgentResponseLoop(ctx context.Context, repos repository.Repositories, caller aihelpers.Caller, modelName string, broker realtime.Broker, chat models.Chat, user models.User, agents []models.Agent, chatHistory []models.Message, userMessage string, responseChan chan<- ReflectionAgentResponse) {
 	defer close(responseChan)
-	defer close(doneChan)
+
+	rounds := 0
+	defer func() { caller.Metrics.ObserveReflectionRounds(rounds) }()
 
 	agentResponses := make(map[uint]string)
 	for {
+		rounds++
 		for _, agent := range agents {
-			response := GenerateAgentResponseAsync(ctx, client, agent, chatHistory, userMessage, agentResponses)
+			realtime.PublishToChat(ctx, broker, repos.Chats, chat, realtime.EventGenerationStarted, realtime.ActivityPayload{Name: agent.Name})
+			response := GenerateAgentResponseAsync(ctx, caller, modelName, agent, user, chatHistory, userMessage, agentResponses)
+			realtime.PublishToChat(ctx, broker, repos.Chats, chat, realtime.EventGenerationFinished, realtime.ActivityPayload{Name: agent.Name})
+			// Canceled at the shutdown deadline or by the client leaving, keeping the turns already saved
+			if ctx.Err() != nil {
+				return
+			}
 			agentResponses[agent.ID] = response
 
 			responseChan <- ReflectionAgentResponse{
@@ -159,21 +217,31 @@ func ReflectionAgentResponseLoop(ctx context.Context, client *genai.Client, agen
 				SenderID:   agent.ID,
 				ChatID:     chatHistory[0].ChatID,
 			}
-			if err := initializers.DB.Create(&message).Error; err != nil {
-				log.Printf("Error saving message for agent %s: %v", agent.ID, err)
+			if err := repos.Messages.Create(ctx, &message); err != nil {
+				log.Printf("Error saving message for agent %d: %v", agent.ID, err)
 				return
 			}
+			realtime.PublishToChat(ctx, broker, repos.Chats, chat, realtime.EventMessageCreated, realtime.NewMessagePayload(message, realtime.AgentSender(agent)))
 
-			if strings.HasPrefix(strings.ToLower(response), "agree") || strings.HasSuffix(strings.ToLower(response), "alternate") || response == "" {
+			if response == "" || types.GetReflectionVerdict(response).IsTerminal() {
+				return
+			}
+			// Shutting down: every turn so far is saved, so stop here instead of starting another
+			if isDraining(ctx) {
 				return
 			}
 		}
 
-		for agentID, response := range agentResponses {
+		for _, agent := range agents {
+			response, ok := agentResponses[agent.ID]
+			if !ok {
+				continue
+			}
 			message := models.Message{
 				Content:    response,
 				SenderType: string(types.SenderTypeAgent),
-				SenderID:   agentID,
+				SenderID:   agent.ID,
+				SenderName: agent.Name,
 				ChatID:     chatHistory[0].ChatID,
 			}
 			chatHistory = append(chatHistory, message)
@@ -181,36 +249,33 @@ func ReflectionAgentResponseLoop(ctx context.Context, client *genai.Client, agen
 	}
 }
 
-func GenerateAgentResponseAsync(ctx context.Context, client *genai.Client, agent models.Agent, chatHistory []models.Message, userMessage string, otherAgentResponses map[uint]string) string {
-	model := client.GenerativeModel("gemini-1.5-pro")
-	promptGenerator := prompts.NewPromptGenerator(agent, chatHistory, "", models.Agent{}, userMessage)
+func GenerateAgentResponseAsync(ctx context.Context, caller aihelpers.Caller, modelName string, agent models.Agent, user models.User, chatHistory []models.Message, userMessage string, otherAgentResponses map[uint]string) string {
+	promptGenerator := prompts.NewPromptGenerator(agent, chatHistory, "", nil, models.Agent{}, userMessage)
 
 	prompt := promptGenerator.GenerateReflectionPrompt(otherAgentResponses)
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	call := aihelpers.ModelCall{User: user, ChatID: agent.ChatID, Agent: &agent}
+	completion, err := aihelpers.GenerateContent(ctx, caller, modelName, call, prompt)
 	if err != nil {
 		log.Printf("Error generating content for agent %s: %v", agent.Name, err)
 		return ""
 	}
 
-	response := resp.Candidates[0].Content.Parts[0].(genai.Text)
-	return string(response)
+	return completion.Text
 }
 
-func generateAgentResponse(ctx context.Context, client *genai.Client, agent models.Agent, chatHistory []models.Message, userMessage string, userName string, otherAgent models.Agent) (models.Message, error) {
-	model := client.GenerativeModel("gemini-1.5-flash")
-	promptGenerator := prompts.NewPromptGenerator(agent, chatHistory, userName, otherAgent, userMessage)
+func generateAgentResponse(ctx context.Context, caller aihelpers.Caller, modelName string, agent models.Agent, chatHistory []models.Message, userMessage string, user models.User, participants []string, otherAgent models.Agent) (models.Message, error) {
+	promptGenerator := prompts.NewPromptGenerator(agent, chatHistory, user.Username, participants, otherAgent, userMessage)
 	prompt := promptGenerator.GenerateBasicPrompt()
 
-	res, err := model.GenerateContent(ctx, genai.Text(prompt))
+	call := aihelpers.ModelCall{User: user, ChatID: agent.ChatID, Agent: &agent}
+	completion, err := aihelpers.GenerateContent(ctx, caller, modelName, call, prompt)
 	if err != nil {
 		return models.Message{}, err
 	}
 
-	var aiResponse string
-	if len(res.Candidates) > 0 && len(res.Candidates[0].Content.Parts) > 0 {
-		aiResponse = string(res.Candidates[0].Content.Parts[0].(genai.Text))
-	} else {
+	aiResponse := completion.Text
+	if aiResponse == "" {
 		aiResponse = "No response generated"
 	}
 
@@ -218,6 +283,7 @@ func generateAgentResponse(ctx context.Context, client *genai.Client, agent mode
 		Content:    aiResponse,
 		SenderType: string(types.SenderTypeAgent),
 		SenderID:   agent.ID,
+		SenderName: agent.Name,
 		ChatID:     chatHistory[0].ChatID,
 	}, nil
 }
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/app"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/controllers"
+	docs "github.com/somtojf/trio/docs"
+	"github.com/somtojf/trio/middleware"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/ratelimit"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// NewRouter registers every route against handlers built from the app
+func NewRouter(a *app.App, limits ratelimit.Limits) *gin.Engine {
+	r := gin.Default()
+	// Without this gin believes X-Forwarded-For from any peer, letting clients pick the IP they are limited by
+	if err := r.SetTrustedProxies(a.Config.TrustedProxies); err != nil {
+		a.Logger.Error("Failed to set trusted proxies, trusting none", "error", err)
+		r.SetTrustedProxies(nil)
+	}
+	h := controllers.NewHandler(a)
+	authLimit := middleware.RateLimit(a.RateLimitStore, limits.Auth)
+	generationLimit := middleware.RateLimit(a.RateLimitStore, limits.Generation)
+	generation := middleware.TrackGeneration(a.Generations)
+
+	config := cors.DefaultConfig()
+	config.AllowOrigins = []string{a.Config.ClientAddress}
+	config.AllowCredentials = true
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.ExposeHeaders = append([]string{"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}, quota.HeaderNames()...)
+
+	r.Use(middleware.Metrics(a.Metrics), cors.New(config))
+
+	docs.SwaggerInfo.BasePath = "/"
+
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Probes and metrics for container orchestrators, outside rate limits so polling never gets throttled
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	// Without a token /metrics is only served on the internal listener, see ServeMetrics
+	if a.Config.Metrics.Token != "" {
+		r.GET("/metrics", middleware.RequireMetricsToken(a.Config.Metrics.Token), gin.WrapH(a.Metrics.Handler()))
+	}
+
+	// Public routes are limited per IP
+	public := r.Group("/")
+	{
+		public.POST("/login", authLimit, h.Login)
+		public.POST("/login/2fa", authLimit, h.LoginTwoFactor)
+		public.POST("/signup", authLimit, h.Signup)
+		public.GET("/shared/:token", middleware.RateLimit(a.RateLimitStore, limits.Read), h.GetSharedChat)
+		public.POST("/refresh", authLimit, h.RefreshSession)
+		public.POST("/logout", h.Logout)
+		public.POST("/verify-email", authLimit, h.VerifyEmail)
+		public.POST("/forgot-password", authLimit, h.ForgotPassword)
+		public.POST("/forgot-password/reset", authLimit, h.ResetForgottenPassword)
+		public.GET("/auth/oidc/providers", h.GetOIDCProviders)
+		public.GET("/auth/oidc/:provider/login", authLimit, h.StartOIDCLogin)
+		public.GET("/auth/oidc/:provider/callback", authLimit, h.OIDCCallback)
+	}
+
+	// Authenticated routes are limited per user, with model calls limited separately
+	authenticated := r.Group("/")
+	authenticated.Use(middleware.CheckAuth(a.Repositories, a.Keys, a.Config.Domain), middleware.RateLimitByMethod(a.RateLimitStore, limits.Read, limits.Write))
+	{
+		// RequireScope lets sessions through and only stops API keys missing the scope, so every
+		// route here needs a scope guard or sessionOnly to keep keys to what they were granted
+		readChats := middleware.RequireScope(auth.ScopeChatsRead)
+		writeChats := middleware.RequireScope(auth.ScopeChatsWrite)
+		writeAgents := middleware.RequireScope(auth.ScopeAgentsWrite)
+		sessionOnly := middleware.RequireSession()
+
+		authenticated.POST("/logout-all", sessionOnly, h.LogoutEverywhere)
+		authenticated.POST("/reset-password", sessionOnly, authLimit, h.ResetPassword)
+		authenticated.GET("/completions", middleware.RequireScope(auth.ScopeCompletions), generationLimit, generation, h.GetCompletion)
+		authenticated.GET("/ws", readChats, h.ConnectRealtime)
+		authenticated.GET("/auth/oidc/:provider/link", sessionOnly, h.StartOIDCLink)
+
+		// Chat related endpoints
+		chats := authenticated.Group("/chats")
+		{
+			chats.POST("", writeChats, h.CreateChat)
+			chats.GET("", readChats, h.GetUserChats)
+			chats.POST("/import", writeChats, generationLimit, generation, h.ImportChat)
+			chats.GET("/:chatId", readChats, h.GetChatInfo)
+			chats.DELETE("/:chatId", writeChats, h.DeleteChat)
+			chats.PUT("/:chatId", writeChats, h.UpdateChat)
+			chats.POST("/:chatId/messages", writeChats, generationLimit, generation, h.NewMessage)
+			chats.POST("/:chatId/agents", writeAgents, h.AddAgentToChat)
+			chats.GET("/:chatId/export", readChats, h.ExportChat)
+			chats.POST("/:chatId/shares", writeChats, h.CreateChatShare)
+			chats.GET("/:chatId/shares", readChats, h.GetChatShares)
+			chats.DELETE("/:chatId/shares/:shareId", writeChats, h.RevokeChatShare)
+			chats.GET("/:chatId/members", readChats, h.GetChatMembers)
+			chats.PUT("/:chatId/members/:userId", writeChats, h.UpdateChatMember)
+			chats.DELETE("/:chatId/members/:userId", writeChats, h.RemoveChatMember)
+			chats.POST("/:chatId/invitations", writeChats, h.InviteToChat)
+			chats.GET("/:chatId/invitations", readChats, h.GetChatInvitations)
+			chats.DELETE("/:chatId/invitations/:invitationId", writeChats, h.RevokeChatInvitation)
+		}
+
+		user := authenticated.Group("/me")
+		{
+			user.GET("", readChats, h.GetCurrentUser)
+			user.GET("/export", readChats, h.ExportAllChats)
+			user.GET("/quota", readChats, h.GetMyQuota)
+			user.GET("/usage", readChats, h.GetMyUsage)
+			user.GET("/invitations", readChats, h.GetMyInvitations)
+			user.GET("/sessions", sessionOnly, h.GetSessions)
+			user.DELETE("/sessions/:sessionId", sessionOnly, h.RevokeSession)
+			user.POST("/api-keys", sessionOnly, h.CreateAPIKey)
+			user.GET("/api-keys", sessionOnly, h.GetAPIKeys)
+			user.DELETE("/api-keys/:keyId", sessionOnly, h.RevokeAPIKey)
+			user.PUT("/email", sessionOnly, h.UpdateEmail)
+			user.POST("/email/verification", sessionOnly, h.ResendVerificationEmail)
+			user.GET("/2fa", sessionOnly, h.GetTwoFactorStatus)
+			user.DELETE("/2fa", sessionOnly, h.DisableTwoFactor)
+			user.POST("/2fa/totp", sessionOnly, h.BeginTOTPEnrollment)
+			user.POST("/2fa/totp/verify", sessionOnly, h.ConfirmTOTPEnrollment)
+			user.POST("/2fa/recovery-codes", sessionOnly, h.RegenerateRecoveryCodes)
+			user.GET("/identities", sessionOnly, h.GetIdentities)
+			user.DELETE("/identities/:identityId", sessionOnly, h.UnlinkIdentity)
+		}
+
+		invitations := authenticated.Group("/invitations")
+		invitations.Use(sessionOnly)
+		{
+			invitations.POST("/:invitationId/accept", h.AcceptInvitation)
+			invitations.POST("/:invitationId/decline", h.DeclineInvitation)
+		}
+
+		admin := authenticated.Group("/admin")
+		admin.Use(sessionOnly, middleware.RequireRole(models.UserRoleAdmin))
+		{
+			admin.GET("/users", h.AdminListUsers)
+			admin.GET("/users/:userId", h.AdminGetUser)
+			admin.PUT("/users/:userId/role", h.AdminUpdateUserRole)
+			admin.PUT("/users/:userId/plan", h.AdminUpdateUserPlan)
+			admin.POST("/users/:userId/suspend", h.AdminSuspendUser)
+			admin.POST("/users/:userId/unsuspend", h.AdminUnsuspendUser)
+			admin.POST("/users/:userId/reset-password", h.AdminResetUserPassword)
+			admin.GET("/usage", h.AdminGetUsage)
+			admin.GET("/plans", h.AdminGetPlans)
+		}
+
+		// Agent related endpoints
+		agents := authenticated.Group("/agents")
+		{
+			agents.GET("/:agentId", readChats, h.GetAgent)
+			agents.PUT("/:agentId", writeAgents, h.UpdateAgent)
+			agents.DELETE("/:agentId", writeAgents, h.DeleteAgent)
+		}
+	}
+
+	return r
+}
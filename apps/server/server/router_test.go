@@ -0,0 +1,1008 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/somtojf/trio/app"
+	"github.com/somtojf/trio/auth"
+	"github.com/somtojf/trio/config"
+	"github.com/somtojf/trio/lifecycle"
+	"github.com/somtojf/trio/llm"
+	"github.com/somtojf/trio/mailer"
+	"github.com/somtojf/trio/metrics"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/quota"
+	"github.com/somtojf/trio/ratelimit"
+	"github.com/somtojf/trio/realtime"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/types"
+)
+
+// fakeProvider answers with the queued replies in order, then with "ok" once they run out
+type fakeProvider struct {
+	mu      sync.Mutex
+	replies []string
+	prompts []string
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, model string, prompt string) (llm.Completion, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prompts = append(p.prompts, prompt)
+	text := "ok"
+	if len(p.replies) > 0 {
+		text, p.replies = p.replies[0], p.replies[1:]
+	}
+	return llm.Completion{Text: text, InputTokens: 10, OutputTokens: 5, FinishReason: "STOP"}, nil
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = []float32{0}
+	}
+	return vectors, nil
+}
+
+func (p *fakeProvider) Close() error {
+	return nil
+}
+
+func (p *fakeProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.prompts)
+}
+
+// testMailer keeps every message so tests can follow the links in them
+type testMailer struct {
+	messages chan mailer.Message
+}
+
+func newTestMailer() *testMailer {
+	return &testMailer{messages: make(chan mailer.Message, 16)}
+}
+
+func (m *testMailer) Send(ctx context.Context, message mailer.Message) error {
+	m.messages <- message
+	return nil
+}
+
+var testLimits = ratelimit.Limits{
+	Auth:       ratelimit.Limit{Name: "auth", Requests: 1000, Period: time.Minute},
+	Generation: ratelimit.Limit{Name: "generation", Requests: 1000, Period: time.Minute},
+	Read:       ratelimit.Limit{Name: "read", Requests: 1000, Period: time.Minute},
+	Write:      ratelimit.Limit{Name: "write", Requests: 1000, Period: time.Minute},
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func testConfig() config.Config {
+	cfg := config.Defaults()
+	cfg.Secret = "test-secret"
+	cfg.ClientAddress = "http://localhost:3000"
+	cfg.LLM.Provider = "fake"
+	return cfg
+}
+
+// newTestApp gives each test its own broker and rate limit store, so no state leaks between tests
+func newTestApp(t *testing.T, provider llm.Provider) *app.App {
+	t.Helper()
+
+	cfg := testConfig()
+	keys, err := auth.NewKeys(cfg.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans, err := quota.LoadPlans(cfg.Quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &app.App{
+		Repositories:   repository.NewMemory(),
+		LLM:            provider,
+		Config:         cfg,
+		Keys:           keys,
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Generations:    lifecycle.NewGenerations(),
+		Broker:         realtime.NewMemoryBroker(),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		Mailer:         newTestMailer(),
+		Plans:          plans,
+		OIDC:           auth.NewOIDCProviders(cfg.OIDC),
+		Metrics:        metrics.New(),
+	}
+	t.Cleanup(func() {
+		a.Broker.Close()
+		a.RateLimitStore.Close()
+	})
+	return a
+}
+
+// newTestServer runs the router against in-memory repositories. DB is left nil, so any
+// handler on these paths that still reaches for the database fails the test.
+func newTestServer(t *testing.T) (*httptest.Server, *fakeProvider) {
+	t.Helper()
+
+	provider := &fakeProvider{}
+	server := httptest.NewServer(NewRouter(newTestApp(t, provider), testLimits))
+	t.Cleanup(server.Close)
+	return server, provider
+}
+
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	http   *http.Client
+	// bearer is sent as the Authorization header when set, as API key clients do
+	bearer string
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *testClient {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, server: server, http: &http.Client{Jar: jar}}
+}
+
+func (c *testClient) do(method string, path string, body any) *http.Response {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearer)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+// expect checks the status and decodes the JSON body into out, if given
+func (c *testClient) expect(res *http.Response, status int, out any) {
+	c.t.Helper()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if res.StatusCode != status {
+		c.t.Fatalf("%s %s: got status %d, want %d: %s", res.Request.Method, res.Request.URL.Path, res.StatusCode, status, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			c.t.Fatalf("%s %s: decoding %s: %v", res.Request.Method, res.Request.URL.Path, data, err)
+		}
+	}
+}
+
+// signupAndLogin creates an account and leaves the client holding its session cookies
+func signupAndLogin(t *testing.T, server *httptest.Server, username string) *testClient {
+	t.Helper()
+
+	client := newTestClient(t, server)
+	client.expect(client.do(http.MethodPost, "/signup", gin.H{
+		"userName": username,
+		"fullName": "Test " + username,
+		"email":    username + "@example.com",
+		"password": "correct-horse",
+	}), http.StatusCreated, nil)
+	client.expect(client.do(http.MethodPost, "/login", gin.H{
+		"username": username,
+		"password": "correct-horse",
+	}), http.StatusOK, nil)
+	return client
+}
+
+// mailedToken waits for an email linking to path and returns the token in the link.
+// Other mail, such as the verification email sent on signup, is skipped.
+func mailedToken(t *testing.T, a *app.App, path string) string {
+	t.Helper()
+
+	messages := a.Mailer.(*testMailer).messages
+	prefix := a.Config.ClientAddress + path + "?"
+	for {
+		select {
+		case message := <-messages:
+			start := strings.Index(message.Text, prefix)
+			if start < 0 {
+				continue
+			}
+			link, _, _ := strings.Cut(message.Text[start:], "\n")
+			parsed, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return parsed.Query().Get("token")
+		case <-time.After(time.Second):
+			t.Fatalf("no email linking to %s was sent", path)
+		}
+	}
+}
+
+type agentJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type chatJSON struct {
+	ID       string      `json:"id"`
+	ChatName string      `json:"chatName"`
+	Type     string      `json:"type"`
+	Agents   []agentJSON `json:"agents"`
+	Messages []struct {
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+func createChat(client *testClient, chatType string, agentNames ...string) chatJSON {
+	client.t.Helper()
+
+	agents := make([]gin.H, 0, len(agentNames))
+	for _, name := range agentNames {
+		agents = append(agents, gin.H{"name": name, "lingo": "casual", "traits": []string{"curious"}})
+	}
+
+	var created struct {
+		Data chatJSON `json:"data"`
+	}
+	client.expect(client.do(http.MethodPost, "/chats", gin.H{
+		"chatName": "Test chat",
+		"type":     chatType,
+		"agents":   agents,
+	}), http.StatusCreated, &created)
+	return created.Data
+}
+
+func TestSignupLoginLogout(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := signupAndLogin(t, server, "alice")
+
+	var me struct {
+		Data struct {
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	client.expect(client.do(http.MethodGet, "/me", nil), http.StatusOK, &me)
+	if me.Data.Username != "alice" {
+		t.Fatalf("got username %q, want alice", me.Data.Username)
+	}
+
+	client.expect(client.do(http.MethodPost, "/signup", gin.H{
+		"userName": "alice",
+		"fullName": "Another Alice",
+		"email":    "other@example.com",
+		"password": "correct-horse",
+	}), http.StatusBadRequest, nil)
+
+	stranger := newTestClient(t, server)
+	stranger.expect(stranger.do(http.MethodPost, "/login", gin.H{
+		"username": "alice",
+		"password": "wrong-password",
+	}), http.StatusBadRequest, nil)
+	stranger.expect(stranger.do(http.MethodGet, "/me", nil), http.StatusUnauthorized, nil)
+
+	client.expect(client.do(http.MethodPost, "/logout", nil), http.StatusOK, nil)
+	client.expect(client.do(http.MethodGet, "/me", nil), http.StatusUnauthorized, nil)
+}
+
+func TestChatCRUD(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := signupAndLogin(t, server, "alice")
+
+	chat := createChat(client, "DEFAULT", "Ada")
+	if chat.ID == "" || len(chat.Agents) != 1 || chat.Agents[0].Name != "Ada" {
+		t.Fatalf("unexpected chat %+v", chat)
+	}
+
+	var list struct {
+		Data []chatJSON `json:"data"`
+	}
+	client.expect(client.do(http.MethodGet, "/chats", nil), http.StatusOK, &list)
+	if len(list.Data) != 1 || list.Data[0].ID != chat.ID {
+		t.Fatalf("got chats %+v, want only %s", list.Data, chat.ID)
+	}
+
+	var updated struct {
+		Data chatJSON `json:"data"`
+	}
+	client.expect(client.do(http.MethodPut, "/chats/"+chat.ID, gin.H{
+		"chatName": "Renamed",
+		"agents": []gin.H{{
+			"id":       chat.Agents[0].ID,
+			"name":     "Grace",
+			"metadata": gin.H{"lingo": "formal", "traits": []string{"precise"}},
+		}},
+	}), http.StatusOK, &updated)
+	if updated.Data.ChatName != "Renamed" || len(updated.Data.Agents) != 1 || updated.Data.Agents[0].Name != "Grace" {
+		t.Fatalf("unexpected updated chat %+v", updated.Data)
+	}
+
+	var info struct {
+		Data chatJSON `json:"data"`
+	}
+	client.expect(client.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusOK, &info)
+	if info.Data.ChatName != "Renamed" {
+		t.Fatalf("got chat name %q, want Renamed", info.Data.ChatName)
+	}
+
+	client.expect(client.do(http.MethodDelete, "/chats/"+chat.ID, nil), http.StatusNoContent, nil)
+	client.expect(client.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusNotFound, nil)
+}
+
+func TestAgentLimits(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := signupAndLogin(t, server, "alice")
+
+	client.expect(client.do(http.MethodPost, "/chats", gin.H{
+		"chatName": "Crowded",
+		"type":     "DEFAULT",
+		"agents": []gin.H{
+			{"name": "One", "lingo": "casual", "traits": []string{"curious"}},
+			{"name": "Two", "lingo": "casual", "traits": []string{"curious"}},
+			{"name": "Three", "lingo": "casual", "traits": []string{"curious"}},
+		},
+	}), http.StatusBadRequest, nil)
+
+	client.expect(client.do(http.MethodPost, "/chats", gin.H{
+		"chatName": "Lonely",
+		"type":     "REFLECTION",
+		"agents":   []gin.H{{"name": "One", "lingo": "casual", "traits": []string{"curious"}}},
+	}), http.StatusBadRequest, nil)
+
+	chat := createChat(client, "DEFAULT", "Ada")
+	newAgent := func(name string, traits ...string) gin.H {
+		return gin.H{"name": name, "lingo": "casual", "traits": append([]string{"curious"}, traits...)}
+	}
+
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/agents", newAgent("Ada")), http.StatusConflict, nil)
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/agents", newAgent("Grace", "a", "b", "c", "d")), http.StatusBadRequest, nil)
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/agents", newAgent("Grace")), http.StatusCreated, nil)
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/agents", newAgent("Linus")), http.StatusBadRequest, nil)
+}
+
+func TestDefaultMessageGeneration(t *testing.T) {
+	server, provider := newTestServer(t)
+	client := signupAndLogin(t, server, "alice")
+	chat := createChat(client, "DEFAULT", "Ada", "Grace")
+
+	var generated struct {
+		RequestPrompt string `json:"requestPrompt"`
+		Data          []struct {
+			Content    string `json:"content"`
+			SenderType string `json:"senderType"`
+		} `json:"data"`
+	}
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "Hello agents"}), http.StatusCreated, &generated)
+	if generated.RequestPrompt != "Hello agents" {
+		t.Fatalf("got request prompt %q", generated.RequestPrompt)
+	}
+	if len(generated.Data) != 2 {
+		t.Fatalf("got %d agent responses, want 2", len(generated.Data))
+	}
+	for _, message := range generated.Data {
+		if message.Content != "ok" {
+			t.Fatalf("got agent response %q, want ok", message.Content)
+		}
+	}
+	if calls := provider.calls(); calls != 2 {
+		t.Fatalf("got %d model calls, want 2", calls)
+	}
+
+	var info struct {
+		Data chatJSON `json:"data"`
+	}
+	client.expect(client.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusOK, &info)
+	if len(info.Data.Messages) != 3 || info.Data.Messages[0].Content != "Hello agents" {
+		t.Fatalf("got messages %+v, want the prompt and two responses", info.Data.Messages)
+	}
+
+	var status struct {
+		Data struct {
+			Day struct {
+				Requests int64 `json:"requests"`
+				Tokens   int64 `json:"tokens"`
+			} `json:"day"`
+		} `json:"data"`
+	}
+	client.expect(client.do(http.MethodGet, "/me/quota", nil), http.StatusOK, &status)
+	if status.Data.Day.Requests != 2 || status.Data.Day.Tokens != 30 {
+		t.Fatalf("got usage %+v, want 2 requests and 30 tokens", status.Data.Day)
+	}
+}
+
+func TestReflectionStreamingStopsOnVerdict(t *testing.T) {
+	server, provider := newTestServer(t)
+	client := signupAndLogin(t, server, "alice")
+	chat := createChat(client, "REFLECTION", "Ada", "Grace")
+
+	provider.replies = []string{
+		"I think the answer is 42",
+		"I see it differently, it is 41",
+		"Agree, 41 it is",
+	}
+
+	res := client.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "What is the answer?"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Fatalf("got content type %q, want text/event-stream", contentType)
+	}
+
+	var events []struct {
+		AgentName string `json:"agentName"`
+		Content   string `json:"content"`
+	}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			AgentName string `json:"agentName"`
+			Content   string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("decoding event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+	if last := events[len(events)-1]; types.GetReflectionVerdict(last.Content) != types.ReflectionVerdictAgree {
+		t.Fatalf("stream ended on %q, want an agreeing verdict", last.Content)
+	}
+	if events[0].AgentName == events[1].AgentName || events[0].AgentName != events[2].AgentName {
+		t.Fatalf("agents did not take turns: %+v", events)
+	}
+	if calls := provider.calls(); calls != 3 {
+		t.Fatalf("got %d model calls, want 3", calls)
+	}
+}
+
+func TestUsersCannotReachEachOthersChats(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	bob := signupAndLogin(t, server, "bob")
+
+	chat := createChat(alice, "DEFAULT", "Ada")
+	agentPath := "/agents/" + chat.Agents[0].ID
+
+	var list struct {
+		Data []chatJSON `json:"data"`
+	}
+	bob.expect(bob.do(http.MethodGet, "/chats", nil), http.StatusOK, &list)
+	if len(list.Data) != 0 {
+		t.Fatalf("bob sees alice's chats: %+v", list.Data)
+	}
+
+	bob.expect(bob.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodPut, "/chats/"+chat.ID, gin.H{"chatName": "Mine now", "agents": []gin.H{}}), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodDelete, "/chats/"+chat.ID, nil), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "hi"}), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodPost, "/chats/"+chat.ID+"/agents", gin.H{"name": "Eve", "lingo": "casual", "traits": []string{"curious"}}), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodGet, agentPath, nil), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodDelete, agentPath, nil), http.StatusNotFound, nil)
+
+	alice.expect(alice.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusOK, nil)
+	alice.expect(alice.do(http.MethodGet, agentPath, nil), http.StatusOK, nil)
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := newTestClient(t, server)
+
+	var alive struct {
+		Status string `json:"status"`
+	}
+	client.expect(client.do(http.MethodGet, "/healthz", nil), http.StatusOK, &alive)
+	if alive.Status != "up" {
+		t.Fatalf("got liveness %q, want up", alive.Status)
+	}
+
+	// The test app has no database or Qdrant, only a model
+	var report struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status   string `json:"status"`
+			Optional bool   `json:"optional"`
+			Error    string `json:"error"`
+		} `json:"checks"`
+	}
+	client.expect(client.do(http.MethodGet, "/readyz", nil), http.StatusServiceUnavailable, &report)
+	if report.Status != "down" {
+		t.Fatalf("got readiness %q, want down", report.Status)
+	}
+	if postgres := report.Checks["postgres"]; postgres.Status != "down" || postgres.Error != "not configured" {
+		t.Fatalf("got postgres %+v, want down and not configured", postgres)
+	}
+	if qdrant := report.Checks["qdrant"]; qdrant.Status != "down" || !qdrant.Optional {
+		t.Fatalf("got qdrant %+v, want down and optional", qdrant)
+	}
+	if llm := report.Checks["llm"]; llm.Status != "up" {
+		t.Fatalf("got llm %+v, want up", llm)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp(t, &fakeProvider{})
+	server := httptest.NewServer(NewRouter(a, testLimits))
+	t.Cleanup(server.Close)
+	client := signupAndLogin(t, server, "alice")
+	chat := createChat(client, "DEFAULT", "Ada")
+	client.expect(client.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "Hello"}), http.StatusCreated, nil)
+	client.expect(client.do("BREW", "/coffee", nil), http.StatusNotFound, nil)
+
+	// Without a token the public port does not serve metrics at all
+	client.expect(client.do(http.MethodGet, "/metrics", nil), http.StatusNotFound, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ServeMetrics(ctx, a, listener)
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d from the internal listener, want 200", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		// Routes are recorded by pattern, never by the chat ID in the path
+		`trio_http_requests_total{method="POST",route="/chats/:chatId/messages",status="201"}`,
+		`trio_http_request_duration_seconds_bucket{method="POST",route="/chats/:chatId/messages"`,
+		`trio_llm_call_duration_seconds_count{model="gemini-1.5-flash"}`,
+		`trio_llm_tokens_total{direction="output",model="gemini-1.5-flash"}`,
+		`trio_sse_connections_open`,
+		// Made-up methods share one series
+		`trio_http_requests_total{method="other",route="unmatched",status="404"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+	if strings.Contains(string(body), chat.ID) {
+		t.Error("metrics should not contain chat IDs")
+	}
+	if strings.Contains(string(body), "Ada") {
+		t.Error("metrics should not contain user-chosen agent names")
+	}
+	if strings.Contains(string(body), "BREW") {
+		t.Error("metrics should not contain unknown methods")
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	a := newTestApp(t, &fakeProvider{})
+	a.Config.Metrics.Token = "scrape-me"
+	server := httptest.NewServer(NewRouter(a, testLimits))
+	t.Cleanup(server.Close)
+	client := newTestClient(t, server)
+
+	client.expect(client.do(http.MethodGet, "/metrics", nil), http.StatusUnauthorized, nil)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer scrape-me")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d with the token, want 200", res.StatusCode)
+	}
+}
+
+// newTestAppServer is newTestServer for tests that also reach into the app, to read mail or seed data
+func newTestAppServer(t *testing.T) (*httptest.Server, *app.App) {
+	t.Helper()
+
+	a := newTestApp(t, &fakeProvider{})
+	server := httptest.NewServer(NewRouter(a, testLimits))
+	t.Cleanup(server.Close)
+	return server, a
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+
+	var enrollment struct {
+		Data struct {
+			Secret string `json:"secret"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodPost, "/me/2fa/totp", nil), http.StatusOK, &enrollment)
+
+	// Enrolling uses the current step and logging in the next one, which both stay
+	// within the allowed skew even if the clock ticks over in between
+	step := time.Now().Unix() / auth.TOTP_PERIOD
+	var confirmed struct {
+		Data struct {
+			RecoveryCodes []string `json:"recoveryCodes"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodPost, "/me/2fa/totp/verify", gin.H{"code": totpCode(t, enrollment.Data.Secret, step)}), http.StatusOK, &confirmed)
+	if len(confirmed.Data.RecoveryCodes) != auth.RECOVERY_CODES {
+		t.Fatalf("got %d recovery codes, want %d", len(confirmed.Data.RecoveryCodes), auth.RECOVERY_CODES)
+	}
+
+	loginStep := func(client *testClient) string {
+		t.Helper()
+
+		var pending struct {
+			TwoFactorRequired bool   `json:"twoFactorRequired"`
+			PreAuthToken      string `json:"preAuthToken"`
+		}
+		client.expect(client.do(http.MethodPost, "/login", gin.H{"username": "alice", "password": "correct-horse"}), http.StatusOK, &pending)
+		if !pending.TwoFactorRequired || pending.PreAuthToken == "" {
+			t.Fatalf("got %+v, want a second login step", pending)
+		}
+		// The password alone does not sign the user in
+		client.expect(client.do(http.MethodGet, "/me", nil), http.StatusUnauthorized, nil)
+		return pending.PreAuthToken
+	}
+
+	client := newTestClient(t, server)
+	preAuthToken := loginStep(client)
+	client.expect(client.do(http.MethodPost, "/login/2fa", gin.H{"preAuthToken": preAuthToken, "code": "000000"}), http.StatusBadRequest, nil)
+	code := totpCode(t, enrollment.Data.Secret, step+1)
+	client.expect(client.do(http.MethodPost, "/login/2fa", gin.H{"preAuthToken": preAuthToken, "code": code}), http.StatusOK, nil)
+	client.expect(client.do(http.MethodGet, "/me", nil), http.StatusOK, nil)
+
+	// A code that was already used cannot sign in again
+	replay := newTestClient(t, server)
+	replay.expect(replay.do(http.MethodPost, "/login/2fa", gin.H{"preAuthToken": loginStep(replay), "code": code}), http.StatusBadRequest, nil)
+
+	recovery := newTestClient(t, server)
+	recoveryCode := confirmed.Data.RecoveryCodes[0]
+	recovery.expect(recovery.do(http.MethodPost, "/login/2fa", gin.H{"preAuthToken": loginStep(recovery), "code": recoveryCode}), http.StatusOK, nil)
+	recovery.expect(recovery.do(http.MethodGet, "/me", nil), http.StatusOK, nil)
+	reused := newTestClient(t, server)
+	reused.expect(reused.do(http.MethodPost, "/login/2fa", gin.H{"preAuthToken": loginStep(reused), "code": recoveryCode}), http.StatusBadRequest, nil)
+
+	// The pre-auth token is not a session
+	stranger := newTestClient(t, server)
+	stranger.bearer = loginStep(stranger)
+	stranger.expect(stranger.do(http.MethodGet, "/me", nil), http.StatusUnauthorized, nil)
+}
+
+func TestPasswordReset(t *testing.T) {
+	server, a := newTestAppServer(t)
+	signupAndLogin(t, server, "alice")
+
+	client := newTestClient(t, server)
+	client.expect(client.do(http.MethodPost, "/forgot-password", gin.H{"email": "alice@example.com"}), http.StatusOK, nil)
+	token := mailedToken(t, a, "/reset-password")
+	client.expect(client.do(http.MethodPost, "/forgot-password/reset", gin.H{"token": token, "newPassword": "battery-staple"}), http.StatusOK, nil)
+	client.expect(client.do(http.MethodPost, "/forgot-password/reset", gin.H{"token": token, "newPassword": "another-one"}), http.StatusBadRequest, nil)
+
+	client.expect(client.do(http.MethodPost, "/login", gin.H{"username": "alice", "password": "correct-horse"}), http.StatusBadRequest, nil)
+	client.expect(client.do(http.MethodPost, "/login", gin.H{"username": "alice", "password": "battery-staple"}), http.StatusOK, nil)
+
+	client.expect(client.do(http.MethodPost, "/reset-password", gin.H{"password": "wrong-password", "newPassword": "third-time"}), http.StatusBadRequest, nil)
+	client.expect(client.do(http.MethodPost, "/reset-password", gin.H{"password": "battery-staple", "newPassword": "third-time"}), http.StatusOK, nil)
+	client.expect(client.do(http.MethodPost, "/login", gin.H{"username": "alice", "password": "third-time"}), http.StatusOK, nil)
+}
+
+func TestImportExport(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	bob := signupAndLogin(t, server, "bob")
+	chat := createChat(alice, "DEFAULT", "Ada")
+	alice.expect(alice.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "Hello Ada"}), http.StatusCreated, nil)
+
+	res := alice.do(http.MethodGet, "/chats/"+chat.ID+"/export?format=json", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+	exported, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.expect(bob.do(http.MethodGet, "/chats/"+chat.ID+"/export", nil), http.StatusNotFound, nil)
+
+	var imported struct {
+		Data          chatJSON `json:"data"`
+		MessagesCount int      `json:"messagesCount"`
+	}
+	alice.expect(alice.do(http.MethodPost, "/chats/import", gin.H{"chatName": "Imported", "transcript": json.RawMessage(exported)}), http.StatusCreated, &imported)
+	if imported.MessagesCount != 2 || imported.Data.ID == chat.ID {
+		t.Fatalf("got %d messages in chat %s, want 2 in a new chat", imported.MessagesCount, imported.Data.ID)
+	}
+
+	var info struct {
+		Data chatJSON `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodGet, "/chats/"+imported.Data.ID, nil), http.StatusOK, &info)
+	if len(info.Data.Agents) != 1 || info.Data.Agents[0].Name != "Ada" {
+		t.Fatalf("got agents %+v, want Ada", info.Data.Agents)
+	}
+	if len(info.Data.Messages) != 2 || info.Data.Messages[0].Content != "Hello Ada" || info.Data.Messages[1].Content != "ok" {
+		t.Fatalf("got messages %+v, want the original prompt and response", info.Data.Messages)
+	}
+
+	alice.expect(alice.do(http.MethodPost, "/chats/import", gin.H{"transcript": gin.H{"unknown": true}}), http.StatusBadRequest, nil)
+
+	archive := alice.do(http.MethodGet, "/me/export", nil)
+	if archive.StatusCode != http.StatusOK || archive.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("got status %d and content type %q, want a zip archive", archive.StatusCode, archive.Header.Get("Content-Type"))
+	}
+}
+
+func TestChatSharing(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	bob := signupAndLogin(t, server, "bob")
+	chat := createChat(alice, "DEFAULT", "Ada")
+	alice.expect(alice.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "Hello Ada"}), http.StatusCreated, nil)
+
+	bob.expect(bob.do(http.MethodPost, "/chats/"+chat.ID+"/shares", gin.H{}), http.StatusNotFound, nil)
+
+	var share struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Path string `json:"path"`
+	}
+	alice.expect(alice.do(http.MethodPost, "/chats/"+chat.ID+"/shares", gin.H{"expiresInHours": 24}), http.StatusCreated, &share)
+
+	anonymous := newTestClient(t, server)
+	var shared struct {
+		Messages []struct {
+			SenderName string `json:"senderName"`
+			Content    string `json:"content"`
+		} `json:"messages"`
+	}
+	anonymous.expect(anonymous.do(http.MethodGet, share.Path+"?format=json", nil), http.StatusOK, &shared)
+	if len(shared.Messages) != 2 || shared.Messages[0].Content != "Hello Ada" {
+		t.Fatalf("got shared messages %+v, want the prompt and response", shared.Messages)
+	}
+
+	var shares struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodGet, "/chats/"+chat.ID+"/shares", nil), http.StatusOK, &shares)
+	if len(shares.Data) != 1 || shares.Data[0].ID != share.Data.ID {
+		t.Fatalf("got shares %+v, want only %s", shares.Data, share.Data.ID)
+	}
+
+	alice.expect(alice.do(http.MethodDelete, "/chats/"+chat.ID+"/shares/"+share.Data.ID, nil), http.StatusOK, nil)
+	anonymous.expect(anonymous.do(http.MethodGet, share.Path, nil), http.StatusNotFound, nil)
+	anonymous.expect(anonymous.do(http.MethodGet, "/shared/not-a-token", nil), http.StatusNotFound, nil)
+}
+
+func TestChatMembersAndInvitations(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	bob := signupAndLogin(t, server, "bob")
+	carol := signupAndLogin(t, server, "carol")
+	chat := createChat(alice, "DEFAULT", "Ada")
+
+	type invitationJSON struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	invite := func(username string) invitationJSON {
+		t.Helper()
+
+		var created struct {
+			Data invitationJSON `json:"data"`
+		}
+		alice.expect(alice.do(http.MethodPost, "/chats/"+chat.ID+"/invitations", gin.H{"userName": username, "role": "viewer"}), http.StatusCreated, &created)
+		return created.Data
+	}
+
+	invitation := invite("bob")
+	var pending struct {
+		Data []invitationJSON `json:"data"`
+	}
+	bob.expect(bob.do(http.MethodGet, "/me/invitations", nil), http.StatusOK, &pending)
+	if len(pending.Data) != 1 || pending.Data[0].ID != invitation.ID {
+		t.Fatalf("got invitations %+v, want only %s", pending.Data, invitation.ID)
+	}
+	carol.expect(carol.do(http.MethodPost, "/invitations/"+invitation.ID+"/accept", nil), http.StatusNotFound, nil)
+	bob.expect(bob.do(http.MethodPost, "/invitations/"+invitation.ID+"/accept", nil), http.StatusOK, nil)
+
+	// A viewer can read but not write
+	bob.expect(bob.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusOK, nil)
+	bob.expect(bob.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "hi"}), http.StatusForbidden, nil)
+
+	var members struct {
+		Data []struct {
+			ID       string `json:"id"`
+			Username string `json:"userName"`
+			Role     string `json:"role"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodGet, "/chats/"+chat.ID+"/members", nil), http.StatusOK, &members)
+	var bobID string
+	for _, member := range members.Data {
+		if member.Username == "bob" {
+			bobID = member.ID
+		}
+	}
+	if len(members.Data) != 2 || bobID == "" {
+		t.Fatalf("got members %+v, want alice and bob", members.Data)
+	}
+
+	bob.expect(bob.do(http.MethodPut, "/chats/"+chat.ID+"/members/"+bobID, gin.H{"role": "editor"}), http.StatusForbidden, nil)
+	alice.expect(alice.do(http.MethodPut, "/chats/"+chat.ID+"/members/"+bobID, gin.H{"role": "editor"}), http.StatusOK, nil)
+	bob.expect(bob.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "hi"}), http.StatusCreated, nil)
+
+	declined := invite("carol")
+	carol.expect(carol.do(http.MethodPost, "/invitations/"+declined.ID+"/decline", nil), http.StatusOK, nil)
+	carol.expect(carol.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusNotFound, nil)
+
+	revoked := invite("carol")
+	alice.expect(alice.do(http.MethodDelete, "/chats/"+chat.ID+"/invitations/"+revoked.ID, nil), http.StatusOK, nil)
+	carol.expect(carol.do(http.MethodPost, "/invitations/"+revoked.ID+"/accept", nil), http.StatusNotFound, nil)
+
+	alice.expect(alice.do(http.MethodDelete, "/chats/"+chat.ID+"/members/"+bobID, nil), http.StatusOK, nil)
+	bob.expect(bob.do(http.MethodGet, "/chats/"+chat.ID, nil), http.StatusNotFound, nil)
+}
+
+func TestAdmin(t *testing.T) {
+	server, a := newTestAppServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	bob := signupAndLogin(t, server, "bob")
+
+	alice.expect(alice.do(http.MethodGet, "/admin/users", nil), http.StatusForbidden, nil)
+
+	user, err := a.Users.FindByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Role = models.UserRoleAdmin
+	if err := a.Users.Update(context.Background(), &user, "role"); err != nil {
+		t.Fatal(err)
+	}
+
+	var users struct {
+		Data []struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"data"`
+		Total int64 `json:"total"`
+	}
+	alice.expect(alice.do(http.MethodGet, "/admin/users?q=bob", nil), http.StatusOK, &users)
+	if len(users.Data) != 1 || users.Data[0].Username != "bob" {
+		t.Fatalf("got users %+v, want only bob", users.Data)
+	}
+	bobPath := "/admin/users/" + users.Data[0].ID
+
+	alice.expect(alice.do(http.MethodPost, "/admin/users/"+user.ExternalID.String()+"/suspend", nil), http.StatusBadRequest, nil)
+	alice.expect(alice.do(http.MethodPost, bobPath+"/suspend", nil), http.StatusOK, nil)
+	bob.expect(bob.do(http.MethodGet, "/me", nil), http.StatusUnauthorized, nil)
+	bob.expect(bob.do(http.MethodPost, "/login", gin.H{"username": "bob", "password": "correct-horse"}), http.StatusForbidden, nil)
+
+	alice.expect(alice.do(http.MethodPost, bobPath+"/unsuspend", nil), http.StatusOK, nil)
+	bob.expect(bob.do(http.MethodPost, "/login", gin.H{"username": "bob", "password": "correct-horse"}), http.StatusOK, nil)
+
+	var promoted struct {
+		Data struct {
+			Role string `json:"role"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodPut, bobPath+"/role", gin.H{"role": "admin"}), http.StatusOK, &promoted)
+	if promoted.Data.Role != "admin" {
+		t.Fatalf("got role %q, want admin", promoted.Data.Role)
+	}
+	bob.expect(bob.do(http.MethodGet, "/admin/usage", nil), http.StatusOK, nil)
+	alice.expect(alice.do(http.MethodGet, "/admin/plans", nil), http.StatusOK, nil)
+	alice.expect(alice.do(http.MethodGet, bobPath, nil), http.StatusOK, nil)
+}
+
+func TestAPIKeys(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := signupAndLogin(t, server, "alice")
+	createChat(alice, "DEFAULT", "Ada")
+
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Key string `json:"key"`
+	}
+	alice.expect(alice.do(http.MethodPost, "/me/api-keys", gin.H{"name": "Reader", "scopes": []string{"chats:read"}}), http.StatusCreated, &created)
+
+	keyed := newTestClient(t, server)
+	keyed.bearer = created.Key
+	var list struct {
+		Data []chatJSON `json:"data"`
+	}
+	keyed.expect(keyed.do(http.MethodGet, "/chats", nil), http.StatusOK, &list)
+	if len(list.Data) != 1 {
+		t.Fatalf("got %d chats through the key, want 1", len(list.Data))
+	}
+	// Keys only reach what their scopes allow, and never the account settings
+	keyed.expect(keyed.do(http.MethodPost, "/chats", gin.H{"chatName": "Nope", "type": "DEFAULT", "agents": []gin.H{}}), http.StatusForbidden, nil)
+	keyed.expect(keyed.do(http.MethodGet, "/me/api-keys", nil), http.StatusForbidden, nil)
+
+	var keys struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	alice.expect(alice.do(http.MethodGet, "/me/api-keys", nil), http.StatusOK, &keys)
+	if len(keys.Data) != 1 || keys.Data[0].ID != created.Data.ID {
+		t.Fatalf("got keys %+v, want only %s", keys.Data, created.Data.ID)
+	}
+
+	alice.expect(alice.do(http.MethodDelete, "/me/api-keys/"+created.Data.ID, nil), http.StatusOK, nil)
+	keyed.expect(keyed.do(http.MethodGet, "/chats", nil), http.StatusUnauthorized, nil)
+}
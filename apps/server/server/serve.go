@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/somtojf/trio/app"
+)
+
+// Serve handles requests on the listener until ctx is canceled, then shuts down gracefully:
+// it stops accepting connections, closes realtime connections, and gives in-flight requests
+// and generations until timeout to finish. Reflection streams stop after their current turn.
+// Whatever is still running at the deadline is canceled. The caller closes the App afterwards.
+func Serve(ctx context.Context, a *app.App, listener net.Listener, handler http.Handler, timeout time.Duration) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shutdown does not track WebSockets, so closing the broker is what ends them
+	server.RegisterOnShutdown(func() {
+		if err := a.Broker.Close(); err != nil {
+			slog.Error("Failed to close realtime broker", "error", err)
+		}
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	slog.Info("Listening", "address", listener.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- a.Generations.Drain(shutdownCtx)
+	}()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Requests were still running at the shutdown deadline, closing their connections", "error", err)
+		server.Close()
+	}
+	if err := <-drained; err != nil {
+		slog.Warn("Generations were still running at the shutdown deadline and were canceled", "error", err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// ServeMetrics serves /metrics without a token on an internal listener until ctx is canceled
+func ServeMetrics(ctx context.Context, a *app.App, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", a.Metrics.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Serving metrics", "address", listener.Addr().String())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/somtojf/trio/lifecycle"
+	"github.com/somtojf/trio/llm"
+)
+
+// drainingProvider holds every call until shutdown starts, so the generation is in flight across it
+type drainingProvider struct {
+	*fakeProvider
+	started chan struct{}
+}
+
+func (p *drainingProvider) Generate(ctx context.Context, model string, prompt string) (llm.Completion, error) {
+	select {
+	case p.started <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-lifecycle.Draining(ctx):
+	case <-ctx.Done():
+		return llm.Completion{}, ctx.Err()
+	}
+	return p.fakeProvider.Generate(ctx, model, prompt)
+}
+
+func TestShutdownCheckpointsReflectionStream(t *testing.T) {
+	provider := &drainingProvider{fakeProvider: &fakeProvider{}, started: make(chan struct{}, 1)}
+	a := newTestApp(t, provider)
+
+	// Serve takes the listener in place of the test server, which is never started
+	server := httptest.NewUnstartedServer(NewRouter(a, testLimits))
+	server.URL = "http://" + server.Listener.Addr().String()
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- Serve(ctx, a, server.Listener, server.Config.Handler, 5*time.Second)
+	}()
+
+	client := signupAndLogin(t, server, "alice")
+	chat := createChat(client, "REFLECTION", "Ada", "Grace")
+
+	go func() {
+		<-provider.started
+		shutdown()
+	}()
+
+	// Never agreeing, the agents would go on forever if shutdown did not stop them
+	res := client.do(http.MethodPost, "/chats/"+chat.ID+"/messages", gin.H{"content": "What is the answer?"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event:"); ok {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(events, ",") != "message,shutdown" {
+		t.Fatalf("got events %v, want the turn in flight and then a shutdown notice", events)
+	}
+	if calls := provider.calls(); calls != 1 {
+		t.Fatalf("got %d model calls, want 1", calls)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+
+	if _, _, err := a.Generations.Begin(context.Background()); err != lifecycle.ErrShuttingDown {
+		t.Fatalf("got %v starting a generation after shutdown, want ErrShuttingDown", err)
+	}
+}
@@ -0,0 +1,24 @@
+package transcript
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+)
+
+// WriteArchive zips every transcript into w, one file per chat
+func WriteArchive(w io.Writer, transcripts []Transcript, format Format) error {
+	archive := zip.NewWriter(w)
+
+	for _, transcript := range transcripts {
+		file, err := archive.Create(transcript.FileName(format))
+		if err != nil {
+			return fmt.Errorf("failed to add %s to archive: %w", transcript.ChatName, err)
+		}
+		if err := transcript.Render(file, format); err != nil {
+			return fmt.Errorf("failed to render %s: %w", transcript.ChatName, err)
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,154 @@
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/somtojf/trio/types"
+)
+
+const DefaultImportedAgentName = "Assistant"
+
+var ErrUnrecognizedTranscript = errors.New("unrecognized transcript format")
+
+// externalMessage covers OpenAI-style {role, content, name} messages as well as
+// Gemini-style {role, parts} contents. Content may be a string or a list of text parts.
+type externalMessage struct {
+	Role    string          `json:"role"`
+	Name    string          `json:"name"`
+	Content json.RawMessage `json:"content"`
+	Parts   []struct {
+		Text string `json:"text"`
+	} `json:"parts"`
+}
+
+type externalTranscript struct {
+	Format   string            `json:"format"`
+	Messages []externalMessage `json:"messages"`
+	Contents []externalMessage `json:"contents"`
+}
+
+// Parse reads a Trio JSON export or a common chat transcript format:
+// an OpenAI-style message array, an object with a "messages" array, or a Gemini-style "contents" array.
+func Parse(data []byte) (Transcript, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return Transcript{}, ErrUnrecognizedTranscript
+	}
+
+	if strings.HasPrefix(trimmed, "[") {
+		var messages []externalMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return Transcript{}, fmt.Errorf("%w: %v", ErrUnrecognizedTranscript, err)
+		}
+		return fromExternalMessages(messages)
+	}
+
+	var external externalTranscript
+	if err := json.Unmarshal(data, &external); err != nil {
+		return Transcript{}, fmt.Errorf("%w: %v", ErrUnrecognizedTranscript, err)
+	}
+
+	switch {
+	case external.Format == TranscriptFormat:
+		var transcript Transcript
+		if err := json.Unmarshal(data, &transcript); err != nil {
+			return Transcript{}, fmt.Errorf("%w: %v", ErrUnrecognizedTranscript, err)
+		}
+		if transcript.Version > TranscriptVersion {
+			return Transcript{}, fmt.Errorf("%w: transcript version %d is newer than supported version %d", ErrUnrecognizedTranscript, transcript.Version, TranscriptVersion)
+		}
+		for _, message := range transcript.Messages {
+			if !message.SenderType.IsValid() {
+				return Transcript{}, fmt.Errorf("%w: invalid sender type %q", ErrUnrecognizedTranscript, message.SenderType)
+			}
+		}
+		return transcript, nil
+	case len(external.Messages) > 0:
+		return fromExternalMessages(external.Messages)
+	case len(external.Contents) > 0:
+		return fromExternalMessages(external.Contents)
+	}
+
+	return Transcript{}, ErrUnrecognizedTranscript
+}
+
+func fromExternalMessages(messages []externalMessage) (Transcript, error) {
+	var transcript Transcript
+	agentNames := make(map[string]bool)
+
+	for _, message := range messages {
+		content, err := message.text()
+		if err != nil {
+			return Transcript{}, err
+		}
+
+		var entry Message
+		switch strings.ToLower(message.Role) {
+		case "user", "human":
+			entry = Message{SenderType: types.SenderTypeUser, SenderName: message.Name}
+		case "assistant", "model", "ai":
+			name := message.Name
+			if name == "" {
+				name = DefaultImportedAgentName
+			}
+			if !agentNames[name] {
+				agentNames[name] = true
+				transcript.Agents = append(transcript.Agents, Agent{Name: name})
+			}
+			entry = Message{SenderType: types.SenderTypeAgent, SenderName: name}
+		default:
+			// System prompts and tool calls have no equivalent in a Trio chat
+			continue
+		}
+
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		entry.Content = content
+		transcript.Messages = append(transcript.Messages, entry)
+	}
+
+	if len(transcript.Messages) == 0 {
+		return Transcript{}, fmt.Errorf("%w: transcript has no user or assistant messages", ErrUnrecognizedTranscript)
+	}
+
+	return transcript, nil
+}
+
+func (m externalMessage) text() (string, error) {
+	if len(m.Parts) > 0 {
+		var text []string
+		for _, part := range m.Parts {
+			text = append(text, part.Text)
+		}
+		return strings.Join(text, "\n"), nil
+	}
+
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+
+	var content string
+	if err := json.Unmarshal(m.Content, &content); err == nil {
+		return content, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("%w: unsupported message content", ErrUnrecognizedTranscript)
+	}
+
+	var text []string
+	for _, part := range parts {
+		if part.Type == "" || part.Type == "text" {
+			text = append(text, part.Text)
+		}
+	}
+	return strings.Join(text, "\n"), nil
+}
@@ -0,0 +1,115 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+const timestampLayout = "2006-01-02 15:04:05 MST"
+
+// Render writes the transcript to w in the requested format
+func (t Transcript) Render(w io.Writer, format Format) error {
+	switch format {
+	case FormatMarkdown:
+		return t.renderMarkdown(w)
+	case FormatJSON:
+		return t.renderJSON(w)
+	case FormatHTML:
+		return t.renderHTML(w)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+func (t Transcript) renderJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(t)
+}
+
+func (t Transcript) renderMarkdown(w io.Writer) error {
+	var md strings.Builder
+
+	fmt.Fprintf(&md, "# %s\n\n", t.ChatName)
+	fmt.Fprintf(&md, "- **Type:** %s\n", t.Type)
+	fmt.Fprintf(&md, "- **Created:** %s\n", formatTimestamp(t.CreatedAt))
+	fmt.Fprintf(&md, "- **Exported:** %s\n\n", formatTimestamp(t.ExportedAt))
+
+	md.WriteString("## Agents\n\n")
+	for _, agent := range t.Agents {
+		fmt.Fprintf(&md, "- **%s**", agent.Name)
+		if len(agent.Traits) > 0 {
+			fmt.Fprintf(&md, ": %s", strings.Join(agent.Traits, ", "))
+		}
+		if agent.Lingo != "" {
+			fmt.Fprintf(&md, " (lingo: %s)", agent.Lingo)
+		}
+		md.WriteString("\n")
+	}
+
+	md.WriteString("\n## Transcript\n")
+	for _, message := range t.Messages {
+		fmt.Fprintf(&md, "\n### %s (%s) - %s\n\n", message.SenderName, message.SenderType, formatTimestamp(message.CreatedAt))
+		md.WriteString(strings.TrimSpace(message.Content))
+		md.WriteString("\n")
+		if message.Verdict != "" {
+			fmt.Fprintf(&md, "\n> Verdict: %s\n", message.Verdict)
+		}
+	}
+
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"timestamp": formatTimestamp,
+	"join":      strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.ChatName}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; color: #1f2937; }
+.meta { color: #6b7280; font-size: 0.875rem; }
+.message { border-left: 3px solid #d1d5db; padding: 0.25rem 1rem; margin: 1rem 0; }
+.message.User { border-color: #2563eb; }
+.message.Agent { border-color: #059669; }
+.content { white-space: pre-wrap; }
+.verdict { font-size: 0.75rem; text-transform: uppercase; color: #92400e; }
+</style>
+</head>
+<body>
+<h1>{{.ChatName}}</h1>
+<p class="meta">{{.Type}} chat &middot; created {{timestamp .CreatedAt}} &middot; exported {{timestamp .ExportedAt}}</p>
+<h2>Agents</h2>
+<ul>
+{{- range .Agents}}
+<li><strong>{{.Name}}</strong>{{if .Traits}}: {{join .Traits ", "}}{{end}}{{if .Lingo}} (lingo: {{.Lingo}}){{end}}</li>
+{{- end}}
+</ul>
+<h2>Transcript</h2>
+{{- range .Messages}}
+<div class="message {{.SenderType}}">
+<p class="meta"><strong>{{.SenderName}}</strong> ({{.SenderType}}) &middot; {{timestamp .CreatedAt}}</p>
+<div class="content">{{.Content}}</div>
+{{- if .Verdict}}
+<p class="verdict">Verdict: {{.Verdict}}</p>
+{{- end}}
+</div>
+{{- end}}
+</body>
+</html>
+`))
+
+func (t Transcript) renderHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, t)
+}
+
+func formatTimestamp(timestamp time.Time) string {
+	return timestamp.UTC().Format(timestampLayout)
+}
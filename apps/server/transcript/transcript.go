@@ -0,0 +1,260 @@
+package transcript
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/repository"
+	"github.com/somtojf/trio/types"
+)
+
+const (
+	TranscriptFormat  = "trio-transcript"
+	TranscriptVersion = 1
+)
+
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	FormatHTML     Format = "html"
+)
+
+// IsValid checks if the Format is valid
+func (f Format) IsValid() bool {
+	switch f {
+	case FormatMarkdown, FormatJSON, FormatHTML:
+		return true
+	}
+	return false
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+type Agent struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Lingo  string    `json:"lingo,omitempty"`
+	Traits []string  `json:"traits,omitempty"`
+}
+
+type Message struct {
+	ID         uuid.UUID               `json:"id"`
+	SenderType types.SenderType        `json:"senderType"`
+	SenderID   *uuid.UUID              `json:"senderId,omitempty"`
+	SenderName string                  `json:"senderName"`
+	Content    string                  `json:"content"`
+	Verdict    types.ReflectionVerdict `json:"verdict,omitempty"`
+	CreatedAt  time.Time               `json:"createdAt"`
+}
+
+type Transcript struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	ChatID     uuid.UUID       `json:"chatId"`
+	ChatName   string          `json:"chatName"`
+	Type       models.ChatType `json:"type"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Agents     []Agent         `json:"agents"`
+	Messages   []Message       `json:"messages"`
+}
+
+// Load builds the transcript of a chat the caller has already authorized.
+// Agents that have since been removed from the chat are still resolved so old messages keep their sender names.
+func Load(ctx context.Context, repos repository.Repositories, chat models.Chat) (Transcript, error) {
+	return LoadUntil(ctx, repos, chat, time.Time{})
+}
+
+// LoadUntil builds the transcript with only the messages created up to cutoff. A zero cutoff includes every message.
+func LoadUntil(ctx context.Context, repos repository.Repositories, chat models.Chat, cutoff time.Time) (Transcript, error) {
+	messages, err := repos.Messages.ListForChat(ctx, chat.ID, cutoff)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("failed to retrieve messages: %w", err)
+	}
+
+	currentAgents, err := repos.Agents.ListForChat(ctx, chat.ID)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("failed to retrieve agents: %w", err)
+	}
+
+	var agentIDs, userIDs []uint
+	for _, message := range messages {
+		if message.SenderType == string(types.SenderTypeAgent) {
+			agentIDs = append(agentIDs, message.SenderID)
+		} else {
+			userIDs = append(userIDs, message.SenderID)
+		}
+	}
+
+	agentsByID := make(map[uint]models.Agent)
+	for _, agent := range currentAgents {
+		agentsByID[agent.ID] = agent
+	}
+	if len(agentIDs) > 0 {
+		senders, err := repos.Agents.FindByIDs(ctx, agentIDs)
+		if err != nil {
+			return Transcript{}, fmt.Errorf("failed to retrieve message senders: %w", err)
+		}
+		for _, agent := range senders {
+			agentsByID[agent.ID] = agent
+		}
+	}
+
+	usersByID := make(map[uint]models.User)
+	if len(userIDs) > 0 {
+		senders, err := repos.Users.FindByIDs(ctx, userIDs)
+		if err != nil {
+			return Transcript{}, fmt.Errorf("failed to retrieve message senders: %w", err)
+		}
+		for _, user := range senders {
+			usersByID[user.ID] = user
+		}
+	}
+
+	transcript := Transcript{
+		Format:     TranscriptFormat,
+		Version:    TranscriptVersion,
+		ChatID:     chat.ExternalID,
+		ChatName:   chat.ChatName,
+		Type:       chat.Type,
+		CreatedAt:  chat.CreatedAt,
+		ExportedAt: time.Now().UTC(),
+		Agents:     make([]Agent, 0, len(currentAgents)),
+		Messages:   make([]Message, 0, len(messages)),
+	}
+
+	for _, agent := range currentAgents {
+		transcript.Agents = append(transcript.Agents, newAgent(agent))
+	}
+
+	for _, message := range messages {
+		entry := Message{
+			ID:         message.ExternalID,
+			SenderType: types.SenderType(message.SenderType),
+			Content:    message.Content,
+			CreatedAt:  message.CreatedAt,
+		}
+
+		if entry.SenderType == types.SenderTypeAgent {
+			agent := agentsByID[message.SenderID]
+			entry.SenderID = &agent.ExternalID
+			entry.SenderName = agent.Name
+			if chat.Type == models.ChatTypeReflection {
+				entry.Verdict = types.GetReflectionVerdict(message.Content)
+			}
+		} else {
+			user := usersByID[message.SenderID]
+			entry.SenderID = &user.ExternalID
+			entry.SenderName = user.Username
+		}
+
+		if entry.SenderName == "" {
+			entry.SenderName = "Unknown " + string(entry.SenderType)
+		}
+
+		transcript.Messages = append(transcript.Messages, entry)
+	}
+
+	return transcript, nil
+}
+
+// Freeze replaces the chat name and agents with the ones recorded for a snapshot share,
+// renaming the agents' messages to match.
+func (t *Transcript) Freeze(chatName string, agents []models.ChatShareAgent) {
+	t.ChatName = chatName
+	t.Agents = make([]Agent, 0, len(agents))
+
+	names := make(map[uuid.UUID]string, len(agents))
+	for _, agent := range agents {
+		t.Agents = append(t.Agents, Agent{ID: agent.ExternalID, Name: agent.Name, Lingo: agent.Lingo, Traits: agent.Traits})
+		names[agent.ExternalID] = agent.Name
+	}
+
+	for i, message := range t.Messages {
+		if message.SenderType != types.SenderTypeAgent || message.SenderID == nil {
+			continue
+		}
+		if name, ok := names[*message.SenderID]; ok {
+			t.Messages[i].SenderName = name
+		}
+	}
+}
+
+// Anonymize drops the user IDs of human senders, leaving only their display names
+func (t *Transcript) Anonymize() {
+	for i, message := range t.Messages {
+		if message.SenderType != types.SenderTypeAgent {
+			t.Messages[i].SenderID = nil
+		}
+	}
+}
+
+// SnapshotAgents records the chat's agents as they are now, oldest first, to freeze a snapshot share later
+func SnapshotAgents(agents []models.Agent) []models.ChatShareAgent {
+	ordered := append([]models.Agent(nil), agents...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].CreatedAt.Before(ordered[j].CreatedAt) })
+
+	snapshot := make([]models.ChatShareAgent, 0, len(ordered))
+	for _, agent := range ordered {
+		exported := newAgent(agent)
+		snapshot = append(snapshot, models.ChatShareAgent{
+			ExternalID: exported.ID,
+			Name:       exported.Name,
+			Lingo:      exported.Lingo,
+			Traits:     exported.Traits,
+		})
+	}
+	return snapshot
+}
+
+func newAgent(agent models.Agent) Agent {
+	exported := Agent{
+		ID:   agent.ExternalID,
+		Name: agent.Name,
+	}
+	if agent.Metadata != nil {
+		exported.Lingo = agent.Metadata.Lingo
+		exported.Traits = agent.Metadata.Traits
+	}
+	return exported
+}
+
+// FileName returns a filesystem-safe name for the exported transcript
+func (t Transcript) FileName(format Format) string {
+	return fmt.Sprintf("%s-%s.%s", slugify(t.ChatName), t.ChatID.String(), format)
+}
+
+func slugify(name string) string {
+	slug := make([]rune, 0, len(name))
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			slug = append(slug, r)
+		case r >= 'A' && r <= 'Z':
+			slug = append(slug, r+('a'-'A'))
+		case len(slug) > 0 && slug[len(slug)-1] != '-':
+			slug = append(slug, '-')
+		}
+	}
+	for len(slug) > 0 && slug[len(slug)-1] == '-' {
+		slug = slug[:len(slug)-1]
+	}
+	if len(slug) == 0 {
+		return "chat"
+	}
+	return string(slug)
+}
@@ -0,0 +1,48 @@
+package transcript
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/somtojf/trio/models"
+	"github.com/somtojf/trio/types"
+)
+
+func TestFreezeAndAnonymizeForSharing(t *testing.T) {
+	agentID, userID := uuid.New(), uuid.New()
+	chat := Transcript{
+		ChatName: "Renamed later",
+		Agents:   []Agent{{ID: agentID, Name: "Renamed agent"}, {ID: uuid.New(), Name: "Added later"}},
+		Messages: []Message{
+			{SenderType: types.SenderTypeUser, SenderID: &userID, SenderName: "alice", Content: "hi"},
+			{SenderType: types.SenderTypeAgent, SenderID: &agentID, SenderName: "Renamed agent", Content: "hello"},
+		},
+	}
+
+	chat.Freeze("Original", []models.ChatShareAgent{{ExternalID: agentID, Name: "Sage", Traits: []string{"calm"}}})
+	chat.Anonymize()
+
+	if chat.ChatName != "Original" || len(chat.Agents) != 1 || chat.Agents[0].Name != "Sage" {
+		t.Fatalf("snapshot name and agents not restored: %q %+v", chat.ChatName, chat.Agents)
+	}
+	if chat.Messages[1].SenderName != "Sage" || chat.Messages[1].SenderID == nil {
+		t.Fatalf("agent message should keep its ID under the snapshot name, got %+v", chat.Messages[1])
+	}
+	if chat.Messages[0].SenderID != nil || chat.Messages[0].SenderName != "alice" {
+		t.Fatalf("user message should keep only the display name, got %+v", chat.Messages[0])
+	}
+}
+
+func TestSnapshotAgentsOldestFirst(t *testing.T) {
+	now := time.Now()
+	older := models.Agent{Name: "first", Metadata: &models.AgentMetadata{Lingo: "pirate"}}
+	older.CreatedAt = now.Add(-time.Hour)
+	newer := models.Agent{Name: "second"}
+	newer.CreatedAt = now
+
+	snapshot := SnapshotAgents([]models.Agent{newer, older})
+	if len(snapshot) != 2 || snapshot[0].Name != "first" || snapshot[0].Lingo != "pirate" || snapshot[1].Name != "second" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
@@ -0,0 +1,38 @@
+package types
+
+import "strings"
+
+type ReflectionVerdict string
+
+const (
+	ReflectionVerdictAgree     ReflectionVerdict = "agree"
+	ReflectionVerdictAlternate ReflectionVerdict = "alternate"
+	ReflectionVerdictDisagree  ReflectionVerdict = "disagree"
+)
+
+// IsValid checks if the ReflectionVerdict is valid
+func (rv ReflectionVerdict) IsValid() bool {
+	switch rv {
+	case ReflectionVerdictAgree, ReflectionVerdictAlternate, ReflectionVerdictDisagree:
+		return true
+	}
+	return false
+}
+
+// Ends the reflection loop when an agent agrees or contributes an alternate view
+func (rv ReflectionVerdict) IsTerminal() bool {
+	return rv == ReflectionVerdictAgree || rv == ReflectionVerdictAlternate
+}
+
+// GetReflectionVerdict classifies a reflection agent's response the same way the reflection loop always has:
+// surrounding whitespace is significant, so a reply ending in "alternate\n" does not end the loop
+func GetReflectionVerdict(response string) ReflectionVerdict {
+	normalized := strings.ToLower(response)
+	switch {
+	case strings.HasPrefix(normalized, string(ReflectionVerdictAgree)):
+		return ReflectionVerdictAgree
+	case strings.HasSuffix(normalized, string(ReflectionVerdictAlternate)):
+		return ReflectionVerdictAlternate
+	}
+	return ReflectionVerdictDisagree
+}
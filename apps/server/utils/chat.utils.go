@@ -5,7 +5,6 @@ import (
 	"math/rand/v2"
 	"strings"
 
-	"github.com/somtojf/trio/initializers"
 	"github.com/somtojf/trio/models"
 )
 
@@ -22,32 +21,14 @@ func RandomizeArrayElements[T any](array []T) []T {
 	return shuffled
 }
 
-func GetChatHistory(chatID uint, maxTokens int) ([]models.Message, error) {
-	var messages []models.Message
-	err := initializers.DB.Where("chat_id = ?", chatID).Order("created_at DESC").Find(&messages).Error
-	if err != nil {
-		return nil, err
-	}
-
-	// Reverse the order to get chronological order
-	for i := len(messages)/2 - 1; i >= 0; i-- {
-		opp := len(messages) - 1 - i
-		messages[i], messages[opp] = messages[opp], messages[i]
-	}
-
-	// TODO: Implement token counting and truncation logic here
-	// For now, we'll just return all messages
-	return messages, nil
-}
-
 func FormatChatHistory(history []models.Message) string {
 	var formattedHistory strings.Builder
 	for _, msg := range history {
-		formattedHistory.WriteString(fmt.Sprintf("%s: %s\n", msg.SenderType, msg.Content))
+		if msg.SenderName != "" {
+			formattedHistory.WriteString(fmt.Sprintf("%s (%s): %s\n", msg.SenderName, msg.SenderType, msg.Content))
+		} else {
+			formattedHistory.WriteString(fmt.Sprintf("%s: %s\n", msg.SenderType, msg.Content))
+		}
 	}
 	return formattedHistory.String()
 }
-
-func SaveResponsesToDatabase(responses ...models.Message) error {
-	return initializers.DB.Create(&responses).Error
-}
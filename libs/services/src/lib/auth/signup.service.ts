@@ -4,6 +4,7 @@ type SignUpType = {
   userName: string;
   password: string;
   fullName: string;
+  email: string;
 };
 
 export async function signUp(data: SignUpType): Promise<void> {
@@ -4,6 +4,8 @@ export interface User {
   id: string;
   fullName: string;
   userName: string;
+  email: string | null;
+  emailVerifiedAt: string | null;
   chats: Chat[];
 }
 